@@ -13,6 +13,8 @@ func RegisterCmds(cmds *core.CmdTree) {
 	RegisterTrivialCmds(cmds)
 	RegisterFlowCmds(cmds)
 	RegisterHubCmds(cmds)
+	RegisterSessionCmds(cmds)
+	RegisterDocCmds(cmds)
 	RegisterDbgCmds(cmds.AddSub("dbg"))
 	RegisterDisplayCmds(cmds.AddSub("display", "disp", "dis", "di"))
 	RegisterBuiltinCmds(cmds.AddSub("builtin", "b", "B").SetHidden())
@@ -68,6 +70,13 @@ func RegisterExecutorCmds(cmds *core.CmdTree) {
 		SetQuiet().
 		SetPriority()
 
+	desc.AddSub("graph", "g", "G").
+		RegPowerCmd(DumpFlowGraph,
+			"output the flow as a graph for design docs, format: dot|mermaid").
+		SetQuiet().
+		SetPriority().
+		AddArg("format", "dot", "fmt", "f", "F")
+
 	desc.AddSub("depth").
 		RegCmd(SetDumpFlowDepth,
 			"setup display stack depth of flow desc").
@@ -144,6 +153,85 @@ func RegisterFlowCmds(cmds *core.CmdTree) {
 		AddArg("cmd-path", "", "path", "p", "P").
 		AddArg("help-str", "", "help", "h", "H")
 
+	flowParam := flow.AddSub("param", "arg", "args")
+	flowParam.RegCmd(SetFlowParam,
+		"add or update a param of a saved flow, '[[name]]' in the flow will be rendered by its value").
+		SetQuiet().
+		AddArg("cmd-path", "", "path", "p", "P").
+		AddArg("name", "", "n", "N").
+		AddArg("default", "", "def", "d", "D").
+		AddArg("help", "", "h", "H")
+
+	flowParam.AddSub("remove", "rm", "delete", "del", "-").
+		RegCmd(RemoveFlowParam,
+			"remove a param of a saved flow").
+		SetQuiet().
+		AddArg("cmd-path", "", "path", "p", "P").
+		AddArg("name", "", "n", "N")
+
+	flow.AddSub("edit", "e", "E").
+		RegCmd(EditFlow,
+			"edit a saved flow by $EDITOR, the changes are rolled back if it has errors").
+		AddArg("cmd-path", "", "path", "p", "P")
+
+	flow.AddSub("rename", "ren", "rn").
+		RegCmd(RenameFlow,
+			"rename a saved flow, the abbrs of the renamed path segments are dropped").
+		SetQuiet().
+		AddArg("cmd-path", "", "path", "p", "P").
+		AddArg("to-cmd-path", "", "to", "t", "T")
+
+	flow.AddSub("copy", "cp", "dup").
+		RegCmd(CopyFlow,
+			"copy a saved flow to a new command path").
+		SetQuiet().
+		AddArg("cmd-path", "", "path", "p", "P").
+		AddArg("to-cmd-path", "", "to", "t", "T")
+
+	flow.AddSub("history", "hist", "versions", "vers").
+		RegCmd(FlowHistory,
+			"list the old versions of a saved flow, a version is kept each time it's saved, edited or changed").
+		AddArg("cmd-path", "", "path", "p", "P")
+
+	flow.AddSub("diff").
+		RegCmd(DiffFlow,
+			"show the changes from an old version of a saved flow to the current one, or to another version").
+		AddArg("cmd-path", "", "path", "p", "P").
+		AddArg("ver", "", "v", "V").
+		AddArg("to-ver", "", "to", "t", "T")
+
+	flow.AddSub("revert", "restore").
+		RegCmd(RevertFlow,
+			"restore a saved flow (could be removed) to an old version, the current one is kept as a new version").
+		SetQuiet().
+		AddArg("cmd-path", "", "path", "p", "P").
+		AddArg("ver", "", "v", "V")
+
+	flowRecord := flow.AddSub("record", "rec")
+	flowRecord.AddSub("start", "begin", "+").
+		RegCmd(StartFlowRecord,
+			"start recording a flow, the succeeded command sequences will be appended to it, until stopped").
+		SetQuiet().
+		AddArg("to-cmd-path", "", "path", "p", "P")
+	flowRecord.AddSub("stop", "end", "-").
+		RegPowerCmd(StopFlowRecord,
+			"stop recording, save the recorded flow").
+		SetQuiet()
+
+	flow.AddSub("lint", "check", "chk").
+		RegCmd(LintFlows,
+			"check flows without running them, exit with error if any problem found. "+
+				"path: 'all', a command path, or a file/dir path of flows").
+		AddArg("path", "all", "p", "P").
+		AddArg("strict", "false", "s", "S")
+
+	flow.AddSub("test", "t", "T").
+		RegCmd(RunFlowTests,
+			"run flow tests: test files in repos and self-test commands, exit with error if any failed. path: 'all', or a file/dir path of tests").
+		AddArg("path", "all", "p", "P").
+		AddArg("junit", "", "xml", "j", "J").
+		AddArg("selftest", "true", "self", "st")
+
 	flow.AddSub("remove", "rm", "delete", "del", "-").
 		RegCmd(RemoveFlow,
 			"remove a saved flow").
@@ -203,6 +291,13 @@ func RegisterEnvCmds(cmds *core.CmdTree) {
 		RegCmd(ResetLocalEnv,
 			"reset all local saved env KVs")
 
+	env.AddSub("from-stdout", "capture", "cap").
+		RegCmd(CaptureStdoutToEnv,
+			"run an os command, capture its stdout (or the matched part by regex) to an env key").
+		AddArg("key", "", "k", "K").
+		AddArg("cmd", "", "c", "C").
+		AddArg("pattern", core.Stdout2EnvWhole, "regex", "re", "p", "P")
+
 	abbrsCmdHelpStr := "enable borrowing commands' abbrs when setting KVs"
 	abbrsCmd := abbrs.AddSub("cmd")
 	abbrsCmd.RegEmptyCmd(
@@ -265,13 +360,15 @@ func RegisterHubCmds(cmds *core.CmdTree) {
 
 	add := hub.AddSub("add-and-update", "add", "a", "A", "+")
 	add.RegCmd(AddGitRepoToHub,
-		"add and pull a git address to hub, do update if it already exists").
-		AddArg("git-address", "", "git", "address", "addr")
+		"add and pull a repo (git, 'dir:<path>', 'mirror:<path>' or archive) to hub, do update if it already exists, 'addr@ref' pins a git branch, tag or commit").
+		AddArg("git-address", "", "git", "address", "addr").
+		AddArg("as", "", "mount", "--as")
 
 	add.AddSub("local-dir", "local", "l", "L").
 		RegCmd(AddLocalDirToHub,
 			"add a local dir (could be a git repo) to hub").
-		AddArg("path", "", "p", "P")
+		AddArg("path", "", "p", "P").
+		AddArg("as", "", "mount", "--as")
 
 	hubList := hub.AddSub("list", "ls", "~").
 		RegCmd(ListHub,
@@ -290,6 +387,62 @@ func RegisterHubCmds(cmds *core.CmdTree) {
 		RegCmd(UpdateHub,
 			"update all repos and mods defined in hub")
 
+	changes := hub.AddSub("changes", "change", "diff").
+		RegCmd(ShowHubChanges,
+			"show the added, removed and modified commands of the last hub update")
+	addFindStrArgs(changes)
+
+	deps := hub.AddSub("depends", "deps", "dep").
+		RegCmd(ShowHubDepends,
+			"show versions and dependencies of enabled repos, report missing or conflicted ones")
+	addFindStrArgs(deps)
+
+	find := hub.AddSub("find", "search", "f", "F").
+		RegCmd(FindInHubIndex,
+			"search commands in all known repos, include the disabled and not added ones")
+	addFindStrArgs(find)
+
+	hub.AddSub("index", "reindex").
+		RegCmd(RebuildHubIndex,
+			"rebuild the command search index of all known repos")
+
+	hub.AddSub("lock").
+		RegCmd(LockHub,
+			"write the exact commits of all repos to a lock file, default is the one in hub").
+		AddArg("path", "", "p", "P")
+
+	hub.AddSub("sync").
+		RegCmd(SyncHubToLock,
+			"clone or checkout repos to the commits in a lock file, default is the one in hub").
+		AddArg("path", "", "p", "P")
+
+	bundle := hub.AddSub("bundle", "mirror")
+	bundle.AddSub("export", "exp").
+		RegCmd(ExportHubBundle,
+			"pack matched git repos, with their sub-repos and depended repos, to a bundle file").
+		AddArg("path", "", "p", "P").
+		AddArg("find-str", "", "s", "S")
+	bundle.AddSub("import", "imp").
+		RegCmd(ImportHubBundle,
+			"install repos from a bundle file without network, the existing ones are updated").
+		AddArg("path", "", "p", "P")
+
+	hub.AddSub("priority", "prio", "pri").
+		RegCmd(SetRepoPriorityInHub,
+			"set the priority of matched repos, higher one shadows the same commands from lower ones").
+		AddArg("find-str", "", "s", "S").
+		AddArg("priority", "", "p", "P")
+
+	hub.AddSub("trust").
+		RegCmd(TrustRepoInHub,
+			"add a repo address or an owner like 'innerr/*' to the allowlist, show trust status if empty").
+		AddArg("addr", "", "address", "owner")
+
+	hub.AddSub("untrust").
+		RegCmd(UntrustRepoInHub,
+			"remove matched allowlist entries and review records").
+		AddArg("find-str", "", "s", "S")
+
 	hub.AddSub("enable-repo", "enable", "ena", "en", "e", "E").
 		RegCmd(EnableRepoInHub,
 			"enable matched git repos in hub").
@@ -306,6 +459,46 @@ func RegisterHubCmds(cmds *core.CmdTree) {
 		AddArg("path", "", "p", "P")
 }
 
+func RegisterSessionCmds(cmds *core.CmdTree) {
+	listSessionsHelpStr := "list sessions in local"
+	session := cmds.AddSub("session", "sessions", "sess", "ss").
+		RegCmd(ListSessions,
+			listSessionsHelpStr)
+	addFindStrArgs(session)
+
+	sessionList := session.AddSub("list", "ls", "~").
+		RegCmd(ListSessions,
+			listSessionsHelpStr)
+	addFindStrArgs(sessionList)
+
+	session.AddSub("show", "info", "i", "I").
+		RegCmd(ShowSession,
+			"show status, flow and env of a session, the latest one if id is empty").
+		AddArg("id", "", "i", "I")
+
+	session.AddSub("attach", "att", "a", "A").
+		RegCmd(AttachSession,
+			"load env of a session, the following commands will run on top of it").
+		SetQuiet().
+		AddArg("id", "", "i", "I")
+
+	session.AddSub("gc", "clean", "--").
+		RegCmd(GcSessions,
+			"remove finished sessions by the retention policy")
+}
+
+func RegisterDocCmds(cmds *core.CmdTree) {
+	doc := cmds.AddSub("doc", "docs")
+	doc.AddSub("gen", "generate", "g", "G").
+		RegCmd(GenDocs,
+			"generate docs of the commands to a dir, one page for each command and an index page by repo and tag. "+
+				"format: markdown|man, repo: only the commands from this repo (the address, or 'builtin')").
+		SetQuiet().
+		AddArg("format", "markdown", "fmt", "f", "F").
+		AddArg("out", "", "dir", "o", "O").
+		AddArg("repo", "", "source", "r", "R")
+}
+
 func RegisterBuiltinCmds(cmds *core.CmdTree) {
 	env := cmds.AddSub("env", "e", "E")
 
@@ -337,6 +530,11 @@ func RegisterBuiltinCmds(cmds *core.CmdTree) {
 		RegCmd(LoadModsFromHub,
 			"load flows and mods from local hub")
 
+	mod.AddSub("check-cycles", "cycles", "c", "C").
+		RegCmd(CheckFlowCycles,
+			"find flow cycles after all mods are loaded, the flows in cycles are unregistered").
+		SetQuiet()
+
 	cmds.AddSub("display", "disp", "dis", "di", "d", "D").
 		AddSub("load", "l", "L").
 		AddSub("platform", "p", "P").
@@ -0,0 +1,49 @@
+package builtin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+)
+
+func GenDocs(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	format := strings.ToLower(strings.TrimSpace(argv.GetRaw("format")))
+	if format == "md" {
+		format = display.CmdDocFormatMarkdown
+	}
+	out := strings.TrimSpace(argv.GetRaw("out"))
+	if len(out) == 0 {
+		panic(core.NewCmdError(cmd, "arg 'out' is empty, it should be the dir to write docs"))
+	}
+	repo := strings.TrimSpace(argv.GetRaw("repo"))
+
+	pages := display.GenCmdDocs(cc, env, format, repo)
+	if len(pages) <= 1 {
+		panic(core.NewCmdError(cmd, fmt.Sprintf("no commands from repo '%s', "+
+			"use the repo address listed by 'hub', or 'builtin'", repo)))
+	}
+
+	err := os.MkdirAll(out, os.ModePerm)
+	if err != nil {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("create dir '%s' failed: %v", out, err)))
+	}
+	for _, page := range pages {
+		path := filepath.Join(out, page.File)
+		err = ioutil.WriteFile(path, []byte(strings.Join(page.Lines, "\n")+"\n"), 0644)
+		if err != nil {
+			panic(core.WrapCmdError(cmd, fmt.Errorf("write doc file '%s' failed: %v", path, err)))
+		}
+	}
+
+	index := pages[len(pages)-1].File
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("docs of %d commands are generated in '%s', the index page:", len(pages)-1, out),
+		"",
+		"    "+filepath.Join(out, index))
+	return true
+}
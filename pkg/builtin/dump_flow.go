@@ -1,6 +1,8 @@
 package builtin
 
 import (
+	"strings"
+
 	"github.com/pingcap/ticat/pkg/cli/core"
 	"github.com/pingcap/ticat/pkg/cli/display"
 )
@@ -67,6 +69,18 @@ func DumpFlowSkeleton(
 	return clearFlow(flow)
 }
 
+func DumpFlowGraph(
+	argv core.ArgVals,
+	cc *core.Cli,
+	env *core.Env,
+	flow *core.ParsedCmds,
+	currCmdIdx int) (int, bool) {
+
+	format := strings.ToLower(argv.GetRaw("format"))
+	display.DumpFlowGraph(cc, env, flow.Cmds[currCmdIdx+1:], format)
+	return clearFlow(flow)
+}
+
 func DumpFlowDepends(
 	_ core.ArgVals,
 	cc *core.Cli,
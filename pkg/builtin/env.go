@@ -3,6 +3,7 @@ package builtin
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	"github.com/pingcap/ticat/pkg/cli/core"
@@ -15,6 +16,7 @@ func LoadDefaultEnv(env *core.Env) {
 
 	env.Set("sys.bootstrap", "")
 	env.SetInt("sys.stack-depth", 0)
+	env.SetInt("sys.flow.max-depth", 64)
 
 	env.SetBool("sys.step-by-step", false)
 	env.SetBool("sys.panic.recover", true)
@@ -27,6 +29,14 @@ func LoadDefaultEnv(env *core.Env) {
 	env.SetBool("sys.env.use-cmd-abbrs", false)
 
 	env.Set("sys.hub.init-repo", "innerr/marsh.ticat")
+	env.SetInt("sys.hub.update-concurrency", 4)
+	env.SetBool("sys.hub.trust.check", true)
+	env.Set("sys.hub.trust.keys", "")
+	env.SetBool("sys.hub.trust.require-signature", false)
+
+	env.SetBool("sys.session.auto-gc", true)
+	env.Set("sys.session.keep-duration", "72h")
+	env.SetInt("sys.session.keep-count", 32)
 
 	row, col := utils.GetTerminalWidth()
 	if col > 100 {
@@ -60,6 +70,12 @@ func LoadEnvAbbrs(abbrs *core.EnvAbbrs) {
 
 	hub := sys.GetOrAddSub("hub")
 	hub.GetOrAddSub("init-repo").AddAbbrs("repo")
+	hub.GetOrAddSub("update-concurrency").AddAbbrs("concurrency", "conc")
+
+	session := sys.GetOrAddSub("session").AddAbbrs("sess")
+	session.GetOrAddSub("auto-gc").AddAbbrs("gc")
+	session.GetOrAddSub("keep-duration").AddAbbrs("keep-dur", "dur")
+	session.GetOrAddSub("keep-count").AddAbbrs("keep-cnt", "cnt")
 
 	disp := abbrs.GetOrAddSub("display").AddAbbrs("disp", "dis", "di")
 	disp.GetOrAddSub("width").AddAbbrs("wid", "w", "W")
@@ -81,6 +97,7 @@ func LoadEnvAbbrs(abbrs *core.EnvAbbrs) {
 	mod := disp.GetOrAddSub("mod")
 	mod.GetOrAddSub("quiet").AddAbbrs("q", "Q")
 	mod.GetOrAddSub("realname").AddAbbrs("real", "r", "R")
+	mod.GetOrAddSub("shadowed").AddAbbrs("shadow")
 }
 
 func LoadRuntimeEnv(_ core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
@@ -105,6 +122,9 @@ func LoadRuntimeEnv(_ core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCm
 	env.Set("sys.paths.flows", filepath.Join(data, "flows"))
 	paths.GetOrAddSub("flows").AddAbbrs("flow")
 
+	env.Set("sys.paths.flows-history", filepath.Join(data, ".flows.history"))
+	paths.GetOrAddSub("flows-history").AddAbbrs("flow-history", "history")
+
 	env.Set("sys.paths.sessions", filepath.Join(data, "sessions"))
 	paths.GetOrAddSub("sessions").AddAbbrs("session", "s", "S")
 
@@ -162,6 +182,34 @@ func ResetLocalEnv(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.Parsed
 	return true
 }
 
+// Run an os command by bash, capture its stdout (or the matched part) to an env key
+func CaptureStdoutToEnv(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	key := getAndCheckArg(argv, env, cmd, "key")
+	cmdLine := getAndCheckArg(argv, env, cmd, "cmd")
+	pattern := argv.GetRaw("pattern")
+
+	re, err := core.CompileStdoutPattern(pattern)
+	if err != nil {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("bad pattern '%s': %v", pattern, err)))
+	}
+
+	osCmd := exec.Command("bash", "-c", cmdLine)
+	osCmd.Stdin = os.Stdin
+	osCmd.Stderr = os.Stderr
+	stdout, err := core.RunAndCaptureStdout(osCmd, cc.Screen)
+	if err != nil {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("run '%s' failed: %v", cmdLine, err)))
+	}
+
+	val, ok := core.ExtractStdoutVal(stdout, re)
+	if !ok {
+		panic(core.NewCmdError(cmd, fmt.Sprintf("pattern '%s' not found in stdout of '%s'",
+			pattern, cmdLine)))
+	}
+	env.GetLayer(core.EnvLayerSession).Set(key, val)
+	return true
+}
+
 func getEnvLocalFilePath(env *core.Env) string {
 	path := env.GetRaw("sys.paths.data")
 	file := env.GetRaw("strs.env-file-name")
@@ -187,6 +235,7 @@ func setToDefaultVerb(env *core.Env) {
 	env.SetBool("display.env.default", false)
 	env.SetBool("display.mod.quiet", false)
 	env.SetBool("display.mod.realname", true)
+	env.SetBool("display.mod.shadowed", true)
 	env.SetBool("display.env.display", false)
 	env.SetInt("display.flow.depth", 6)
 	env.SetInt("display.max-cmd-cnt", 14)
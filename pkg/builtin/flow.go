@@ -18,6 +18,7 @@ import (
 
 func ListFlows(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
 	flowExt := env.GetRaw("strs.flow-ext")
+	abbrsSep := env.GetRaw("strs.abbrs-sep")
 	root := env.GetRaw("sys.paths.flows")
 	if len(root) == 0 {
 		panic(fmt.Errorf("[ListFlows] env 'sys.paths.flows' is empty"))
@@ -63,6 +64,7 @@ func ListFlows(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd)
 			screen.Print("    - abbrs:\n")
 			screen.Print(fmt.Sprintf("        %s\n", abbrsStr))
 		}
+		printFlowParams(screen, flow_file.LoadFlowParams(path, abbrsSep))
 		screen.Print("    - flow:\n")
 		for _, flowStr := range flowStrs {
 			screen.Print(fmt.Sprintf("        %s\n", flowStr))
@@ -97,6 +99,7 @@ func RemoveFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd
 	if os.IsNotExist(err) {
 		panic(fmt.Errorf("[RemoveFlow] path '%s' does not exist", filePath))
 	}
+	archiveFlowFile(env, cmdPath, filePath, "remove")
 	err = os.Remove(filePath)
 	if err != nil {
 		panic(fmt.Errorf("[RemoveFlow] remove flow file '%s' failed: %v",
@@ -121,12 +124,13 @@ func RemoveAllFlows(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.Parse
 
 	filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
 		if path != root && strings.HasSuffix(path, flowExt) {
+			cmdPath := getCmdPath(path, flowExt)
+			archiveFlowFile(env, cmdPath, path, "remove")
 			err = os.Remove(path)
 			if err != nil {
 				panic(fmt.Errorf("[RemoveAllFlows] remove flow file '%s' failed: %v",
 					path, err))
 			}
-			cmdPath := getCmdPath(path, flowExt)
 			screen.Print(fmt.Sprintf("[%s] (removed)\n", cmdPath))
 			screen.Print(fmt.Sprintf("    %s\n", path))
 		}
@@ -172,20 +176,22 @@ func SaveFlow(
 	w := bytes.NewBuffer(nil)
 	flow.RemoveLeadingCmds(1)
 
-	// TODO: wrap line if too long
 	saveFlow(w, flow, cc.Cmds.Strs.PathSep, env)
-	data := w.String()
+	lines := strings.Split(w.String(), "\n")
 
 	screen.Print(fmt.Sprintf("[%s]\n", cmdPath))
 	screen.Print("    - flow:\n")
-	screen.Print(fmt.Sprintf("        %s\n", data))
+	for _, line := range lines {
+		screen.Print(fmt.Sprintf("        %s\n", line))
+	}
 	screen.Print("    - executable:\n")
 	screen.Print(fmt.Sprintf("        %s\n", filePath))
 
 	dirPath := filepath.Dir(filePath)
 	os.MkdirAll(dirPath, os.ModePerm)
 
-	flow_file.SaveFlowFile(filePath, []string{data}, "", "")
+	archiveFlowFile(env, cmdPath, filePath, "save")
+	flow_file.SaveFlowFile(filePath, lines, "", "", nil, "")
 
 	display.PrintTipTitle(cc.Screen, env,
 		"flow '"+cmdPath+"' is saved, can be used as a command")
@@ -196,8 +202,11 @@ func SaveFlow(
 func SetFlowHelpStr(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
 	help := argv.GetRaw("help-str")
 	cmdPath, filePath := getFlowCmdPath(argv, cc, env, true, "cmd-path", "SetFlowHelpStr")
+	abbrsSep := env.GetRaw("strs.abbrs-sep")
 	flowStrs, oldHelp, abbrsStr := flow_file.LoadFlowFile(filePath)
-	flow_file.SaveFlowFile(filePath, flowStrs, help, abbrsStr)
+	params := flow_file.LoadFlowParams(filePath, abbrsSep)
+	archiveFlowFile(env, cmdPath, filePath, "help")
+	flow_file.UpdateFlowFile(filePath, flowStrs, help, abbrsStr, params, abbrsSep)
 
 	display.PrintTipTitle(cc.Screen, env,
 		"help string of flow '"+cmdPath+"' is saved")
@@ -217,6 +226,77 @@ func SetFlowHelpStr(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.Parse
 	return true
 }
 
+func SetFlowParam(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	names := argv.GetRaw("name")
+	if len(names) == 0 {
+		panic(fmt.Errorf("[SetFlowParam] arg 'name' is empty"))
+	}
+	cmdPath, filePath := getFlowCmdPath(argv, cc, env, true, "cmd-path", "SetFlowParam")
+	abbrsSep := env.GetRaw("strs.abbrs-sep")
+	flowStrs, help, abbrsStr := flow_file.LoadFlowFile(filePath)
+	params := flow_file.LoadFlowParams(filePath, abbrsSep)
+
+	param := flow_file.FlowParam{names, argv.GetRaw("default"), argv.GetRaw("help")}
+	name := param.Name(abbrsSep)
+	found := false
+	for i, it := range params {
+		if it.Name(abbrsSep) == name {
+			// Keep the old abbrs, default and help if not provided
+			if param.Names == name {
+				param.Names = it.Names
+			}
+			if !argv["default"].Provided {
+				param.Default = it.Default
+			}
+			if !argv["help"].Provided {
+				param.Help = it.Help
+			}
+			params[i] = param
+			found = true
+		}
+	}
+	if !found {
+		params = append(params, param)
+	}
+	archiveFlowFile(env, cmdPath, filePath, "param")
+	flow_file.UpdateFlowFile(filePath, flowStrs, help, abbrsStr, params, abbrsSep)
+
+	display.PrintTipTitle(cc.Screen, env,
+		"param '"+name+"' of flow '"+cmdPath+"' is saved, pass it by:",
+		"",
+		"    "+cmdPath+" "+name+"=<value>")
+	printFlowInfo(cc.Screen, cmdPath, filePath, help, flowStrs, params)
+	return true
+}
+
+func RemoveFlowParam(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	name := argv.GetRaw("name")
+	if len(name) == 0 {
+		panic(fmt.Errorf("[RemoveFlowParam] arg 'name' is empty"))
+	}
+	cmdPath, filePath := getFlowCmdPath(argv, cc, env, true, "cmd-path", "RemoveFlowParam")
+	abbrsSep := env.GetRaw("strs.abbrs-sep")
+	flowStrs, help, abbrsStr := flow_file.LoadFlowFile(filePath)
+	params := flow_file.LoadFlowParams(filePath, abbrsSep)
+
+	var rest []flow_file.FlowParam
+	for _, it := range params {
+		if it.Name(abbrsSep) != name {
+			rest = append(rest, it)
+		}
+	}
+	if len(rest) == len(params) {
+		panic(fmt.Errorf("[RemoveFlowParam] flow '%s' has no param '%s'", cmdPath, name))
+	}
+	archiveFlowFile(env, cmdPath, filePath, "param")
+	flow_file.UpdateFlowFile(filePath, flowStrs, help, abbrsStr, rest, abbrsSep)
+
+	display.PrintTipTitle(cc.Screen, env,
+		"param '"+name+"' of flow '"+cmdPath+"' is removed")
+	printFlowInfo(cc.Screen, cmdPath, filePath, help, flowStrs, rest)
+	return true
+}
+
 func LoadFlows(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
 	root := env.GetRaw("sys.paths.flows")
 	if len(root) == 0 {
@@ -267,7 +347,7 @@ func loadFlowsFromDir(root string, cc *core.Cli, env *core.Env, source string) b
 		}
 		cmdPath := filepath.Base(path[0 : len(path)-len(flowExt)])
 		cmdPaths := strings.Split(cmdPath, cc.Cmds.Strs.PathSep)
-		mod_meta.RegMod(cc, path, "", false, true, cmdPaths, cc.Cmds.Strs.AbbrsSep, envPathSep, source)
+		mod_meta.RegMod(cc, path, "", false, true, nil, cmdPaths, cc.Cmds.Strs.AbbrsSep, envPathSep, source, 0)
 		return nil
 	})
 	return true
@@ -291,7 +371,8 @@ func saveFlow(w io.Writer, flow *core.ParsedCmds, cmdPathSep string, env *core.E
 					fmt.Fprint(w, seqSep+" ")
 				}
 			} else {
-				fmt.Fprint(w, " "+seqSep+" ")
+				// One command per line
+				fmt.Fprint(w, "\n"+seqSep+" ")
 			}
 		}
 
@@ -358,6 +439,40 @@ func saveFlowEnv(
 	return true
 }
 
+func printFlowInfo(
+	screen core.Screen,
+	cmdPath string,
+	filePath string,
+	help string,
+	flowStrs []string,
+	params []flow_file.FlowParam) {
+
+	screen.Print(fmt.Sprintf("[%s]\n", cmdPath))
+	if len(help) != 0 {
+		screen.Print(fmt.Sprintf("     '%s'\n", help))
+	}
+	printFlowParams(screen, params)
+	screen.Print("    - flow:\n")
+	for _, flowStr := range flowStrs {
+		screen.Print(fmt.Sprintf("        %s\n", flowStr))
+	}
+	screen.Print("    - executable:\n")
+	screen.Print(fmt.Sprintf("        %s\n", filePath))
+}
+
+func printFlowParams(screen core.Screen, params []flow_file.FlowParam) {
+	if len(params) == 0 {
+		return
+	}
+	screen.Print("    - params:\n")
+	for _, param := range params {
+		screen.Print(fmt.Sprintf("        %s = %s\n", param.Names, param.Default))
+		if len(param.Help) != 0 {
+			screen.Print(fmt.Sprintf("         '%s'\n", param.Help))
+		}
+	}
+}
+
 func getFlowCmdPath(
 	argv core.ArgVals,
 	cc *core.Cli,
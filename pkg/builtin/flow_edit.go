@@ -0,0 +1,254 @@
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+	"github.com/pingcap/ticat/pkg/proto/flow_file"
+	"github.com/pingcap/ticat/pkg/proto/mod_meta"
+	"github.com/pingcap/ticat/pkg/utils"
+)
+
+func EditFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	cmdPath, filePath := getFlowCmdPath(argv, cc, env, true, "cmd-path", "EditFlow")
+	origin, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		panic(fmt.Errorf("[EditFlow] read flow file '%s' failed: %v", filePath, err))
+	}
+
+	editor := strings.TrimSpace(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	c := exec.Command(fields[0], append(fields[1:], filePath)...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err = c.Run(); err != nil {
+		ioutil.WriteFile(filePath, origin, 0644)
+		panic(fmt.Errorf("[EditFlow] run editor '%s' failed, changes are rolled back: %v", editor, err))
+	}
+
+	edited, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		panic(fmt.Errorf("[EditFlow] read flow file '%s' failed: %v", filePath, err))
+	}
+	if bytes.Equal(origin, edited) {
+		display.PrintTipTitle(cc.Screen, env, "flow '"+cmdPath+"' is not changed")
+		return true
+	}
+
+	findings := validateFlowFile(cc, env, cmdPath, filePath)
+	if findings.Count(display.FlowLintError) != 0 {
+		rejected := filePath + ".rejected"
+		ioutil.WriteFile(rejected, edited, 0644)
+		err = ioutil.WriteFile(filePath, origin, 0644)
+		if err != nil {
+			panic(fmt.Errorf("[EditFlow] roll back flow file '%s' failed: %v", filePath, err))
+		}
+		display.DumpFlowLintFindings(cc.Screen, env, findings, 1)
+		display.PrintErrTitle(cc.Screen, env,
+			"flow '"+cmdPath+"' has errors, the changes are rolled back.",
+			"",
+			"the edited content is kept in:",
+			"",
+			"    "+rejected)
+		return false
+	}
+	if findings.Count(display.FlowLintWarn) != 0 {
+		display.DumpFlowLintFindings(cc.Screen, env, findings, 1)
+	}
+	archiveFlowContent(env, cmdPath, origin, "edit")
+
+	abbrsSep := env.GetRaw("strs.abbrs-sep")
+	flowStrs, help, _ := flow_file.LoadFlowFile(filePath)
+	params := flow_file.LoadFlowParams(filePath, abbrsSep)
+	display.PrintTipTitle(cc.Screen, env, "flow '"+cmdPath+"' is saved")
+	printFlowInfo(cc.Screen, cmdPath, filePath, help, flowStrs, params)
+	return true
+}
+
+func RenameFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	return moveFlow(argv, cc, env, false, "RenameFlow")
+}
+
+func CopyFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	return moveFlow(argv, cc, env, true, "CopyFlow")
+}
+
+func moveFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, keepSrc bool, funcName string) bool {
+	srcPath, srcFile := getFlowCmdPath(argv, cc, env, true, "cmd-path", funcName)
+	destPath, destFile := getFlowCmdPath(argv, cc, env, false, "to-cmd-path", funcName)
+	if srcPath == destPath {
+		panic(fmt.Errorf("[%s] the source and the destination are the same: '%s'", funcName, srcPath))
+	}
+	if fileExists(destFile) {
+		cc.Screen.Print(fmt.Sprintf("[confirm] flow file of '%s' exists, "+
+			"type 'y' and press enter to overwrite:\n", destPath))
+		utils.UserConfirm()
+	}
+
+	content, err := ioutil.ReadFile(srcFile)
+	if err != nil {
+		panic(fmt.Errorf("[%s] read flow file '%s' failed: %v", funcName, srcFile, err))
+	}
+	pathSep := cc.Cmds.Strs.PathSep
+	flowStrs, help, abbrs := flow_file.LoadFlowFile(srcFile)
+	newAbbrs := alignFlowAbbrs(abbrs, strings.Split(srcPath, pathSep), strings.Split(destPath, pathSep), pathSep)
+	if newAbbrs != abbrs {
+		content = []byte(replaceFlowAbbrs(string(content), newAbbrs))
+	}
+	if keepSrc {
+		archiveFlowFile(env, destPath, destFile, "copy")
+	} else {
+		archiveFlowFile(env, destPath, destFile, "rename")
+	}
+	err = ioutil.WriteFile(destFile, content, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[%s] write flow file '%s' failed: %v", funcName, destFile, err))
+	}
+	if !keepSrc {
+		archiveFlowFile(env, srcPath, srcFile, "rename")
+		err = os.Remove(srcFile)
+		if err != nil {
+			panic(fmt.Errorf("[%s] remove flow file '%s' failed: %v", funcName, srcFile, err))
+		}
+	}
+
+	params := flow_file.LoadFlowParams(destFile, env.GetRaw("strs.abbrs-sep"))
+	if keepSrc {
+		display.PrintTipTitle(cc.Screen, env, "flow '"+srcPath+"' is copied to '"+destPath+"'")
+	} else {
+		display.PrintTipTitle(cc.Screen, env, "flow '"+srcPath+"' is renamed to '"+destPath+"'")
+	}
+	printFlowInfo(cc.Screen, destPath, destFile, help, flowStrs, params)
+	if newAbbrs != abbrs {
+		cc.Screen.Print("    - abbrs:\n")
+		cc.Screen.Print(fmt.Sprintf("        %s (old: %s)\n", newAbbrs, abbrs))
+	}
+
+	refs := findFlowsReferTo(cc, env, srcPath)
+	if len(refs) == 0 {
+		return true
+	}
+	lines := []interface{}{}
+	if keepSrc {
+		lines = append(lines, "these flows refer to '"+srcPath+"', they don't use the copy:", "")
+	} else {
+		lines = append(lines, "these flows refer to the old path '"+srcPath+"', update them to '"+destPath+"':", "")
+	}
+	for _, ref := range refs {
+		lines = append(lines, "    ["+ref.Owner().DisplayPath()+"]", "        '"+ref.MetaFile()+"'")
+	}
+	if keepSrc {
+		display.PrintTipTitle(cc.Screen, env, lines...)
+	} else {
+		display.PrintErrTitle(cc.Screen, env, lines...)
+	}
+	return true
+}
+
+// Register the flow file to a scratch command tree, then check it like 'flow.lint'
+func validateFlowFile(
+	cc *core.Cli,
+	env *core.Env,
+	cmdPath string,
+	filePath string) (findings display.FlowLintFindings) {
+
+	scratch := *cc
+	scratch.Cmds = core.NewCmdTree(cc.Cmds.Strs)
+	scratch.TolerableErrs = core.NewTolerableErrs()
+	source := env.GetRaw("sys.paths.flows")
+	mod_meta.RegMod(&scratch, filePath, "", false, true, nil, strings.Split(cmdPath, cc.Cmds.Strs.PathSep),
+		cc.Cmds.Strs.AbbrsSep, env.GetRaw("strs.env-path-sep"), source, 0)
+
+	for _, it := range scratch.TolerableErrs.Uncatalogeds {
+		findings = append(findings, display.FlowLintFinding{
+			source,
+			cmdPath,
+			filePath,
+			display.FlowLintError,
+			fmt.Sprintf("%s: %v", it.Reason, it.Err),
+		})
+	}
+	if len(findings) != 0 {
+		return
+	}
+	cic := scratch.Cmds.GetSub(strings.Split(cmdPath, cc.Cmds.Strs.PathSep)...).Cmd()
+	lintFlow(cc, env.Clone(), cic, getConflictedAbbrs(cc), &findings)
+	return
+}
+
+// The abbrs in a flow file are for each segment of the command path,
+// keep the abbrs of the segments which are not changed, drop the others
+func alignFlowAbbrs(abbrs string, srcPath []string, destPath []string, pathSep string) string {
+	if len(abbrs) == 0 {
+		return abbrs
+	}
+	segs := strings.Split(abbrs, pathSep)
+	var aligned []string
+	for i, name := range destPath {
+		if i < len(srcPath) && i < len(segs) && srcPath[i] == name {
+			aligned = append(aligned, segs[i])
+		} else {
+			aligned = append(aligned, "")
+		}
+	}
+	for len(aligned) != 0 && len(aligned[len(aligned)-1]) == 0 {
+		aligned = aligned[:len(aligned)-1]
+	}
+	return strings.Join(aligned, pathSep)
+}
+
+// Replace (or remove) the abbrs line in the global section, keep other content untouched
+func replaceFlowAbbrs(content string, abbrs string) string {
+	lines := strings.Split(content, "\n")
+	var result []string
+	global := true
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			global = false
+		}
+		if global {
+			key := strings.TrimSpace(strings.SplitN(trimmed, "=", 2)[0])
+			if strings.Contains(trimmed, "=") && (key == "abbrs" || key == "abbr") {
+				global = false
+				if len(abbrs) != 0 {
+					result = append(result, "abbrs = "+abbrs)
+				}
+				continue
+			}
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// The loaded flows which call the command
+func findFlowsReferTo(cc *core.Cli, env *core.Env, cmdPath string) (refs []*core.Cmd) {
+	node := cc.Cmds.GetSub(strings.Split(cmdPath, cc.Cmds.Strs.PathSep)...)
+	if node == nil || node.Cmd() == nil {
+		return
+	}
+	target := node.Cmd()
+	for _, cic := range collectFlowsToLint("all", cc, env) {
+		if cic == target {
+			continue
+		}
+		for _, ref := range cic.FlowRefs(cc, env) {
+			if ref == target {
+				refs = append(refs, cic)
+				break
+			}
+		}
+	}
+	return
+}
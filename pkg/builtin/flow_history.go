@@ -0,0 +1,259 @@
+package builtin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+	"github.com/pingcap/ticat/pkg/proto/flow_file"
+	meta "github.com/pingcap/ticat/pkg/proto/hub_meta"
+)
+
+func FlowHistory(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	cmdPath, filePath, dir := getFlowHistoryPaths(argv, cc, env, "FlowHistory")
+	versions := flow_file.ListFlowVersions(dir, env.GetRaw("strs.flow-ext"))
+	exists := fileExists(filePath)
+	if len(versions) == 0 {
+		if !exists {
+			panic(fmt.Errorf("[FlowHistory] flow '%s' file '%s' not exists, and it has no history",
+				cmdPath, filePath))
+		}
+		display.PrintTipTitle(cc.Screen, env,
+			"flow '"+cmdPath+"' has no old versions.",
+			"",
+			"a version is kept each time the flow is saved, edited or changed.")
+		return true
+	}
+
+	selfName := env.GetRaw("strs.self-name")
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("flow '%s' has %d old versions, inspect or restore one by:", cmdPath, len(versions)),
+		"",
+		"    "+selfName+" flow.diff "+cmdPath+" <ver>",
+		"    "+selfName+" flow.revert "+cmdPath+" <ver>")
+
+	cc.Screen.Print(fmt.Sprintf("[%s]\n", cmdPath))
+	cc.Screen.Print("    - executable:\n")
+	if exists {
+		cc.Screen.Print(fmt.Sprintf("        %s\n", filePath))
+	} else {
+		cc.Screen.Print(fmt.Sprintf("        %s (removed)\n", filePath))
+	}
+	cc.Screen.Print("    - versions:\n")
+	for i := len(versions) - 1; i >= 0; i-- {
+		ver := versions[i]
+		cc.Screen.Print(fmt.Sprintf("        #%d %s (replaced by: %s)\n",
+			ver.Ver, ver.Time.Format("2006-01-02 15:04:05"), ver.Action))
+		flowStrs, help, _ := flow_file.LoadFlowFile(ver.Path)
+		if len(help) != 0 {
+			cc.Screen.Print(fmt.Sprintf("             '%s'\n", help))
+		}
+		for _, flowStr := range flowStrs {
+			cc.Screen.Print(fmt.Sprintf("            %s\n", flowStr))
+		}
+	}
+	return true
+}
+
+// Show the changes from an old version to the current flow file (or to another version)
+func DiffFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	cmdPath, filePath, dir := getFlowHistoryPaths(argv, cc, env, "DiffFlow")
+	flowExt := env.GetRaw("strs.flow-ext")
+
+	from := getFlowVersion(argv, "ver", dir, flowExt, cmdPath, "DiffFlow")
+	fromName := fmt.Sprintf("%s #%d", cmdPath, from.Ver)
+	fromContent := readFlowVersion(from.Path, "DiffFlow")
+
+	var toName string
+	var toContent []byte
+	if len(strings.TrimSpace(argv.GetRaw("to-ver"))) == 0 {
+		toName = cmdPath + " (current)"
+		if fileExists(filePath) {
+			toContent = readFlowVersion(filePath, "DiffFlow")
+		} else {
+			toName = cmdPath + " (removed)"
+		}
+	} else {
+		to := getFlowVersion(argv, "to-ver", dir, flowExt, cmdPath, "DiffFlow")
+		toName = fmt.Sprintf("%s #%d", cmdPath, to.Ver)
+		toContent = readFlowVersion(to.Path, "DiffFlow")
+	}
+
+	diff := diffLines(splitFlowLines(fromContent), splitFlowLines(toContent), 2)
+	if len(diff) == 0 {
+		display.PrintTipTitle(cc.Screen, env,
+			"no differences between '"+fromName+"' and '"+toName+"'")
+		return true
+	}
+	cc.Screen.Print(fmt.Sprintf("--- %s\n", fromName))
+	cc.Screen.Print(fmt.Sprintf("+++ %s\n", toName))
+	for _, line := range diff {
+		cc.Screen.Print(line + "\n")
+	}
+	return true
+}
+
+// The current flow file is kept as a new version before being replaced, so a revert could be reverted too
+func RevertFlow(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	cmdPath, filePath, dir := getFlowHistoryPaths(argv, cc, env, "RevertFlow")
+	flowExt := env.GetRaw("strs.flow-ext")
+	ver := getFlowVersion(argv, "ver", dir, flowExt, cmdPath, "RevertFlow")
+	content := readFlowVersion(ver.Path, "RevertFlow")
+
+	if fileExists(filePath) {
+		current := readFlowVersion(filePath, "RevertFlow")
+		if string(current) == string(content) {
+			display.PrintTipTitle(cc.Screen, env,
+				fmt.Sprintf("flow '%s' is the same as version #%d, nothing to do.", cmdPath, ver.Ver))
+			return true
+		}
+		archiveFlowContent(env, cmdPath, current, "revert")
+	}
+
+	os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	err := ioutil.WriteFile(filePath, content, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[RevertFlow] write flow file '%s' failed: %v", filePath, err))
+	}
+
+	flowStrs, help, _ := flow_file.LoadFlowFile(filePath)
+	params := flow_file.LoadFlowParams(filePath, env.GetRaw("strs.abbrs-sep"))
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("flow '%s' is reverted to version #%d", cmdPath, ver.Ver))
+	printFlowInfo(cc.Screen, cmdPath, filePath, help, flowStrs, params)
+	return true
+}
+
+// Keep the current content of a flow file as an old version before it's changed or removed,
+// do nothing if the file doesn't exist
+func archiveFlowFile(env *core.Env, cmdPath string, filePath string, action string) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	archiveFlowContent(env, cmdPath, content, action)
+}
+
+func archiveFlowContent(env *core.Env, cmdPath string, content []byte, action string) {
+	dir := getFlowHistoryDir(env, cmdPath)
+	if len(dir) == 0 {
+		return
+	}
+	flow_file.ArchiveFlowContent(dir, env.GetRaw("strs.flow-ext"), content, action)
+}
+
+// The history of a flow is in a hidden dir next to the flows dir, one sub dir for each flow
+func getFlowHistoryDir(env *core.Env, cmdPath string) string {
+	root := env.GetRaw("sys.paths.flows-history")
+	if len(root) == 0 {
+		return ""
+	}
+	return filepath.Join(root, cmdPath)
+}
+
+// Unlike 'getFlowCmdPath', the flow file may not exist, it could be removed but still has history
+func getFlowHistoryPaths(
+	argv core.ArgVals,
+	cc *core.Cli,
+	env *core.Env,
+	funcName string) (cmdPath string, filePath string, dir string) {
+
+	cmdPath = normalizeCmdPath(argv.GetRaw("cmd-path"),
+		cc.Cmds.Strs.PathSep, cc.Cmds.Strs.PathAlterSeps)
+	if len(cmdPath) == 0 {
+		panic(fmt.Errorf("[%s] arg 'cmd-path' is empty", funcName))
+	}
+	root := env.GetRaw("sys.paths.flows")
+	if len(root) == 0 {
+		panic(fmt.Errorf("[%s] env 'sys.paths.flows' is empty", funcName))
+	}
+	dir = getFlowHistoryDir(env, cmdPath)
+	if len(dir) == 0 {
+		panic(fmt.Errorf("[%s] env 'sys.paths.flows-history' is empty", funcName))
+	}
+	filePath = filepath.Join(root, cmdPath) + env.GetRaw("strs.flow-ext")
+	return
+}
+
+func getFlowVersion(
+	argv core.ArgVals,
+	argName string,
+	dir string,
+	flowExt string,
+	cmdPath string,
+	funcName string) flow_file.FlowVersion {
+
+	raw := strings.TrimPrefix(strings.TrimSpace(argv.GetRaw(argName)), "#")
+	if len(raw) == 0 {
+		panic(fmt.Errorf("[%s] arg '%s' is empty", funcName, argName))
+	}
+	ver, err := strconv.Atoi(raw)
+	if err != nil {
+		panic(fmt.Errorf("[%s] arg '%s' = '%s' is not a version number", funcName, argName, raw))
+	}
+	version, ok := flow_file.GetFlowVersion(dir, flowExt, ver)
+	if !ok {
+		panic(fmt.Errorf("[%s] flow '%s' has no version #%d, list versions by 'flow.history %s'",
+			funcName, cmdPath, ver, cmdPath))
+	}
+	return version
+}
+
+func readFlowVersion(path string, funcName string) []byte {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf("[%s] read flow file '%s' failed: %v", funcName, path, err))
+	}
+	return content
+}
+
+func splitFlowLines(content []byte) []string {
+	text := strings.TrimRight(string(content), "\n")
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// The unchanged lines more than 'context' away from changes are omitted
+func diffLines(from []string, to []string, context int) (result []string) {
+	lines := meta.DiffLines(from, to)
+	keep := make([]bool, len(lines))
+	changed := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "  ") {
+			continue
+		}
+		changed = true
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	omitted := false
+	for i, line := range lines {
+		if !keep[i] {
+			omitted = true
+			continue
+		}
+		if omitted {
+			result = append(result, "...")
+			omitted = false
+		}
+		result = append(result, line)
+	}
+	if omitted {
+		result = append(result, "...")
+	}
+	return
+}
@@ -0,0 +1,298 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+)
+
+// Check flows without running anything, return false if there are errors (or warnings in strict mode)
+func LintFlows(argv core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) bool {
+	path := strings.TrimSpace(argv.GetRaw("path"))
+	strict := argv.GetBool("strict")
+	env = env.Clone()
+
+	flows := collectFlowsToLint(path, cc, env)
+	findings := display.FlowLintFindings{}
+	if len(path) == 0 || path == "all" {
+		lintFlowLoadingErrs(cc, env, &findings)
+	}
+
+	conflictedAbbrs := getConflictedAbbrs(cc)
+	for _, cic := range flows {
+		lintFlow(cc, env, cic, conflictedAbbrs, &findings)
+	}
+
+	display.DumpFlowLintFindings(cc.Screen, env, findings, len(flows))
+	if findings.Count(display.FlowLintError) != 0 {
+		return false
+	}
+	if strict && findings.Count(display.FlowLintWarn) != 0 {
+		return false
+	}
+	return true
+}
+
+// The path could be 'all' (or empty), a command path, or a file/dir path of flows
+func collectFlowsToLint(path string, cc *core.Cli, env *core.Env) (flows []*core.Cmd) {
+	var collect func(node *core.CmdTree)
+	collect = func(node *core.CmdTree) {
+		if cic := node.Cmd(); cic != nil && cic.Type() == core.CmdTypeFlow {
+			flows = append(flows, cic)
+		}
+		for _, name := range node.SubNames() {
+			collect(node.GetSub(name))
+		}
+	}
+
+	if len(path) == 0 || path == "all" {
+		collect(cc.Cmds)
+		return
+	}
+
+	cmdPath := normalizeCmdPath(path, cc.Cmds.Strs.PathSep, cc.Cmds.Strs.PathAlterSeps)
+	if len(cmdPath) != 0 {
+		node := cc.Cmds.GetSub(strings.Split(cmdPath, cc.Cmds.Strs.PathSep)...)
+		if node != nil {
+			collect(node)
+			return
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil || !fileOrDirExists(absPath) {
+		panic(fmt.Errorf("[LintFlows] '%s' is not a command path or a file path", path))
+	}
+	collect(cc.Cmds)
+	var matched []*core.Cmd
+	for _, cic := range flows {
+		file := cic.MetaFile()
+		if file == absPath || strings.HasPrefix(file, absPath+string(filepath.Separator)) {
+			matched = append(matched, cic)
+		}
+	}
+	return matched
+}
+
+// The flows failed to load are not in the command tree, report them from the loading errors
+func lintFlowLoadingErrs(cc *core.Cli, env *core.Env, findings *display.FlowLintFindings) {
+	flowExt := env.GetRaw("strs.flow-ext")
+	for _, it := range cc.TolerableErrs.Uncatalogeds {
+		if !strings.HasSuffix(it.File, flowExt) {
+			continue
+		}
+		*findings = append(*findings, display.FlowLintFinding{
+			it.Source,
+			strings.TrimSuffix(filepath.Base(it.File), flowExt),
+			it.File,
+			display.FlowLintError,
+			fmt.Sprintf("%s: %v", it.Reason, it.Err),
+		})
+	}
+}
+
+// The abbrs declared by more than one command under the same parent, only the first one takes effect
+type conflictedAbbr struct {
+	old    string
+	new    string
+	source string
+}
+
+func getConflictedAbbrs(cc *core.Cli) map[string]conflictedAbbr {
+	abbrs := map[string]conflictedAbbr{}
+	add := func(errs core.ConflictedWithSameSource) {
+		for source, list := range errs {
+			for _, it := range list {
+				err, ok := it.Err.(core.CmdTreeErrSubAbbrConflicted)
+				if !ok {
+					continue
+				}
+				key := strings.Join(append(err.ParentCmdPath, err.Abbr), cc.Cmds.Strs.PathSep)
+				abbrs[key] = conflictedAbbr{err.ForOldCmdName, err.ForNewCmdName, source}
+			}
+		}
+	}
+	add(cc.TolerableErrs.ConflictedWithBuiltin)
+	for _, errs := range cc.TolerableErrs.Conflicteds {
+		add(errs)
+	}
+	return abbrs
+}
+
+func lintFlow(
+	cc *core.Cli,
+	env *core.Env,
+	cic *core.Cmd,
+	conflictedAbbrs map[string]conflictedAbbr,
+	findings *display.FlowLintFindings) {
+
+	defer func() {
+		if err := recover(); err != nil {
+			findings.Add(cic, display.FlowLintError, "check failed: %v", err)
+		}
+	}()
+
+	sep := cc.Cmds.Strs.PathSep
+	strs := cc.Cmds.Strs
+
+	argv := core.ArgVals{}
+	args := cic.Args()
+	for _, name := range args.Names() {
+		argv[name] = core.ArgVal{args.DefVal(name), false}
+	}
+	lookup := func(key string) (string, bool) {
+		if val, ok := argv[key]; ok && len(val.Raw) != 0 {
+			return val.Raw, true
+		}
+		val, ok := env.GetEx(key)
+		return val.Raw, ok
+	}
+
+	missedKeys := core.TemplateMissedKeys(strings.Join(cic.FlowStrs(), "\n"),
+		strs.FlowTemplateBracketLeft, strs.FlowTemplateBracketRight, strs.FlowTemplateDefaultSep, lookup)
+	for _, key := range missedKeys {
+		if args.Has(key) {
+			findings.Add(cic, display.FlowLintInfo,
+				"param '%s' has no default value, it should be passed when calling", key)
+		} else {
+			findings.Add(cic, display.FlowLintWarn,
+				"template key '%s' has no value in env and no default value", key)
+		}
+	}
+	if len(missedKeys) != 0 {
+		// Fill the missed keys with placeholders, so the rest checks could go on
+		for _, key := range missedKeys {
+			argv[key] = core.ArgVal{key, true}
+		}
+	}
+
+	subFlow, rendered := cic.Flow(env, argv, true)
+	if !rendered || len(subFlow) == 0 {
+		if len(cic.FlowStrs()) == 0 {
+			findings.Add(cic, display.FlowLintWarn, "the flow is empty")
+		}
+		return
+	}
+
+	parsedFlow := cic.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
+	hasParseErr := false
+	for _, cmd := range parsedFlow.Cmds {
+		if cmd.ParseResult.Error != nil {
+			hasParseErr = true
+			findings.Add(cic, display.FlowLintError, "dead reference '%s': %v",
+				strings.Join(cmd.ParseResult.Input, " "), cmd.ParseResult.Error)
+			continue
+		}
+		lintFlowCmdAbbrs(cic, cmd, sep, conflictedAbbrs, findings)
+	}
+	if hasParseErr {
+		return
+	}
+
+	flowEnv := env
+	if parsedFlow.GlobalEnv != nil {
+		flowEnv = env.Clone().GetOrNewLayer(core.EnvLayerTmp)
+		parsedFlow.GlobalEnv.WriteNotArgTo(flowEnv, strs.EnvValDelAllMark)
+	}
+	lintFlowEnvOps(cc, flowEnv, cic, parsedFlow, findings)
+	lintFlowDepends(cc, flowEnv, cic, parsedFlow, findings)
+}
+
+func lintFlowCmdAbbrs(
+	cic *core.Cmd,
+	cmd core.ParsedCmd,
+	sep string,
+	conflictedAbbrs map[string]conflictedAbbr,
+	findings *display.FlowLintFindings) {
+
+	var parent []string
+	for _, seg := range cmd.Segments {
+		if seg.Matched.Cmd == nil {
+			continue
+		}
+		key := strings.Join(append(parent, seg.Matched.Name), sep)
+		if conflicted, ok := conflictedAbbrs[key]; ok {
+			findings.Add(cic, display.FlowLintWarn,
+				"abbr '%s' in '%s' is ambiguous, it resolves to '%s' but is also declared for '%s' by '%s'",
+				seg.Matched.Name, cmd.DisplayPath(sep, false), conflicted.old, conflicted.new, conflicted.source)
+		}
+		parent = append(parent, seg.Matched.Cmd.Name())
+	}
+}
+
+func lintFlowEnvOps(
+	cc *core.Cli,
+	env *core.Env,
+	cic *core.Cmd,
+	parsedFlow *core.ParsedCmds,
+	findings *display.FlowLintFindings) {
+
+	checker := core.EnvOpsChecker{}
+	result := []core.EnvOpsCheckResult{}
+	core.CheckEnvOps(cc, parsedFlow, env, &checker, false, &result)
+
+	met := map[string]bool{}
+	for _, it := range result {
+		id := it.CmdDisplayPath + "\n" + it.Key
+		if met[id] {
+			continue
+		}
+		met[id] = true
+		if it.ReadNotExist {
+			findings.Add(cic, display.FlowLintWarn,
+				"'%s' reads env key '%s', but no command writes it before", it.CmdDisplayPath, it.Key)
+		} else if it.MayReadNotExist {
+			findings.Add(cic, display.FlowLintInfo,
+				"'%s' may read env key '%s', but no command writes it before", it.CmdDisplayPath, it.Key)
+		} else {
+			findings.Add(cic, display.FlowLintInfo,
+				"'%s' reads env key '%s', which may not be written before", it.CmdDisplayPath, it.Key)
+		}
+	}
+
+	var keys []string
+	for key, _ := range checker {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	written := core.EnvOpTypeWrite | core.EnvOpTypeMayWrite
+	read := core.EnvOpTypeRead | core.EnvOpTypeMayRead
+	for _, key := range keys {
+		ops := checker.KeyOps(key)
+		if (ops&written) != 0 && (ops&read) == 0 {
+			findings.Add(cic, display.FlowLintInfo, "env key '%s' is written but never read in this flow", key)
+		}
+	}
+}
+
+func lintFlowDepends(
+	cc *core.Cli,
+	env *core.Env,
+	cic *core.Cmd,
+	parsedFlow *core.ParsedCmds,
+	findings *display.FlowLintFindings) {
+
+	deps := display.Depends{}
+	display.CollectDepends(cc, env, parsedFlow.Cmds, deps, true)
+	var osCmds []string
+	for osCmd, _ := range deps {
+		osCmds = append(osCmds, osCmd)
+	}
+	sort.Strings(osCmds)
+	for _, osCmd := range osCmds {
+		if path, err := exec.LookPath(osCmd); err != nil || len(path) == 0 {
+			findings.Add(cic, display.FlowLintWarn, "depended os-command '%s' is not installed", osCmd)
+		}
+	}
+}
+
+func fileOrDirExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,184 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+	"github.com/pingcap/ticat/pkg/proto/flow_file"
+	"github.com/pingcap/ticat/pkg/utils"
+)
+
+// The draft is in the session dir, so only the invocations attached to this session are recorded
+func StartFlowRecord(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	cmdPath, _ := getFlowCmdPath(argv, cc, env, false, "to-cmd-path", "StartFlowRecord")
+	draftPath := getFlowRecordPath(env)
+	if len(draftPath) == 0 {
+		panic(core.NewCmdError(cmd, "env 'session' or 'strs.flow-record-file' is empty"))
+	}
+	if fileExists(draftPath) {
+		recording, flow := flow_file.LoadFlowRecord(draftPath)
+		panic(core.NewCmdError(cmd, fmt.Sprintf("flow '%s' is being recorded (%d commands), "+
+			"stop it by 'flow.record.stop' first", recording, len(flow))))
+	}
+	os.MkdirAll(filepath.Dir(draftPath), os.ModePerm)
+	flow_file.SaveFlowRecord(draftPath, cmdPath, nil)
+
+	selfName := env.GetRaw("strs.self-name")
+	sessionId := filepath.Base(env.GetRaw("session"))
+	display.PrintTipTitle(cc.Screen, env,
+		"recording flow '"+cmdPath+"' in session '"+sessionId+"',",
+		"each succeeded command sequence in this session will be appended to it.",
+		"",
+		"run commands in this session by:",
+		"",
+		"    "+selfName+" session.attach "+sessionId+" : <command>",
+		"",
+		"stop recording and save the flow by:",
+		"",
+		"    "+selfName+" session.attach "+sessionId+" : flow.record.stop")
+	return true
+}
+
+// The commands before this one in the same sequence are already executed, record them too
+func StopFlowRecord(
+	argv core.ArgVals,
+	cc *core.Cli,
+	env *core.Env,
+	flow *core.ParsedCmds,
+	currCmdIdx int) (int, bool) {
+
+	draftPath := getFlowRecordPath(env)
+	if len(draftPath) == 0 || !fileExists(draftPath) {
+		panic(fmt.Errorf("[StopFlowRecord] no flow is being recorded in this session, " +
+			"start by 'flow.record.start <command-path>'"))
+	}
+	executed := *flow
+	executed.Cmds = flow.Cmds[:currCmdIdx]
+	AppendFlowRecord(cc, &executed, env)
+
+	cmdPath, lines := flow_file.LoadFlowRecord(draftPath)
+	if len(lines) == 0 {
+		os.Remove(draftPath)
+		display.PrintTipTitle(cc.Screen, env,
+			"recording stopped, nothing recorded for flow '"+cmdPath+"'.")
+		return currCmdIdx, true
+	}
+
+	root := env.GetRaw("sys.paths.flows")
+	if len(root) == 0 {
+		panic(fmt.Errorf("[StopFlowRecord] env 'sys.paths.flows' is empty"))
+	}
+	filePath := filepath.Join(root, cmdPath) + env.GetRaw("strs.flow-ext")
+	if fileExists(filePath) {
+		if !env.GetBool("sys.interact") {
+			panic(fmt.Errorf("[StopFlowRecord] flow '%s' file '%s' exists", cmdPath, filePath))
+		}
+		cc.Screen.Print(fmt.Sprintf("[confirm] flow file of '%s' exists, "+
+			"type 'y' and press enter to overwrite:\n", cmdPath))
+		utils.UserConfirm()
+	}
+
+	os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	archiveFlowFile(env, cmdPath, filePath, "record")
+	flow_file.SaveFlowFile(filePath, lines, "", "", nil, "")
+	err := os.Remove(draftPath)
+	if err != nil {
+		panic(fmt.Errorf("[StopFlowRecord] remove draft file '%s' failed: %v", draftPath, err))
+	}
+
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("recording stopped, flow '%s' is saved with %d commands.", cmdPath, len(lines)),
+		"",
+		"review the flow below, edit it by:",
+		"",
+		"    "+env.GetRaw("strs.self-name")+" flow.edit "+cmdPath)
+	printFlowInfo(cc.Screen, cmdPath, filePath, "", lines, nil)
+	return currCmdIdx, true
+}
+
+// Append a succeeded top-level flow to the draft, do nothing if it's not recording.
+// The flow should be the one before executing, it could be modified when executing
+func AppendFlowRecord(cc *core.Cli, flow *core.ParsedCmds, env *core.Env) bool {
+	draftPath := getFlowRecordPath(env)
+	if len(draftPath) == 0 || !fileExists(draftPath) {
+		return true
+	}
+	cmdPath, lines := flow_file.LoadFlowRecord(draftPath)
+	added := flowRecordLines(env, flow, len(lines) == 0)
+	if len(added) == 0 {
+		return true
+	}
+	flow_file.SaveFlowRecord(draftPath, cmdPath, append(lines, added...))
+	return true
+}
+
+// Convert a top-level command sequence to the lines of the recording flow, one command per line
+// as 'flow.save' does, the first line of the flow has no leading sequence separator.
+// The global env is bound to each command, so it stays in the scope of this sequence
+// when the lines are joined together.
+// The sequences with power commands (eg: 'desc', 'flow.save') are not recorded.
+func flowRecordLines(env *core.Env, flow *core.ParsedCmds, isFirst bool) (lines []string) {
+	seqSep := env.GetRaw("strs.seq-sep")
+	bracketLeft := env.GetRaw("strs.env-bracket-left")
+	bracketRight := env.GetRaw("strs.env-bracket-right")
+	envKeyValSep := env.GetRaw("strs.env-kv-sep")
+	if len(seqSep) == 0 || len(bracketLeft) == 0 || len(bracketRight) == 0 || len(envKeyValSep) == 0 {
+		return nil
+	}
+
+	var globalEnv string
+	if flow.GlobalCmdIdx >= 0 {
+		var kvs []string
+		for k, v := range flow.GlobalEnv {
+			if !v.IsArg {
+				kvs = append(kvs, k+envKeyValSep+quoteIfHasSpace(v.Val))
+			}
+		}
+		sort.Strings(kvs)
+		if len(kvs) != 0 {
+			globalEnv = bracketLeft + strings.Join(kvs, " ") + bracketRight
+		}
+	}
+
+	var cmds []string
+	for i, cmd := range flow.Cmds {
+		last := cmd.LastCmd()
+		if last == nil || last.IsTheSameFunc(StartFlowRecord) || last.IsTheSameFunc(StopFlowRecord) ||
+			last.IsTheSameFunc(AttachSession) {
+			continue
+		}
+		if last.IsPowerCmd() {
+			return nil
+		}
+		var tokens []string
+		if len(globalEnv) != 0 && i != flow.GlobalCmdIdx {
+			tokens = append(tokens, globalEnv)
+		}
+		for _, it := range cmd.ParseResult.Input {
+			tokens = append(tokens, quoteIfHasSpace(it))
+		}
+		cmds = append(cmds, strings.Join(tokens, " "))
+	}
+	for i, it := range cmds {
+		if i == 0 && isFirst {
+			lines = append(lines, it)
+		} else {
+			lines = append(lines, seqSep+" "+it)
+		}
+	}
+	return
+}
+
+func getFlowRecordPath(env *core.Env) string {
+	sessionDir := env.GetRaw("session")
+	name := env.GetRaw("strs.flow-record-file")
+	if len(sessionDir) == 0 || len(name) == 0 {
+		return ""
+	}
+	return filepath.Join(sessionDir, name)
+}
@@ -0,0 +1,369 @@
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-shellwords"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+	"github.com/pingcap/ticat/pkg/proto/flow_file"
+	meta "github.com/pingcap/ticat/pkg/proto/hub_meta"
+)
+
+// Run the flow tests (test files and '@selftest' commands), return false if any test failed
+func RunFlowTests(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	path := strings.TrimSpace(argv.GetRaw("path"))
+	junitPath := strings.TrimSpace(argv.GetRaw("junit"))
+
+	tests := collectFlowTests(path, argv.GetBool("selftest"), cc, env, cmd)
+	if len(tests) == 0 {
+		display.PrintTipTitle(cc.Screen, env,
+			"no flow tests found.",
+			"",
+			"tests are files with ext '"+env.GetRaw("strs.flow-test-ext")+"' in repos,",
+			"and commands with tag '"+env.GetRaw("strs.tag-self-test")+"' in help string.")
+		return true
+	}
+
+	var results []display.FlowTestResult
+	for i, test := range tests {
+		result := runFlowTest(cc, env, test)
+		display.PrintFlowTestResult(cc.Screen, result, i == 0 || tests[i-1].suite != test.suite)
+		results = append(results, result)
+	}
+	if len(junitPath) != 0 {
+		flow_file.SaveJUnitReport(junitPath, genJUnitReport(results))
+	}
+	display.DumpFlowTestSummary(cc.Screen, env, results, junitPath)
+
+	for _, it := range results {
+		if !it.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// A test from a test file, or a command with tag '@selftest'
+type flowTest struct {
+	suite string
+	name  string
+	file  string
+	spec  *flow_file.FlowTest
+	cmd   *core.Cmd
+}
+
+// The path could be 'all' (or empty), or a file/dir path of tests
+func collectFlowTests(
+	path string,
+	withSelfTests bool,
+	cc *core.Cli,
+	env *core.Env,
+	cmd core.ParsedCmd) (tests []flowTest) {
+
+	type testRoot struct {
+		dir    string
+		source string
+	}
+	var roots []testRoot
+
+	if len(path) == 0 || path == "all" {
+		flowsDir := env.GetRaw("sys.paths.flows")
+		if len(flowsDir) != 0 {
+			roots = append(roots, testRoot{flowsDir, flowsDir})
+		}
+		infos, _ := meta.ReadReposInfoFile(getReposInfoPath(env, cmd), true, env.GetRaw("strs.proto-sep"))
+		for _, info := range meta.SortReposByPriority(infos) {
+			if info.OnOff != "on" {
+				continue
+			}
+			source := info.Addr
+			if len(source) == 0 {
+				source = info.Path
+			}
+			roots = append(roots, testRoot{info.Path, source})
+		}
+	} else {
+		absPath, err := filepath.Abs(path)
+		if err != nil || !fileOrDirExists(absPath) {
+			panic(core.NewCmdError(cmd, fmt.Sprintf("'%s' is not a file or dir path", path)))
+		}
+		roots = append(roots, testRoot{absPath, absPath})
+	}
+
+	testExt := env.GetRaw("strs.flow-test-ext")
+	seqSep := env.GetRaw("strs.seq-sep")
+	for _, root := range roots {
+		filepath.Walk(root.dir, func(file string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				base := filepath.Base(file)
+				if file != root.dir && len(base) > 0 && base[0] == '.' {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(file, testExt) {
+				return nil
+			}
+			name := strings.TrimSuffix(filepath.Base(file), testExt)
+			if rel, err := filepath.Rel(root.dir, file); err == nil && rel != "." {
+				name = strings.TrimSuffix(rel, testExt)
+			}
+			spec := flow_file.LoadFlowTest(file, seqSep)
+			tests = append(tests, flowTest{root.source, name, file, &spec, nil})
+			return nil
+		})
+	}
+
+	if withSelfTests {
+		tests = append(tests, collectSelfTests(cc, env, path)...)
+	}
+	sort.SliceStable(tests, func(i, j int) bool {
+		return tests[i].suite < tests[j].suite
+	})
+	return
+}
+
+func collectSelfTests(cc *core.Cli, env *core.Env, path string) (tests []flowTest) {
+	tag := env.GetRaw("strs.tag-self-test")
+	if len(tag) == 0 {
+		return
+	}
+	var absPath string
+	if len(path) != 0 && path != "all" {
+		absPath, _ = filepath.Abs(path)
+	}
+	var collect func(node *core.CmdTree)
+	collect = func(node *core.CmdTree) {
+		if cic := node.Cmd(); cic != nil && strings.Index(cic.Help(), tag) >= 0 && !cic.IsTheSameFunc(RunFlowTests) {
+			file := cic.MetaFile()
+			if len(absPath) == 0 || file == absPath || strings.HasPrefix(file, absPath+string(filepath.Separator)) {
+				tests = append(tests, flowTest{cic.Source(), node.DisplayPath(), file, nil, cic})
+			}
+		}
+		for _, name := range node.SubNames() {
+			collect(node.GetSub(name))
+		}
+	}
+	collect(cc.Cmds)
+	return
+}
+
+// Run a test in a cloned env and a temporary session dir, the output is captured
+func runFlowTest(cc *core.Cli, env *core.Env, test flowTest) (result display.FlowTestResult) {
+	result = display.FlowTestResult{test.suite, test.name, test.file, nil, "", 0}
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Now().Sub(start)
+	}()
+
+	fail := func(format string, a ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, a...))
+	}
+
+	inner := *cc
+	inner.GlobalEnv = cc.GlobalEnv.Clone()
+	screen := &flowTestScreen{}
+	inner.Screen = screen
+	hooks := core.NewTestHooks()
+	inner.TestHooks = hooks
+
+	sessionEnv := inner.GlobalEnv.GetLayer(core.EnvLayerSession)
+	sessionEnv.SetBool("display.executor", false)
+	sessionEnv.SetBool("sys.interact", false)
+	sessionDir, err := ioutil.TempDir("", "ticat-test-")
+	if err != nil {
+		fail("create temporary session dir failed: %v", err)
+		return
+	}
+	defer os.RemoveAll(sessionDir)
+	sessionEnv.Set("session", sessionDir)
+
+	var input []string
+	expectSucceeded := true
+	if test.spec != nil {
+		spec := test.spec
+		for _, it := range spec.Env {
+			sessionEnv.Set(it.Key, it.Val)
+		}
+		for _, path := range spec.MockedCmdPaths() {
+			cmdPath := normalizeCmdPath(path, cc.Cmds.Strs.PathSep, cc.Cmds.Strs.PathAlterSeps)
+			node := cc.Cmds.GetSub(strings.Split(cmdPath, cc.Cmds.Strs.PathSep)...)
+			if node == nil || node.Cmd() == nil {
+				fail("mocked command '%s' not found", path)
+				continue
+			}
+			mockEnv := map[string]string{}
+			for _, it := range spec.Mocks[path] {
+				mockEnv[it.Key] = it.Val
+			}
+			hooks.Mocks[node.Cmd()] = core.CmdMock{mockEnv}
+		}
+		if len(result.Failures) != 0 {
+			return
+		}
+		input, err = shellwords.Parse(strings.Join(spec.Flow, " "))
+		if err != nil {
+			fail("parse flow '%s' failed: %v", strings.Join(spec.Flow, " "), err)
+			return
+		}
+		if len(input) == 0 {
+			fail("the flow to test is empty")
+			return
+		}
+		expectSucceeded = spec.Succeeded
+	} else {
+		input = []string{test.name}
+	}
+
+	succeeded := executeFlowTest(&inner, input, fail)
+	result.Output = screen.String()
+	if succeeded != expectSucceeded {
+		if expectSucceeded {
+			fail("the flow failed")
+		} else {
+			fail("the flow succeeded, but expected to fail")
+		}
+	}
+	if test.spec == nil {
+		return
+	}
+
+	for _, it := range test.spec.ExpectEnv {
+		val, ok := inner.GlobalEnv.GetEx(it.Key)
+		if !ok {
+			fail("env '%s' not found, expected '%s'", it.Key, it.Val)
+		} else if val.Raw != it.Val {
+			fail("env '%s' is '%s', expected '%s'", it.Key, val.Raw, it.Val)
+		}
+	}
+	checkFlowTestCmds(cc, test.spec.Cmds, hooks.Executed, fail)
+	for _, pattern := range test.spec.Output {
+		re, err := regexp.Compile("(?m)" + pattern)
+		if err != nil {
+			fail("bad output pattern '%s': %v", pattern, err)
+		} else if !re.MatchString(result.Output) {
+			fail("output doesn't match '%s'", pattern)
+		}
+	}
+	return
+}
+
+func executeFlowTest(cc *core.Cli, input []string, fail func(string, ...interface{})) (succeeded bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			succeeded = false
+			fail("%v", err)
+		}
+	}()
+	return cc.Executor.Execute(cc, input...)
+}
+
+// The expected commands should be executed in order, other commands could be in between
+func checkFlowTestCmds(cc *core.Cli, expected []string, executed []string, fail func(string, ...interface{})) {
+	i := 0
+	for _, it := range executed {
+		if i >= len(expected) {
+			break
+		}
+		if it == flowTestCmdDisplayPath(cc, expected[i]) {
+			i += 1
+		}
+	}
+	if i < len(expected) {
+		fail("command '%s' is not executed in the expected order, executed: %s",
+			expected[i], strings.Join(executed, ", "))
+	}
+}
+
+func flowTestCmdDisplayPath(cc *core.Cli, path string) string {
+	cmdPath := normalizeCmdPath(path, cc.Cmds.Strs.PathSep, cc.Cmds.Strs.PathAlterSeps)
+	node := cc.Cmds.GetSub(strings.Split(cmdPath, cc.Cmds.Strs.PathSep)...)
+	if node == nil {
+		return path
+	}
+	return node.DisplayPath()
+}
+
+func genJUnitReport(results []display.FlowTestResult) (report flow_file.JUnitTestSuites) {
+	var total time.Duration
+	var suite *flow_file.JUnitTestSuite
+	var suiteDur time.Duration
+	flush := func() {
+		if suite != nil {
+			suite.Time = flow_file.JUnitDuration(suiteDur)
+			report.Add(*suite)
+		}
+	}
+	for _, it := range results {
+		if suite == nil || suite.Name != it.Suite {
+			flush()
+			suite = &flow_file.JUnitTestSuite{it.Suite, 0, 0, "", nil}
+			suiteDur = 0
+		}
+		testCase := flow_file.JUnitTestCase{it.Name, it.Suite, it.File,
+			flow_file.JUnitDuration(it.Duration), nil, it.Output}
+		if !it.Passed() {
+			testCase.Failure = &flow_file.JUnitFailure{it.Failures[0], strings.Join(it.Failures, "\n")}
+			suite.Failures += 1
+		}
+		suite.Tests += 1
+		suite.Cases = append(suite.Cases, testCase)
+		suiteDur += it.Duration
+		total += it.Duration
+	}
+	flush()
+	report.Time = flow_file.JUnitDuration(total)
+	return
+}
+
+// Capture all output of a test, include the stdout/stderr of the mods,
+// they are written by different goroutines, so the buffer is guarded
+type flowTestScreen struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+	outN int
+}
+
+func (self *flowTestScreen) Print(text string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.buf.WriteString(text)
+	self.outN += 1
+}
+
+func (self *flowTestScreen) Error(text string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.buf.WriteString(text)
+}
+
+func (self *flowTestScreen) OutputNum() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.outN
+}
+
+func (self *flowTestScreen) CaptureModOutput() bool {
+	return true
+}
+
+func (self *flowTestScreen) String() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.buf.String()
+}
@@ -0,0 +1,79 @@
+package builtin
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+)
+
+func TestCheckFlowTestCmds(t *testing.T) {
+	tree := core.NewCmdTree(&core.CmdTreeStrs{"<root>", "<builtin>", ".", "./", "|", ":", "--", "=", ".", "\t",
+		"[[", "]]", "|"})
+	tree.AddSub("deploy").AddSub("start")
+	tree.AddSub("bench")
+	cc := &core.Cli{Cmds: tree}
+
+	test := func(expected []string, executed []string, ok bool) {
+		var failures []string
+		fail := func(format string, a ...interface{}) {
+			failures = append(failures, format)
+		}
+		checkFlowTestCmds(cc, expected, executed, fail)
+		if ok != (len(failures) == 0) {
+			t.Fatalf("check %v by executed %v: %v", expected, executed, failures)
+		}
+	}
+
+	test(nil, []string{"bench"}, true)
+	test([]string{"deploy/start", "bench"}, []string{"deploy.start", "dummy", "bench"}, true)
+	test([]string{"bench", "deploy.start"}, []string{"deploy.start", "bench"}, false)
+	test([]string{"bench", "bench"}, []string{"bench"}, false)
+}
+
+func TestGenJUnitReport(t *testing.T) {
+	results := []display.FlowTestResult{
+		{"repo-x", "a", "/x/a.tiflow.test", nil, "out-a", time.Second},
+		{"repo-x", "b", "/x/b.tiflow.test", []string{"the flow failed", "env 'k' not found"}, "out-b",
+			2 * time.Second},
+		{"repo-y", "c", "", nil, "", 500 * time.Millisecond},
+	}
+	report := genJUnitReport(results)
+	if report.Tests != 3 || report.Failures != 1 || report.Time != "3.500" || len(report.Suites) != 2 {
+		t.Fatalf("wrong report: %v", report)
+	}
+	x := report.Suites[0]
+	if x.Name != "repo-x" || x.Tests != 2 || x.Failures != 1 || x.Time != "3.000" || len(x.Cases) != 2 {
+		t.Fatalf("wrong suite: %v", x)
+	}
+	failure := x.Cases[1].Failure
+	if x.Cases[0].Failure != nil || failure == nil || failure.Message != "the flow failed" ||
+		failure.Text != "the flow failed\nenv 'k' not found" || x.Cases[1].SystemOut != "out-b" {
+		t.Fatalf("wrong cases: %v", x.Cases)
+	}
+	if y := report.Suites[1]; y.Name != "repo-y" || y.Tests != 1 || y.Failures != 0 || y.Time != "0.500" {
+		t.Fatalf("wrong suite: %v", y)
+	}
+}
+
+func TestFlowTestScreenConcurrentPrint(t *testing.T) {
+	screen := &flowTestScreen{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				screen.Print("o\n")
+				screen.Error("e\n")
+			}
+		}()
+	}
+	wg.Wait()
+	if screen.OutputNum() != 400 || strings.Count(screen.String(), "\n") != 800 {
+		t.Fatalf("output lost: %d %d", screen.OutputNum(), strings.Count(screen.String(), "\n"))
+	}
+}
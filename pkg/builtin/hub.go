@@ -3,9 +3,12 @@ package builtin
 import (
 	"fmt"
 	"io/fs"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pingcap/ticat/pkg/cli/core"
 	"github.com/pingcap/ticat/pkg/cli/display"
@@ -21,9 +24,10 @@ func LoadModsFromHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.Pa
 
 	metaPath := getReposInfoPath(env, cmd)
 	fieldSep := env.GetRaw("strs.proto-sep")
+	pathSep := cc.Cmds.Strs.PathSep
 
 	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
-	for _, info := range infos {
+	for _, info := range meta.SortReposByPriority(infos) {
 		if info.OnOff != "on" {
 			continue
 		}
@@ -31,14 +35,18 @@ func LoadModsFromHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.Pa
 		if len(source) == 0 {
 			source = info.Path
 		}
-		loadLocalMods(cc, info.Path, reposFileName, metaExt, flowExt, abbrsSep, envPathSep, source)
+		loadLocalMods(cc, info.Path, reposFileName, metaExt, flowExt, abbrsSep, envPathSep, source,
+			info.Priority, info.MountPath(pathSep))
 	}
 	return true
 }
 
 func AddGitRepoToHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
 	addr := getAndCheckArg(argv, env, cmd, "git-address")
-	addRepoToHub(addr, argv, cc.Screen, env, cmd)
+	mountPoint := getMountPointArg(argv, cc)
+	if !addRepoToHub(addr, mountPoint, argv, cc, env, cmd) {
+		return false
+	}
 	showHubFindTip(cc.Screen, env)
 	return true
 }
@@ -48,7 +56,30 @@ func AddGitDefaultToHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core
 	if len(addr) == 0 {
 		panic(core.NewCmdError(cmd, "cant't get init-repo address from env, 'sys.hub.init-repo' is empty"))
 	}
-	addRepoToHub(addr, argv, cc.Screen, env, cmd)
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	olds, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	if !addRepoToHub(addr, "", argv, cc, env, cmd) {
+		return false
+	}
+
+	// The init repo (and the repos it depends on) is configured by ticat, so it's trusted
+	oldAddrs := map[string]bool{}
+	for _, info := range olds {
+		oldAddrs[info.Addr] = true
+	}
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	var addeds []meta.RepoInfo
+	for _, info := range infos {
+		if !oldAddrs[info.Addr] {
+			addeds = append(addeds, info)
+		}
+	}
+	list := readTrustList(cc, env, cmd)
+	if list.ReviewRepos(addeds) != 0 {
+		meta.WriteTrustFile(getHubTrustFilePath(env, cmd), list, fieldSep)
+	}
+
 	showHubFindTip(cc.Screen, env)
 	return true
 }
@@ -89,10 +120,10 @@ func RemoveAllFromHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
 
 	for _, info := range infos {
-		if !info.IsLocal() {
+		if isRepoDirOwned(info) {
 			osRemoveDir(info.Path, cmd)
 		}
-		cc.Screen.Print(fmt.Sprintf("[%s]%s\n", repoDisplayName(info), purgedStr(env, info.IsLocal())))
+		cc.Screen.Print(fmt.Sprintf("[%s]%s\n", repoDisplayName(info), purgedStr(env, !isRepoDirOwned(info))))
 		printInfoProps(cc.Screen, info)
 	}
 
@@ -128,7 +159,6 @@ func PurgeInactiveRepoFromHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cm
 func UpdateHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
 	metaPath := getReposInfoPath(env, cmd)
 	listFileName := env.GetRaw("strs.repos-file-name")
-	repoExt := env.GetRaw("strs.mods-repo-ext")
 
 	path := getHubPath(env, cmd)
 	fieldSep := env.GetRaw("strs.proto-sep")
@@ -139,36 +169,280 @@ func UpdateHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCm
 			finisheds[info.Addr] = true
 		}
 	}
+	oldSnapshots := snapshotHubRepos(cc, env, oldInfos)
+
+	var addrs []string
+	var refs []string
+	for _, info := range oldInfos {
+		if len(info.Addr) == 0 || finisheds[info.Addr] {
+			continue
+		}
+		addrs = append(addrs, info.Addr)
+		refs = append(refs, info.Ref)
+	}
 
 	selfName := env.GetRaw("strs.self-name")
-	var infos []meta.RepoInfo
+	concurrency := env.GetInt("sys.hub.update-concurrency")
+	results := meta.UpdateReposInParallel(
+		cc.Screen, finisheds, path, addrs, refs, listFileName, selfName, concurrency)
 
-	for _, info := range oldInfos {
-		if len(info.Addr) == 0 {
+	// The refs of sub-repos are defined by the upper repos' lists
+	subRefs := map[string]string{}
+
+	var infos []meta.RepoInfo
+	for _, res := range results {
+		for i, addr := range res.SubAddrs {
+			subRefs[addr] = res.SubRefs[i]
+		}
+		if len(res.Parent) == 0 || oldList[res.Addr] || res.Failed() {
 			continue
 		}
-		_, addrs, helpStrs := meta.UpdateRepoAndSubRepos(
-			cc.Screen, finisheds, path, info.Addr, repoExt, listFileName, selfName, cmd)
-		for i, addr := range addrs {
-			if oldList[addr] {
-				continue
-			}
-			repoPath := meta.GetRepoPath(path, addr)
-			infos = append(infos, meta.RepoInfo{addr, info.Addr, repoPath, helpStrs[i], "on"})
+		repoPath := meta.GetRepoPath(path, res.Addr)
+		infos = append(infos, meta.RepoInfo{Addr: res.Addr, AddReason: res.Root, Path: repoPath,
+			HelpStr: res.DisplayHelpStr(), OnOff: "on", Ref: res.Ref})
+	}
+
+	for i, info := range oldInfos {
+		ref, ok := subRefs[info.Addr]
+		if ok && info.AddReason != info.Addr {
+			info.Ref = ref
+			oldInfos[i] = info
 		}
 	}
 
 	infos = append(oldInfos, infos...)
-	if len(infos) != len(oldInfos) {
-		meta.WriteReposInfoFile(metaPath, infos, fieldSep)
-	}
+	meta.WriteReposInfoFile(metaPath, infos, fieldSep)
+	writeHubLockFile(cc.Screen, env, infos, cmd)
 
+	recordHubChanges(cc, env, infos, oldSnapshots, cmd)
+	buildHubIndex(cc, env, infos, cmd)
+
+	checks, conflicts := meta.ResolveRepoDepends(infos, listFileName, selfName)
+	printDependIssues(cc.Screen, env, checks, conflicts)
+
+	if !printHubUpdateSummary(cc.Screen, env, results) {
+		return false
+	}
 	display.PrintTipTitle(cc.Screen, env, fmt.Sprintf(
 		"local dir could also add to %s, use command 'h.add.local'",
 		env.GetRaw("strs.self-name")))
 	return true
 }
 
+func ShowHubChanges(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	findStrs := getFindStrsFromArgv(argv)
+	changes, exists := meta.ReadRepoChanges(getHubChangesFilePath(env, cmd))
+	if !exists {
+		display.PrintTipTitle(cc.Screen, env,
+			"no update record, the changes will be recorded by 'hub.update'.")
+		return true
+	}
+
+	screen := display.NewCacheScreen()
+	for _, it := range changes {
+		if !matchFindRepoChanges(it, findStrs) {
+			continue
+		}
+		printRepoChanges(screen, it)
+	}
+
+	if screen.OutputNum() <= 0 {
+		if len(findStrs) != 0 {
+			display.PrintTipTitle(cc.Screen, env, "no matched changes in the last update.")
+		} else {
+			display.PrintTipTitle(cc.Screen, env, "no command changed in the last update.")
+		}
+		return true
+	}
+	display.PrintTipTitle(cc.Screen, env, "commands changed by the last update:")
+	screen.WriteTo(cc.Screen)
+	return true
+}
+
+func ShowHubDepends(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	findStrs := getFindStrsFromArgv(argv)
+
+	selfName := env.GetRaw("strs.self-name")
+	listFileName := env.GetRaw("strs.repos-file-name")
+	checks, conflicts := meta.ResolveRepoDepends(infos, listFileName, selfName)
+	repoChecks := map[string][]meta.DependCheck{}
+	for _, check := range checks {
+		repoChecks[check.Repo] = append(repoChecks[check.Repo], check)
+	}
+
+	screen := display.NewCacheScreen()
+	for _, info := range infos {
+		if info.OnOff != "on" {
+			continue
+		}
+		repoMeta := meta.ReadRepoMetaFromFile(selfName, filepath.Join(info.Path, listFileName))
+		if len(repoMeta.Version) == 0 && len(repoMeta.Depends) == 0 {
+			continue
+		}
+		matched := true
+		for _, findStr := range findStrs {
+			matched = matched && matchFindRepoInfo(info, findStr)
+		}
+		if !matched {
+			continue
+		}
+		screen.Print(fmt.Sprintf("[%s]\n", repoDisplayName(info)))
+		if len(repoMeta.Version) != 0 {
+			screen.Print(fmt.Sprintf("    - version: %s\n", repoMeta.Version))
+		}
+		repo := info.Addr
+		if info.IsLocal() {
+			repo = info.Path
+		}
+		if len(repoChecks[repo]) != 0 {
+			screen.Print("    - depends:\n")
+			for _, check := range repoChecks[repo] {
+				screen.Print(fmt.Sprintf("        %s\n", dependCheckStr(check)))
+			}
+		}
+	}
+
+	if screen.OutputNum() <= 0 {
+		display.PrintTipTitle(cc.Screen, env, "no repo declares version or dependencies.")
+		return true
+	}
+	display.PrintTipTitle(cc.Screen, env, "versions and dependencies of enabled repos:")
+	screen.WriteTo(cc.Screen)
+	return printDependIssues(cc.Screen, env, checks, conflicts)
+}
+
+func FindInHubIndex(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	findStrs := getFindStrsFromArgv(argv)
+	if len(findStrs) == 0 {
+		panic(core.NewCmdError(cmd, "need at least one find-str"))
+	}
+
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+
+	indexes, exists := meta.ReadRepoIndexes(getHubIndexFilePath(env, cmd))
+	if !exists {
+		indexes = buildHubIndex(cc, env, infos, cmd)
+	}
+	results := meta.SearchRepoIndexes(indexes, findStrs)
+	if len(results) == 0 {
+		display.PrintTipTitle(cc.Screen, env,
+			"no matched commands in hub index.",
+			"",
+			"the index is rebuilt by 'hub.add' and 'hub.update', or manually by 'hub.index'.")
+		return true
+	}
+
+	screen := display.NewCacheScreen()
+	var tips []string
+	for _, it := range results {
+		info, found := findIndexedRepoInHub(infos, it)
+		screen.Print(fmt.Sprintf("[%s]", it.Name()))
+		if !found {
+			screen.Print(" (not in hub)")
+			if it.IsLocal() {
+				tips = append(tips, fmt.Sprintf("hub.add.local path=%s", it.Path))
+			} else {
+				tips = append(tips, fmt.Sprintf("hub.add %s", it.Addr))
+			}
+		} else if info.OnOff != "on" {
+			screen.Print(disabledStr(env))
+			tips = append(tips, fmt.Sprintf("hub.enable %s", repoDisplayName(info)))
+		} else {
+			screen.Print(enabledStr(env, true))
+		}
+		screen.Print("\n")
+		if len(it.HelpStr) > 0 {
+			screen.Print(fmt.Sprintf("     '%s'\n", it.HelpStr))
+		}
+		if len(it.Addr) != 0 && it.Name() != it.Addr {
+			screen.Print(fmt.Sprintf("    - addr: %s\n", it.Addr))
+		}
+		if it.IsLocal() {
+			screen.Print(fmt.Sprintf("    - path: %s\n", it.Path))
+		}
+		if len(it.ListedBy) != 0 {
+			screen.Print(fmt.Sprintf("    - listed-by: %s\n", strings.Join(it.ListedBy, ", ")))
+		}
+		if len(it.Cmds) == 0 {
+			screen.Print("    - commands: unknown, not added before\n")
+		}
+		for _, it := range it.Cmds {
+			screen.Print(fmt.Sprintf("    [%s]\n", it.Path))
+			if len(it.Help) > 0 {
+				screen.Print(fmt.Sprintf("         '%s'\n", it.Help))
+			}
+		}
+	}
+
+	display.PrintTipTitle(cc.Screen, env, "matched commands in hub index:")
+	screen.WriteTo(cc.Screen)
+	if len(tips) != 0 {
+		selfName := env.GetRaw("strs.self-name")
+		lines := []interface{}{"to get the commands of the repos not enabled:", ""}
+		for _, tip := range tips {
+			lines = append(lines, "    "+selfName+" "+tip)
+		}
+		display.PrintTipTitle(cc.Screen, env, lines...)
+	}
+	return true
+}
+
+func RebuildHubIndex(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	indexes := buildHubIndex(cc, env, infos, cmd)
+	cmdCount := 0
+	for _, it := range indexes {
+		cmdCount += len(it.Cmds)
+	}
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("hub index rebuilt, %d repos and %d commands indexed.", len(indexes), cmdCount))
+	return true
+}
+
+func SetRepoPriorityInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	findStr := getAndCheckArg(argv, env, cmd, "find-str")
+
+	extracted, _ := meta.ExtractAddrFromList(infos, findStr)
+	checkFoundRepos(env, cmd, extracted, findStr)
+
+	// Only show the priorities if the arg is empty
+	priorityStr := argv.GetRaw("priority")
+	if len(priorityStr) == 0 {
+		for _, info := range extracted {
+			cc.Screen.Print(fmt.Sprintf("[%s] (priority: %d)\n", repoDisplayName(info), info.Priority))
+			printInfoProps(cc.Screen, info)
+		}
+		return true
+	}
+	priority, err := strconv.Atoi(priorityStr)
+	if err != nil {
+		panic(core.NewCmdError(cmd, fmt.Sprintf("bad priority '%s', should be an integer", priorityStr)))
+	}
+
+	for i, info := range extracted {
+		info.Priority = priority
+		extracted[i] = info
+		cc.Screen.Print(fmt.Sprintf("[%s] (priority: %d)\n", repoDisplayName(info), info.Priority))
+		printInfoProps(cc.Screen, info)
+	}
+	meta.WriteReposInfoFile(metaPath, meta.UpdateReposInfo(infos, extracted), fieldSep)
+
+	display.PrintTipTitle(cc.Screen, env,
+		"when repos/dirs have the same commands, the higher priority one shadows the others.",
+		"the default priority is 0, the same priority ones are conflicted.")
+	return true
+}
+
 func EnableRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
 	metaPath := getReposInfoPath(env, cmd)
 	fieldSep := env.GetRaw("strs.proto-sep")
@@ -177,6 +451,7 @@ func EnableRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.Pa
 
 	extracted, rest := meta.ExtractAddrFromList(infos, findStr)
 	checkFoundRepos(env, cmd, extracted, findStr)
+	checkEnablingReposDepends(env, cmd, extracted, rest)
 
 	var count int
 	for i, info := range extracted {
@@ -190,7 +465,7 @@ func EnableRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.Pa
 		extracted[i] = info
 	}
 
-	meta.WriteReposInfoFile(metaPath, append(rest, extracted...), fieldSep)
+	meta.WriteReposInfoFile(metaPath, meta.UpdateReposInfo(infos, extracted), fieldSep)
 
 	if count > 0 {
 		display.PrintTipTitle(cc.Screen, env,
@@ -208,7 +483,7 @@ func DisableRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
 	findStr := getAndCheckArg(argv, env, cmd, "find-str")
 
-	extracted, rest := meta.ExtractAddrFromList(infos, findStr)
+	extracted, _ := meta.ExtractAddrFromList(infos, findStr)
 	checkFoundRepos(env, cmd, extracted, findStr)
 
 	var count int
@@ -224,7 +499,7 @@ func DisableRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 		}
 	}
 
-	meta.WriteReposInfoFile(metaPath, append(rest, extracted...), fieldSep)
+	meta.WriteReposInfoFile(metaPath, meta.UpdateReposInfo(infos, extracted), fieldSep)
 
 	if count > 0 {
 		display.PrintTipTitle(cc.Screen, env,
@@ -251,6 +526,7 @@ func AddLocalDirToHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 	if err != nil {
 		panic(core.WrapCmdError(cmd, fmt.Errorf("get abs path of '%v' failed: %v", path, err)))
 	}
+	mountPoint := getMountPointArg(argv, cc)
 
 	screen := display.NewCacheScreen()
 
@@ -260,7 +536,7 @@ func AddLocalDirToHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 	found := false
 	for i, info := range infos {
 		if info.Path == path {
-			if info.OnOff == "on" {
+			if info.OnOff == "on" && info.MountPoint == mountPoint {
 				screen.Print(fmt.Sprintf("[%s] (exists)\n", repoDisplayName(info)))
 				printInfoProps(screen, info)
 				display.PrintTipTitle(cc.Screen, env,
@@ -268,6 +544,7 @@ func AddLocalDirToHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 				return true
 			}
 			info.OnOff = "on"
+			info.MountPoint = mountPoint
 			infos[i] = info
 			screen.Print(fmt.Sprintf("[%s] (enabled)\n", repoDisplayName(info)))
 			printInfoProps(screen, info)
@@ -280,12 +557,14 @@ func AddLocalDirToHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.P
 		listFileName := env.GetRaw("strs.repos-file-name")
 		listFilePath := filepath.Join(path, listFileName)
 		helpStr, _, _ := meta.ReadRepoListFromFile(env.GetRaw("strs.self-name"), listFilePath)
-		info := meta.RepoInfo{"", "<local>", path, helpStr, "on"}
+		info := meta.RepoInfo{AddReason: "<local>", Path: path, HelpStr: helpStr, OnOff: "on",
+			MountPoint: mountPoint}
 		infos = append(infos, info)
 		screen.Print(fmt.Sprintf("[%s]\n", repoDisplayName(info)))
 		printInfoProps(screen, info)
 	}
 	meta.WriteReposInfoFile(metaPath, infos, fieldSep)
+	buildHubIndex(cc, env, infos, cmd)
 
 	if !found {
 		display.PrintTipTitle(cc.Screen, env,
@@ -393,6 +672,18 @@ func listHub(screen core.Screen, env *core.Env, infos []meta.RepoInfo, filterStr
 		if len(info.Addr) != 0 && name != info.Addr {
 			screen.Print(fmt.Sprintf("    - addr: %s\n", info.Addr))
 		}
+		if len(info.Ref) != 0 {
+			screen.Print(fmt.Sprintf("    - ref:  %s\n", info.Ref))
+		}
+		if info.Priority != 0 {
+			screen.Print(fmt.Sprintf("    - priority: %d\n", info.Priority))
+		}
+		if len(info.MountPoint) != 0 {
+			screen.Print(fmt.Sprintf("    - mount: %s\n", info.MountPoint))
+		}
+		if !info.IsLocal() && info.Backend().Name() != meta.HubBackendGit {
+			screen.Print(fmt.Sprintf("    - backend: %s\n", info.Backend().Name()))
+		}
 		screen.Print(fmt.Sprintf("    - from: %s\n", getDisplayReason(info)))
 		screen.Print(fmt.Sprintf("    - path: %s\n", info.Path))
 	}
@@ -418,13 +709,13 @@ func purgeInactiveRepoFromHub(findStr string, cc *core.Cli, env *core.Env, cmd c
 	var removeds int
 
 	for _, info := range extracted {
-		if !info.IsLocal() {
+		if isRepoDirOwned(info) {
 			osRemoveDir(info.Path, cmd)
 			removeds += 1
 		} else {
 			unlinkeds += 1
 		}
-		cc.Screen.Print(fmt.Sprintf("[%s]%s\n", repoDisplayName(info), purgedStr(env, info.IsLocal())))
+		cc.Screen.Print(fmt.Sprintf("[%s]%s\n", repoDisplayName(info), purgedStr(env, !isRepoDirOwned(info))))
 		printInfoProps(cc.Screen, info)
 	}
 
@@ -482,19 +773,23 @@ func moveSavedFlowsToLocalDir(toDir string, cc *core.Cli, env *core.Env, cmd cor
 	return count
 }
 
+// Return false if any repo failed
 func addRepoToHub(
 	gitAddr string,
+	mountPoint string,
 	argv core.ArgVals,
-	screen core.Screen,
+	cc *core.Cli,
 	env *core.Env,
-	cmd core.ParsedCmd) (addrs []string, helpStrs []string) {
+	cmd core.ParsedCmd) bool {
 
-	// A repo with this suffix should be a well controlled one, that we could assume some things
-	repoExt := env.GetRaw("strs.mods-repo-ext")
+	screen := cc.Screen
+	gitAddr, ref := meta.SplitAddrAndRef(gitAddr)
+	gitAddr = meta.NormalizeRepoAddr(gitAddr)
 
-	gitAddr = meta.NormalizeGitAddr(gitAddr)
+	// The repos already in hub are trusted if the trust file is not created yet, the new ones are not
+	readTrustList(cc, env, cmd)
 
-	if !isOsCmdExists("git") {
+	if meta.GetHubBackend(gitAddr).Name() == meta.HubBackendGit && !isOsCmdExists("git") {
 		panic(core.NewCmdError(cmd, "cant't find 'git'"))
 	}
 
@@ -511,6 +806,8 @@ func addRepoToHub(
 	for i, info := range oldInfos {
 		if info.Addr == gitAddr {
 			info.OnOff = "on"
+			info.Ref = ref
+			info.MountPoint = mountPoint
 			oldInfos[i] = info
 		}
 		if info.OnOff != "on" {
@@ -520,35 +817,571 @@ func addRepoToHub(
 
 	selfName := env.GetRaw("strs.self-name")
 	listFileName := env.GetRaw("strs.repos-file-name")
-	var topRepoHelpStr string
-	topRepoHelpStr, addrs, helpStrs = meta.UpdateRepoAndSubRepos(
-		screen, finisheds, path, gitAddr, repoExt, listFileName, selfName, cmd)
-
-	addrs = append([]string{gitAddr}, addrs...)
-	helpStrs = append([]string{topRepoHelpStr}, helpStrs...)
+	concurrency := env.GetInt("sys.hub.update-concurrency")
+	results := meta.UpdateReposInParallel(screen, finisheds, path,
+		[]string{gitAddr}, []string{ref}, listFileName, selfName, concurrency)
 
 	var infos []meta.RepoInfo
-	for i, addr := range addrs {
-		if oldList[addr] {
+	for _, res := range results {
+		if oldList[res.Addr] || res.Failed() {
 			continue
 		}
-		repoPath := meta.GetRepoPath(path, addr)
-		infos = append(infos, meta.RepoInfo{addr, gitAddr, repoPath, helpStrs[i], "on"})
+		repoPath := meta.GetRepoPath(path, res.Addr)
+		info := meta.RepoInfo{Addr: res.Addr, AddReason: gitAddr, Path: repoPath,
+			HelpStr: res.DisplayHelpStr(), OnOff: "on", Ref: res.Ref}
+		// Only the manually added repo is mounted, sub-repos and depended repos are in the root
+		if res.Addr == gitAddr {
+			info.MountPoint = mountPoint
+		}
+		infos = append(infos, info)
 	}
 
 	infos = append(oldInfos, infos...)
 	meta.WriteReposInfoFile(metaPath, infos, fieldSep)
+	writeHubLockFile(screen, env, infos, cmd)
+	buildHubIndex(cc, env, infos, cmd)
+
+	checks, conflicts := meta.ResolveRepoDepends(infos, listFileName, selfName)
+	printDependIssues(screen, env, checks, conflicts)
+	return printHubUpdateSummary(screen, env, results)
+}
+
+// Return false if any repo failed
+func printHubUpdateSummary(screen core.Screen, env *core.Env, results []meta.RepoUpdateResult) bool {
+	if len(results) == 0 {
+		return true
+	}
+
+	nameWidth := len("repo")
+	for _, res := range results {
+		if len(res.Name()) > nameWidth {
+			nameWidth = len(res.Name())
+		}
+	}
+
+	counts := map[string]int{}
+	var faileds []meta.RepoUpdateResult
+	screen.Print(fmt.Sprintf("%-*s  %-9s  %-16s  %s\n", nameWidth, "repo", "status", "commit", "time"))
+	for _, res := range results {
+		counts[res.Status] += 1
+		commit := shortCommitStr(res.NewCommit)
+		if res.Status == meta.RepoUpdateUpdated {
+			commit = shortCommitStr(res.OldCommit) + ".." + commit
+		}
+		if res.Failed() {
+			commit = "-"
+			faileds = append(faileds, res)
+		}
+		screen.Print(fmt.Sprintf("%-*s  %-9s  %-16s  %s\n", nameWidth, res.Name(), res.Status, commit,
+			res.Duration.Round(time.Millisecond*100)))
+	}
+
+	for _, res := range faileds {
+		screen.Print(fmt.Sprintf("[%s] (failed)\n", res.Name()))
+		screen.Print(fmt.Sprintf("    - error: %v\n", res.Err))
+		output := strings.TrimSpace(res.Output)
+		if len(output) == 0 {
+			continue
+		}
+		screen.Print("    - output:\n")
+		for _, line := range strings.Split(output, "\n") {
+			screen.Print("        " + line + "\n")
+		}
+	}
+
+	summary := fmt.Sprintf("%d repos: %d cloned, %d updated, %d unchanged, %d failed.",
+		len(results), counts[meta.RepoUpdateCloned], counts[meta.RepoUpdateUpdated],
+		counts[meta.RepoUpdateUnchanged], counts[meta.RepoUpdateFailed])
+	if len(faileds) != 0 {
+		display.PrintErrTitle(screen, env, summary,
+			"",
+			"the failed repos are skipped, fix them and run 'hub.update' again.")
+		return false
+	}
+	display.PrintTipTitle(screen, env, summary)
+	return true
+}
+
+func shortCommitStr(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+func LockHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+
+	locks, err := meta.GenRepoLocks(infos)
+	if err != nil {
+		panic(core.WrapCmdError(cmd, err))
+	}
+	lockPath := argv.GetRaw("path")
+	if len(lockPath) == 0 {
+		lockPath = getHubLockFilePath(env, cmd)
+	}
+	meta.WriteLockFile(lockPath, locks, fieldSep)
+
+	for _, lock := range locks {
+		cc.Screen.Print(fmt.Sprintf("[%s]\n", meta.JoinAddrAndRef(meta.AddrDisplayName(lock.Addr), lock.Ref)))
+		cc.Screen.Print(fmt.Sprintf("    - commit: %s\n", lock.Commit))
+	}
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("%d repos locked to '%s'.", len(locks), lockPath),
+		"",
+		"copy it to another machine and run 'hub.sync path=<lock-file>' to reproduce.")
+	return true
+}
+
+func SyncHubToLock(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	lockPath := argv.GetRaw("path")
+	if len(lockPath) == 0 {
+		lockPath = getHubLockFilePath(env, cmd)
+	}
+	fieldSep := env.GetRaw("strs.proto-sep")
+	locks := meta.ReadLockFile(lockPath, fieldSep)
+	readTrustList(cc, env, cmd)
+	for _, lock := range locks {
+		if meta.GetHubBackend(lock.Addr).Name() == meta.HubBackendGit && !isOsCmdExists("git") {
+			panic(core.NewCmdError(cmd, "cant't find 'git'"))
+		}
+	}
+
+	path := getHubPath(env, cmd)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil && !os.IsExist(err) {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("create hub path '%s' failed: %v", path, err)))
+	}
+
+	metaPath := getReposInfoPath(env, cmd)
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	indexes := map[string]int{}
+	for i, info := range infos {
+		if !info.IsLocal() {
+			indexes[info.Addr] = i
+		}
+	}
+
+	selfName := env.GetRaw("strs.self-name")
+	listFileName := env.GetRaw("strs.repos-file-name")
+	for _, lock := range locks {
+		repoPath, helpStr := meta.SyncRepoToLock(cc.Screen, path, lock, listFileName, selfName, cmd)
+		i, ok := indexes[lock.Addr]
+		if !ok {
+			infos = append(infos, meta.RepoInfo{Addr: lock.Addr, AddReason: lock.AddReason, Path: repoPath,
+				HelpStr: helpStr, OnOff: "on", Ref: lock.Ref})
+			continue
+		}
+		info := infos[i]
+		info.Ref = lock.Ref
+		info.OnOff = "on"
+		infos[i] = info
+	}
+	meta.WriteReposInfoFile(metaPath, infos, fieldSep)
+	if lockPath != getHubLockFilePath(env, cmd) {
+		writeHubLockFile(cc.Screen, env, infos, cmd)
+	}
+	buildHubIndex(cc, env, infos, cmd)
+
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("%d repos synced to the state of '%s'.", len(locks), lockPath),
+		"",
+		"'hub.update' will move the repos to the latest of their refs.")
+	return true
+}
+
+func ExportHubBundle(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	bundlePath := getAndCheckArg(argv, env, cmd, "path")
+	findStr := argv.GetRaw("find-str")
+
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	selfName := env.GetRaw("strs.self-name")
+	listFileName := env.GetRaw("strs.repos-file-name")
+	selected := selectBundleRepos(infos, findStr, listFileName, selfName)
+	if len(selected) == 0 {
+		if len(findStr) != 0 {
+			display.PrintTipTitle(cc.Screen, env, "no git repos matched find string '"+findStr+"'")
+		} else {
+			display.PrintTipTitle(cc.Screen, env, "no git repos in hub")
+		}
+		return true
+	}
+
+	for _, info := range selected {
+		if _, err := os.Stat(info.Path); err != nil {
+			panic(core.WrapCmdError(cmd, fmt.Errorf("access repo '%s' failed: %v", info.Addr, err)))
+		}
+	}
+	bundlePath, err := filepath.Abs(bundlePath)
+	if err != nil {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("get abs path of '%v' failed: %v", bundlePath, err)))
+	}
+	meta.ExportBundle(bundlePath, selected, env.GetRaw("strs.hub-file-name"), fieldSep)
+
+	for _, info := range selected {
+		cc.Screen.Print(fmt.Sprintf("[%s]\n", repoDisplayName(info)))
+		printInfoProps(cc.Screen, info)
+	}
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("%d repos exported to bundle '%s'.", len(selected), bundlePath),
+		"",
+		"install it on another host (could be offline) by:",
+		"",
+		"    "+selfName+" hub.bundle.import path=<file>")
+	return true
+}
+
+func ImportHubBundle(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	bundlePath := getAndCheckArg(argv, env, cmd, "path")
+	readTrustList(cc, env, cmd)
+
+	path := getHubPath(env, cmd)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil && !os.IsExist(err) {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("create hub path '%s' failed: %v", path, err)))
+	}
+	// In the hub dir, so the repos could be moved to their places by renaming
+	tmpDir, err := ioutil.TempDir(path, ".bundle-")
+	if err != nil {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("create temp dir in '%s' failed: %v", path, err)))
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fieldSep := env.GetRaw("strs.proto-sep")
+	packeds := meta.ExtractBundle(bundlePath, filepath.Join(tmpDir, "new"),
+		env.GetRaw("strs.hub-file-name"), fieldSep)
+
+	metaPath := getReposInfoPath(env, cmd)
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+	oldSnapshots := snapshotHubRepos(cc, env, infos)
+	indexes := map[string]int{}
+	for i, info := range infos {
+		if !info.IsLocal() {
+			indexes[info.Addr] = i
+		}
+	}
+
+	screen := display.NewCacheScreen()
+	for _, packed := range packeds {
+		if packed.Backend().ReadOnly() {
+			screen.Print(fmt.Sprintf("[%s] (skipped, read-only)\n", repoDisplayName(packed)))
+			continue
+		}
+		repoPath := meta.GetRepoPath(path, packed.Addr)
+		replaceRepoDir(packed.Path, repoPath, filepath.Join(tmpDir, "old"), cmd)
+		i, ok := indexes[packed.Addr]
+		if ok {
+			// Keep the local settings, only update the repo state
+			info := infos[i]
+			info.HelpStr = packed.HelpStr
+			info.Ref = packed.Ref
+			info.Path = repoPath
+			infos[i] = info
+			screen.Print(fmt.Sprintf("[%s] (updated)\n", repoDisplayName(info)))
+			printInfoProps(screen, info)
+		} else {
+			packed.Path = repoPath
+			indexes[packed.Addr] = len(infos)
+			infos = append(infos, packed)
+			screen.Print(fmt.Sprintf("[%s] (added)\n", repoDisplayName(packed)))
+			printInfoProps(screen, packed)
+		}
+	}
+
+	meta.WriteReposInfoFile(metaPath, infos, fieldSep)
+	writeHubLockFile(cc.Screen, env, infos, cmd)
+	recordHubChanges(cc, env, infos, oldSnapshots, cmd)
+	buildHubIndex(cc, env, infos, cmd)
+
+	selfName := env.GetRaw("strs.self-name")
+	listFileName := env.GetRaw("strs.repos-file-name")
+	checks, conflicts := meta.ResolveRepoDepends(infos, listFileName, selfName)
+	printDependIssues(cc.Screen, env, checks, conflicts)
+
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("%d repos imported from bundle '%s'.", len(packeds), bundlePath))
+	screen.WriteTo(cc.Screen)
+	return true
+}
+
+// The matched repos, with the sub-repos and depended repos of them, read-only repos are excluded
+func selectBundleRepos(
+	infos []meta.RepoInfo,
+	findStr string,
+	listFileName string,
+	selfName string) (selected []meta.RepoInfo) {
+
+	addrs := map[string]bool{}
+	for _, info := range infos {
+		if isRepoDirOwned(info) && matchFindRepoInfo(info, findStr) {
+			selected = append(selected, info)
+			addrs[info.Addr] = true
+		}
+	}
+	for i := 0; i < len(selected); i++ {
+		repoMeta := meta.ReadRepoMetaFromFile(selfName, filepath.Join(selected[i].Path, listFileName))
+		subs := append([]string{}, repoMeta.Addrs...)
+		for _, dep := range repoMeta.Depends {
+			subs = append(subs, dep.Addr)
+		}
+		for _, addr := range subs {
+			addr, _ = meta.SplitAddrAndRef(addr)
+			info, ok := meta.FindRepoInfo(infos, addr)
+			if ok && !addrs[info.Addr] && isRepoDirOwned(info) {
+				selected = append(selected, info)
+				addrs[info.Addr] = true
+			}
+		}
+	}
 	return
 }
 
+// Move the old repo dir away before moving the new one in, move it back if failed
+func replaceRepoDir(newPath string, repoPath string, oldDir string, cmd core.ParsedCmd) {
+	oldPath := ""
+	if _, err := os.Stat(repoPath); err == nil {
+		os.MkdirAll(oldDir, os.ModePerm)
+		oldPath = filepath.Join(oldDir, filepath.Base(repoPath))
+		if err = os.Rename(repoPath, oldPath); err != nil {
+			panic(core.WrapCmdError(cmd, fmt.Errorf("move repo '%s' failed: %v", repoPath, err)))
+		}
+	}
+	if err := os.Rename(newPath, repoPath); err != nil {
+		if len(oldPath) != 0 {
+			os.Rename(oldPath, repoPath)
+		}
+		panic(core.WrapCmdError(cmd, fmt.Errorf("move repo to '%s' failed: %v", repoPath, err)))
+	}
+}
+
+func writeHubLockFile(screen core.Screen, env *core.Env, infos []meta.RepoInfo, cmd core.ParsedCmd) {
+	locks, err := meta.GenRepoLocks(infos)
+	if err != nil {
+		display.PrintErrTitle(screen, env, "lock file not updated: "+err.Error())
+		return
+	}
+	meta.WriteLockFile(getHubLockFilePath(env, cmd), locks, env.GetRaw("strs.proto-sep"))
+}
+
+// Diff the cmds of repos with the snapshots before changing, save the changes and show them
+func recordHubChanges(
+	cc *core.Cli,
+	env *core.Env,
+	infos []meta.RepoInfo,
+	oldSnapshots map[string]meta.RepoSnapshot,
+	cmd core.ParsedCmd) {
+
+	newSnapshots := snapshotHubRepos(cc, env, infos)
+	var changes []meta.RepoChanges
+	for _, info := range infos {
+		newSnapshot, ok := newSnapshots[info.Addr]
+		if !ok {
+			continue
+		}
+		it := meta.DiffRepoSnapshot(info.Addr, info.Ref, oldSnapshots[info.Addr], newSnapshot)
+		if !it.IsEmpty() {
+			changes = append(changes, it)
+		}
+	}
+	meta.WriteRepoChanges(getHubChangesFilePath(env, cmd), changes)
+	if len(changes) != 0 {
+		display.PrintTipTitle(cc.Screen, env, "commands changed by this update:")
+		for _, it := range changes {
+			printRepoChanges(cc.Screen, it)
+		}
+	}
+}
+
+// Load the mods of each enabled git repo to a standalone cmd tree, to snapshot them one by one
+func snapshotHubRepos(cc *core.Cli, env *core.Env, infos []meta.RepoInfo) map[string]meta.RepoSnapshot {
+	snapshots := map[string]meta.RepoSnapshot{}
+	for _, info := range infos {
+		if info.IsLocal() || info.OnOff != "on" {
+			continue
+		}
+		if _, err := os.Stat(info.Path); err != nil {
+			continue
+		}
+		snapshots[info.Addr] = meta.SnapshotCmdTree(loadRepoCmdTree(cc, env, info), info.Path)
+	}
+	return snapshots
+}
+
+// Load the mods of a repo/dir to a standalone cmd tree, the loading errors are ignored
+func loadRepoCmdTree(cc *core.Cli, env *core.Env, info meta.RepoInfo) *core.CmdTree {
+	metaExt := env.GetRaw("strs.meta-ext")
+	flowExt := env.GetRaw("strs.flow-ext")
+	abbrsSep := env.GetRaw("strs.abbrs-sep")
+	envPathSep := env.GetRaw("strs.env-path-sep")
+	reposFileName := env.GetRaw("strs.repos-file-name")
+
+	source := info.Addr
+	if info.IsLocal() {
+		source = info.Path
+	}
+	tmp := *cc
+	tmp.Cmds = core.NewCmdTree(cc.Cmds.Strs)
+	tmp.EnvAbbrs = core.NewEnvAbbrs(cc.Cmds.Strs.RootDisplayName)
+	tmp.TolerableErrs = core.NewTolerableErrs()
+	loadLocalMods(&tmp, info.Path, reposFileName, metaExt, flowExt, abbrsSep, envPathSep, source,
+		0, info.MountPath(cc.Cmds.Strs.PathSep))
+	return tmp.Cmds
+}
+
+// Index the cmds of all repos/dirs in hub (enabled or not), and the repos listed by them but not added
+func buildHubIndex(cc *core.Cli, env *core.Env, infos []meta.RepoInfo, cmd core.ParsedCmd) []meta.RepoIndex {
+	selfName := env.GetRaw("strs.self-name")
+	listFileName := env.GetRaw("strs.repos-file-name")
+
+	var indexes []meta.RepoIndex
+	var listeds []meta.RepoIndex
+	listedIdx := map[string]int{}
+
+	for _, info := range infos {
+		if _, err := os.Stat(info.Path); err != nil {
+			continue
+		}
+		it := meta.RepoIndex{Addr: info.Addr, HelpStr: info.HelpStr}
+		if info.IsLocal() {
+			it.Path = info.Path
+		}
+		it.Cmds = meta.IndexCmdTree(loadRepoCmdTree(cc, env, info))
+		indexes = append(indexes, it)
+
+		repoMeta := meta.ReadRepoMetaFromFile(selfName, filepath.Join(info.Path, listFileName))
+		addrs := append([]string{}, repoMeta.Addrs...)
+		helpStrs := append([]string{}, repoMeta.HelpStrs...)
+		for _, dep := range repoMeta.Depends {
+			addrs = append(addrs, dep.Addr)
+			helpStrs = append(helpStrs, "")
+		}
+		for i, addr := range addrs {
+			addr, _ = meta.SplitAddrAndRef(addr)
+			if _, ok := meta.FindRepoInfo(infos, addr); ok {
+				continue
+			}
+			addr = meta.NormalizeRepoAddr(addr)
+			j, ok := listedIdx[addr]
+			if !ok {
+				j = len(listeds)
+				listedIdx[addr] = j
+				listeds = append(listeds, meta.RepoIndex{Addr: addr})
+			}
+			if len(listeds[j].HelpStr) == 0 {
+				listeds[j].HelpStr = helpStrs[i]
+			}
+			listeds[j].ListedBy = append(listeds[j].ListedBy, repoDisplayName(info))
+		}
+	}
+	indexes = append(indexes, listeds...)
+
+	indexPath := getHubIndexFilePath(env, cmd)
+	olds, _ := meta.ReadRepoIndexes(indexPath)
+	indexes = meta.MergeRepoIndexes(olds, indexes)
+	meta.WriteRepoIndexes(indexPath, indexes)
+	return indexes
+}
+
+func findIndexedRepoInHub(infos []meta.RepoInfo, index meta.RepoIndex) (meta.RepoInfo, bool) {
+	if !index.IsLocal() {
+		return meta.FindRepoInfo(infos, index.Addr)
+	}
+	for _, info := range infos {
+		if info.IsLocal() && info.Path == index.Path {
+			return info, true
+		}
+	}
+	return meta.RepoInfo{}, false
+}
+
+func getHubIndexFilePath(env *core.Env, cmd core.ParsedCmd) string {
+	path := getHubPath(env, cmd)
+	indexFileName := env.GetRaw("strs.hub-index-file-name")
+	if len(indexFileName) == 0 {
+		panic(core.NewCmdError(cmd, "cant't get hub index file name"))
+	}
+	return filepath.Join(path, indexFileName)
+}
+
+func printRepoChanges(screen core.Screen, changes meta.RepoChanges) {
+	screen.Print(fmt.Sprintf("[%s]\n", changes.Name()))
+	for _, path := range changes.Added {
+		screen.Print(fmt.Sprintf("    + %s\n", path))
+	}
+	for _, path := range changes.Removed {
+		screen.Print(fmt.Sprintf("    - %s\n", path))
+	}
+	for _, it := range changes.Modified {
+		screen.Print(fmt.Sprintf("    ~ %s (%s)\n", it.Path, strings.Join(it.Fields, ", ")))
+		for _, line := range it.FlowDiff {
+			screen.Print(fmt.Sprintf("        %s\n", line))
+		}
+	}
+}
+
+func matchFindRepoChanges(changes meta.RepoChanges, findStrs []string) bool {
+	for _, findStr := range findStrs {
+		matched := strings.Index(changes.Addr, findStr) >= 0
+		for _, path := range changes.Added {
+			matched = matched || strings.Index(path, findStr) >= 0
+		}
+		for _, path := range changes.Removed {
+			matched = matched || strings.Index(path, findStr) >= 0
+		}
+		for _, it := range changes.Modified {
+			matched = matched || strings.Index(it.Path, findStr) >= 0
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func getHubChangesFilePath(env *core.Env, cmd core.ParsedCmd) string {
+	path := getHubPath(env, cmd)
+	changesFileName := env.GetRaw("strs.hub-changes-file-name")
+	if len(changesFileName) == 0 {
+		panic(core.NewCmdError(cmd, "cant't get hub changes file name"))
+	}
+	return filepath.Join(path, changesFileName)
+}
+
+func getHubLockFilePath(env *core.Env, cmd core.ParsedCmd) string {
+	path := getHubPath(env, cmd)
+	lockFileName := env.GetRaw("strs.hub-lock-file-name")
+	if len(lockFileName) == 0 {
+		panic(core.NewCmdError(cmd, "cant't get hub lock file name"))
+	}
+	return filepath.Join(path, lockFileName)
+}
+
 func printInfoProps(screen core.Screen, info meta.RepoInfo) {
 	if len(info.HelpStr) > 0 {
 		screen.Print(fmt.Sprintf("     '%s'\n", info.HelpStr))
 	}
+	if len(info.Ref) != 0 {
+		screen.Print(fmt.Sprintf("    - ref:  %s\n", info.Ref))
+	}
+	if len(info.MountPoint) != 0 {
+		screen.Print(fmt.Sprintf("    - mount: %s\n", info.MountPoint))
+	}
+	if !info.IsLocal() && info.Backend().Name() != meta.HubBackendGit {
+		screen.Print(fmt.Sprintf("    - backend: %s\n", info.Backend().Name()))
+	}
 	screen.Print(fmt.Sprintf("    - from: %s\n", getDisplayReason(info)))
 	screen.Print(fmt.Sprintf("    - path: %s\n", info.Path))
 }
 
+func getMountPointArg(argv core.ArgVals, cc *core.Cli) string {
+	pathSep := cc.Cmds.Strs.PathSep
+	return strings.Join(meta.SplitMountPoint(argv.GetRaw("as"), pathSep), pathSep)
+}
+
 func getDisplayReason(info meta.RepoInfo) string {
 	if info.AddReason == info.Addr {
 		return "<manually-added>"
@@ -556,6 +1389,82 @@ func getDisplayReason(info meta.RepoInfo) string {
 	return info.AddReason
 }
 
+// Refuse to enable repos if the depended repos are not in hub or disabled
+func checkEnablingReposDepends(
+	env *core.Env,
+	cmd core.ParsedCmd,
+	enablings []meta.RepoInfo,
+	rest []meta.RepoInfo) {
+
+	repos := map[string]bool{}
+	infos := append([]meta.RepoInfo{}, rest...)
+	for _, info := range enablings {
+		info.OnOff = "on"
+		infos = append(infos, info)
+		if info.IsLocal() {
+			repos[info.Path] = true
+		} else {
+			repos[info.Addr] = true
+		}
+	}
+
+	checks, _ := meta.ResolveRepoDepends(infos, env.GetRaw("strs.repos-file-name"),
+		env.GetRaw("strs.self-name"))
+	var missings []string
+	for _, check := range checks {
+		if repos[check.Repo] && check.IsMissing() {
+			missings = append(missings, fmt.Sprintf("'%s' requires %s",
+				meta.AddrDisplayName(check.Repo), dependCheckStr(check)))
+		}
+	}
+	if len(missings) != 0 {
+		panic(core.NewCmdError(cmd, "dependencies are missing, add or enable them first: "+
+			strings.Join(missings, "; ")))
+	}
+}
+
+// Return false if has any issue
+func printDependIssues(
+	screen core.Screen,
+	env *core.Env,
+	checks []meta.DependCheck,
+	conflicts []meta.DependConflict) bool {
+
+	lines := []interface{}{"dependency issues of repos in hub:", ""}
+	for _, check := range checks {
+		if !check.Ok() {
+			lines = append(lines, fmt.Sprintf("'%s' requires %s",
+				meta.AddrDisplayName(check.Repo), dependCheckStr(check)))
+		}
+	}
+	for _, conflict := range conflicts {
+		version := conflict.Version
+		if len(version) == 0 {
+			version = "no version"
+		}
+		lines = append(lines, fmt.Sprintf("conflicted requirements of '%s' (%s):",
+			meta.AddrDisplayName(conflict.Addr), version))
+		for _, it := range conflict.Requires {
+			lines = append(lines, fmt.Sprintf("    '%s' requires '%s'",
+				meta.AddrDisplayName(it.Repo), it.Depend.Constraint))
+		}
+	}
+	if len(lines) == 2 {
+		return true
+	}
+	display.PrintErrTitle(screen, env, lines...)
+	return false
+}
+
+func dependCheckStr(check meta.DependCheck) string {
+	constraint, _ := meta.ParseVersionConstraint(check.Depend.Constraint)
+	str := fmt.Sprintf("%s %s (%s", check.Depend.Addr, constraint.String(), check.Status)
+	if len(check.Version) != 0 {
+		str += ", " + check.Version
+	}
+	return str + ")"
+}
+
 func checkFoundRepos(env *core.Env, cmd core.ParsedCmd, infos []meta.RepoInfo, findStr string) {
 	if len(infos) == 0 {
 		panic(core.WrapCmdError(cmd, fmt.Errorf("cant't find repo by string '%s'", findStr)))
@@ -589,6 +1498,9 @@ func matchFindRepoInfo(info meta.RepoInfo, findStr string) bool {
 	if strings.Index(info.OnOff, findStr) >= 0 {
 		return true
 	}
+	if strings.Index(info.Ref, findStr) >= 0 {
+		return true
+	}
 
 	// TODO: better place for string "local"
 	if len(info.Addr) == 0 && strings.Index("local", findStr) >= 0 {
@@ -637,6 +1549,11 @@ func enabledStr(env *core.Env, str bool) string {
 	}
 }
 
+// Local dirs and read-only repos are used in place, they are not removed by hub
+func isRepoDirOwned(info meta.RepoInfo) bool {
+	return !info.IsLocal() && !info.Backend().ReadOnly()
+}
+
 func purgedStr(env *core.Env, isLocal bool) string {
 	if isLocal {
 		return " (unlinked)"
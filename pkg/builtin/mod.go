@@ -19,6 +19,11 @@ func SetExtExec(_ core.ArgVals, cc *core.Cli, env *core.Env, _ core.ParsedCmd) b
 	return true
 }
 
+func CheckFlowCycles(_ core.ArgVals, cc *core.Cli, _ *core.Env, _ core.ParsedCmd) bool {
+	mod_meta.UnregFlowCycles(cc)
+	return true
+}
+
 func loadLocalMods(
 	cc *core.Cli,
 	root string,
@@ -27,7 +32,9 @@ func loadLocalMods(
 	flowExt string,
 	abbrsSep string,
 	envPathSep string,
-	source string) {
+	source string,
+	priority int,
+	mountPath []string) {
 
 	if len(root) > 0 && root[len(root)-1] == filepath.Separator {
 		root = root[:len(root)-1]
@@ -35,7 +42,11 @@ func loadLocalMods(
 
 	// TODO: return filepath.SkipDir to avoid some non-sense scanning
 	filepath.Walk(root, func(metaPath string, info fs.FileInfo, err error) error {
-		if info != nil && info.IsDir() {
+		// The repo dir may be missing, eg: an unmounted mirror
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
 			// Skip hidden file or dir
 			base := filepath.Base(metaPath)
 			if len(base) > 0 && base[0] == '.' {
@@ -50,7 +61,7 @@ func loadLocalMods(
 		if strings.HasSuffix(metaPath, flowExt) {
 			cmdPath := filepath.Base(metaPath[0 : len(metaPath)-len(flowExt)])
 			cmdPaths := strings.Split(cmdPath, cc.Cmds.Strs.PathSep)
-			mod_meta.RegMod(cc, metaPath, "", false, true, cmdPaths, abbrsSep, envPathSep, source)
+			mod_meta.RegMod(cc, metaPath, "", false, true, mountPath, cmdPaths, abbrsSep, envPathSep, source, priority)
 			return nil
 		}
 
@@ -80,7 +91,7 @@ func loadLocalMods(
 		}
 
 		cmdPaths := strings.Split(cmdPath, string(filepath.Separator))
-		mod_meta.RegMod(cc, metaPath, targetPath, isDir, false, cmdPaths, abbrsSep, envPathSep, source)
+		mod_meta.RegMod(cc, metaPath, targetPath, isDir, false, mountPath, cmdPaths, abbrsSep, envPathSep, source, priority)
 		return nil
 	})
 }
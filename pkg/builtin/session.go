@@ -0,0 +1,217 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+	meta "github.com/pingcap/ticat/pkg/proto/session_meta"
+)
+
+func ListSessions(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	findStrs := getFindStrsFromArgv(argv)
+	sessions := listSessions(env, cmd)
+
+	screen := display.NewCacheScreen()
+	for _, session := range sessions {
+		if !matchFindSession(session, findStrs) {
+			continue
+		}
+		printSessionInfo(screen, env, session)
+	}
+
+	if screen.OutputNum() <= 0 {
+		if len(findStrs) != 0 {
+			display.PrintTipTitle(cc.Screen, env, "no matched session.")
+		} else {
+			display.PrintTipTitle(cc.Screen, env, "no session in local.")
+		}
+		return true
+	}
+	display.PrintTipTitle(cc.Screen, env, "session list:")
+	screen.WriteTo(cc.Screen)
+	display.PrintTipTitle(cc.Screen, env,
+		"use 'session.show <id>' to see the env of a session,",
+		"use 'session.attach <id> : <commands>' to run commands on top of a session.")
+	return true
+}
+
+func ShowSession(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	session := findSession(argv.GetRaw("id"), env, cmd)
+	printSessionInfo(cc.Screen, env, session)
+
+	envFileName := env.GetRaw("strs.session-env-file")
+	sessionEnv := core.NewEnv()
+	core.LoadEnvFromFile(sessionEnv, filepath.Join(session.Dir, envFileName), env.GetRaw("strs.proto-sep"))
+	keys, vals := sessionEnv.Pairs()
+	if len(keys) == 0 {
+		cc.Screen.Print("    - env: (empty)\n")
+		return true
+	}
+	kvs := map[string]string{}
+	for i, key := range keys {
+		kvs[key] = vals[i].Raw
+	}
+	sort.Strings(keys)
+	cc.Screen.Print("    - env:\n")
+	for _, key := range keys {
+		cc.Screen.Print(fmt.Sprintf("        %s = %s\n", key, kvs[key]))
+	}
+	return true
+}
+
+// Take over an old session: load its env, and the following commands will write to it
+func AttachSession(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	session := findSession(getAndCheckArg(argv, env, cmd, "id"), env, cmd)
+	statusFileName := getSessionStatusFileName(env, cmd)
+	pid := os.Getpid()
+
+	curr := env.GetRaw("session")
+	if session.Dir == curr {
+		display.PrintTipTitle(cc.Screen, env, "already in session '"+session.Id+"'.")
+		return true
+	}
+	if session.Pid != pid && session.IsAlive() {
+		panic(core.NewCmdError(cmd, fmt.Sprintf("session '%s' is still running by pid %d",
+			session.Id, session.Pid)))
+	}
+
+	sessionEnv := env.GetLayer(core.EnvLayerSession)
+	envFileName := env.GetRaw("strs.session-env-file")
+	core.LoadEnvFromFile(sessionEnv, filepath.Join(session.Dir, envFileName), env.GetRaw("strs.proto-sep"))
+	sessionEnv.Set("session", session.Dir)
+
+	// The session created by this process is empty, replace it by the attached one
+	flow := session.Flow
+	if len(curr) != 0 {
+		currStatus := meta.LoadSessionStatus(curr, statusFileName)
+		if currStatus.Pid == pid {
+			if len(currStatus.Flow) != 0 {
+				flow = strings.TrimSpace(flow + "\n" + currStatus.Flow)
+			}
+			err := meta.RemoveSession(currStatus)
+			if err != nil {
+				panic(core.NewCmdError(cmd, fmt.Sprintf("remove the replaced session '%s' failed: %v",
+					currStatus.Dir, err)))
+			}
+		}
+	}
+	session.Pid = pid
+	session.Status = meta.StatusRunning
+	session.FinishTs = time.Time{}
+	session.Flow = flow
+	meta.SaveSessionStatus(session, statusFileName)
+
+	display.PrintTipTitle(cc.Screen, env, "attached to session '"+session.Id+"'.")
+	return true
+}
+
+func GcSessions(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	root := env.GetRaw("sys.paths.sessions")
+	if len(root) == 0 {
+		panic(core.NewCmdError(cmd, "env 'sys.paths.sessions' is empty"))
+	}
+	policy, err := meta.ParseGcPolicy(env.GetRaw("sys.session.keep-duration"),
+		env.GetRaw("sys.session.keep-count"))
+	if err != nil {
+		panic(core.WrapCmdError(cmd, err))
+	}
+	removed, err := meta.GcSessions(root, getSessionStatusFileName(env, cmd), policy, time.Now())
+	for _, session := range removed {
+		cc.Screen.Print(fmt.Sprintf("[%s] (removed)\n", session.Id))
+	}
+	if err != nil {
+		panic(core.WrapCmdError(cmd, err))
+	}
+	if len(removed) == 0 {
+		display.PrintTipTitle(cc.Screen, env, "no session need to be removed.")
+	} else {
+		display.PrintTipTitle(cc.Screen, env, fmt.Sprintf("%d sessions removed.", len(removed)))
+	}
+	return true
+}
+
+func listSessions(env *core.Env, cmd core.ParsedCmd) []meta.SessionStatus {
+	root := env.GetRaw("sys.paths.sessions")
+	if len(root) == 0 {
+		panic(core.NewCmdError(cmd, "env 'sys.paths.sessions' is empty"))
+	}
+	return meta.ListSessions(root, getSessionStatusFileName(env, cmd))
+}
+
+// Find the latest one (excluding the current one) if the find-str is empty
+func findSession(findStr string, env *core.Env, cmd core.ParsedCmd) meta.SessionStatus {
+	sessions := listSessions(env, cmd)
+	if len(findStr) == 0 {
+		curr := env.GetRaw("session")
+		for i := len(sessions) - 1; i >= 0; i-- {
+			if sessions[i].Dir != curr {
+				return sessions[i]
+			}
+		}
+		panic(core.NewCmdError(cmd, "no session in local"))
+	}
+
+	matched := meta.FindSessions(sessions, findStr)
+	if len(matched) == 0 {
+		panic(core.NewCmdError(cmd, fmt.Sprintf("can't find session by string '%s'", findStr)))
+	}
+	if len(matched) > 1 {
+		var ids []string
+		for _, it := range matched {
+			ids = append(ids, it.Id)
+		}
+		panic(core.NewCmdError(cmd, fmt.Sprintf("more than one session matched by string '%s': %s",
+			findStr, strings.Join(ids, ", "))))
+	}
+	return matched[0]
+}
+
+func matchFindSession(session meta.SessionStatus, findStrs []string) bool {
+	for _, findStr := range findStrs {
+		if strings.Index(session.Id, findStr) < 0 &&
+			strings.Index(session.Flow, findStr) < 0 &&
+			session.DisplayStatus() != findStr {
+			return false
+		}
+	}
+	return true
+}
+
+func printSessionInfo(screen core.Screen, env *core.Env, session meta.SessionStatus) {
+	status := session.DisplayStatus()
+	if session.Dir == env.GetRaw("session") {
+		status += ", current"
+	}
+	screen.Print(fmt.Sprintf("[%s] (%s)\n", session.Id, status))
+	if len(session.Flow) != 0 {
+		lines := strings.Split(session.Flow, "\n")
+		screen.Print(fmt.Sprintf("    - flow: %s\n", lines[0]))
+		for _, line := range lines[1:] {
+			screen.Print(fmt.Sprintf("            %s\n", line))
+		}
+	}
+	if session.Pid > 0 {
+		screen.Print(fmt.Sprintf("    - pid: %d\n", session.Pid))
+	}
+	if !session.StartTs.IsZero() {
+		screen.Print(fmt.Sprintf("    - start: %s\n", session.StartTs.Format("2006-01-02 15:04:05")))
+	}
+	if !session.FinishTs.IsZero() {
+		screen.Print(fmt.Sprintf("    - finish: %s\n", session.FinishTs.Format("2006-01-02 15:04:05")))
+	}
+	screen.Print(fmt.Sprintf("    - path: %s\n", session.Dir))
+}
+
+func getSessionStatusFileName(env *core.Env, cmd core.ParsedCmd) string {
+	name := env.GetRaw("strs.session-status-file")
+	if len(name) == 0 {
+		panic(core.NewCmdError(cmd, "env 'strs.session-status-file' is empty"))
+	}
+	return name
+}
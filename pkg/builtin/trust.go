@@ -0,0 +1,250 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/display"
+	meta "github.com/pingcap/ticat/pkg/proto/hub_meta"
+	"github.com/pingcap/ticat/pkg/utils"
+)
+
+func TrustRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	addr := argv.GetRaw("addr")
+	trustPath := getHubTrustFilePath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	list := readTrustList(cc, env, cmd)
+
+	if len(addr) == 0 {
+		listRepoTrusts(cc.Screen, env, list, cmd)
+		return true
+	}
+
+	if !list.Allow(addr) {
+		display.PrintTipTitle(cc.Screen, env, "'"+addr+"' is already in the allowlist")
+		return true
+	}
+	meta.WriteTrustFile(trustPath, list, fieldSep)
+	display.PrintTipTitle(cc.Screen, env,
+		"'"+addr+"' added to the allowlist, commands from the matched repos will run without confirmation")
+	return true
+}
+
+func UntrustRepoInHub(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+	findStr := getAndCheckArg(argv, env, cmd, "find-str")
+	trustPath := getHubTrustFilePath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	list := readTrustList(cc, env, cmd)
+
+	removeds := list.Remove(findStr)
+	if len(removeds) == 0 {
+		display.PrintTipTitle(cc.Screen, env,
+			"no allowlist entries or review records matched find string '"+findStr+"'")
+		return true
+	}
+	meta.WriteTrustFile(trustPath, list, fieldSep)
+	for _, it := range removeds {
+		cc.Screen.Print(fmt.Sprintf("[%s] (removed)\n", it))
+	}
+	display.PrintTipTitle(cc.Screen, env,
+		fmt.Sprintf("%d allowlist entries or review records removed", len(removeds)))
+	return true
+}
+
+// Commands from untrusted repos need confirmation before running, the confirmed commit will be recorded,
+// so only the first run of a new commit need confirmation.
+// It's called for each flow (including the sub flows of flow cmds), the decisions are cached in the process
+type RepoTrustChecker struct {
+	trusted map[string]bool
+}
+
+func NewRepoTrustChecker() *RepoTrustChecker {
+	return &RepoTrustChecker{map[string]bool{}}
+}
+
+func (self *RepoTrustChecker) CheckFlow(cc *core.Cli, flow *core.ParsedCmds, env *core.Env) bool {
+	if !env.GetBool("sys.hub.trust.check") {
+		return true
+	}
+
+	var infos []meta.RepoInfo
+	var list *meta.TrustList
+	for _, cmd := range flow.Cmds {
+		cic := cmd.LastCmd()
+		if cic == nil {
+			continue
+		}
+		if cic.Type() != core.CmdTypeFile && cic.Type() != core.CmdTypeDirWithCmd {
+			continue
+		}
+		source := cic.Source()
+		if len(source) == 0 || self.trusted[source] {
+			continue
+		}
+		// Mocked commands in flow tests are not executed
+		if cc.TestHooks != nil {
+			if _, ok := cc.TestHooks.Mocks[cic]; ok {
+				continue
+			}
+		}
+		// Only read the meta files when there are undecided repos
+		if list == nil {
+			fieldSep := env.GetRaw("strs.proto-sep")
+			infos, _ = meta.ReadReposInfoFile(getReposInfoPath(env, cmd), true, fieldSep)
+			list = readTrustList(cc, env, cmd)
+		}
+		if !checkCmdTrusted(cc, env, cmd, infos, list) {
+			return false
+		}
+		self.trusted[source] = true
+	}
+	return true
+}
+
+func checkCmdTrusted(
+	cc *core.Cli,
+	env *core.Env,
+	cmd core.ParsedCmd,
+	infos []meta.RepoInfo,
+	list *meta.TrustList) bool {
+
+	// Local dirs are not checked
+	info, ok := meta.FindRepoInfo(infos, cmd.LastCmd().Source())
+	if !ok {
+		return true
+	}
+	trusted, reason := getRepoTrust(env, list, info)
+	if trusted {
+		return true
+	}
+
+	name := repoDisplayName(info)
+	cmdPath := cmd.DisplayPath(cc.Cmds.Strs.PathSep, true)
+	selfName := env.GetRaw("strs.self-name")
+	lines := []interface{}{
+		fmt.Sprintf("command '%s' is from untrusted repo '%s': %s.", cmdPath, name, reason),
+		"",
+		"review the repo in '" + info.Path + "' before running it,",
+		"or add it to the allowlist by:",
+		"",
+		"    " + selfName + " hub.trust " + info.Addr,
+	}
+	if !env.GetBool("sys.interact") {
+		lines = append(lines, "", "it can't be confirmed because 'sys.interact' is false.")
+		display.PrintErrTitle(cc.Screen, env, lines...)
+		return false
+	}
+
+	display.PrintErrTitle(cc.Screen, env, lines...)
+	cc.Screen.Print("[confirm] type 'y' and press enter to run it, 'n' to abort:\n")
+	if !utils.UserConfirmOrDecline() {
+		cc.Screen.Print("[confirm] not confirmed, aborted\n")
+		return false
+	}
+
+	commit, err := meta.GetRepoCommit(info.Addr, info.Path)
+	if err == nil {
+		list.SetReviewed(info.Addr, commit)
+		meta.WriteTrustFile(getHubTrustFilePath(env, cmd), list, env.GetRaw("strs.proto-sep"))
+	}
+	return true
+}
+
+// If the trust file doesn't exist (eg: upgraded from a version without trust checking),
+// it's created with the current commits of the repos already in hub as reviewed, so they keep running
+func readTrustList(cc *core.Cli, env *core.Env, cmd core.ParsedCmd) *meta.TrustList {
+	trustPath := getHubTrustFilePath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	if fileExists(trustPath) {
+		return meta.ReadTrustFile(trustPath, fieldSep)
+	}
+	infos, _ := meta.ReadReposInfoFile(getReposInfoPath(env, cmd), true, fieldSep)
+	list := meta.NewTrustList()
+	count := list.ReviewRepos(infos)
+	os.MkdirAll(filepath.Dir(trustPath), os.ModePerm)
+	meta.WriteTrustFile(trustPath, list, fieldSep)
+	if count != 0 {
+		display.PrintTipTitle(cc.Screen, env,
+			fmt.Sprintf("%d repos already in hub are trusted at their current commits,", count),
+			"they need confirmation after being changed.")
+	}
+	return list
+}
+
+// A repo is trusted if it's in the allowlist, or signed by a configured key,
+// or the current commit is reviewed by user
+func getRepoTrust(env *core.Env, list *meta.TrustList, info meta.RepoInfo) (trusted bool, reason string) {
+	if info.IsLocal() {
+		return true, "local dir"
+	}
+	var keys []string
+	for _, key := range strings.Split(env.GetRaw("sys.hub.trust.keys"), ",") {
+		if len(strings.TrimSpace(key)) != 0 {
+			keys = append(keys, key)
+		}
+	}
+	requireSig := env.GetBool("sys.hub.trust.require-signature")
+	signer, signed := meta.VerifyRepoSignature(info.Path, info.Ref, keys)
+	allow := list.MatchedAllow(info.Addr)
+
+	if len(allow) != 0 && signed {
+		return true, "in allowlist by '" + allow + "', signed by key " + signer
+	}
+	if len(allow) != 0 && !requireSig {
+		return true, "in allowlist by '" + allow + "'"
+	}
+	if signed {
+		return true, "signed by key " + signer
+	}
+
+	commit, _ := meta.GetRepoCommit(info.Addr, info.Path)
+	if list.IsReviewed(info.Addr, commit) {
+		return true, "reviewed at commit " + shortCommitStr(commit)
+	}
+	if reviewed, ok := list.Revieweds[info.Addr]; ok {
+		return false, "changed since reviewed at commit " + shortCommitStr(reviewed)
+	}
+	if len(allow) != 0 {
+		return false, "in allowlist by '" + allow + "', but not signed by the configured keys"
+	}
+	return false, "not in allowlist"
+}
+
+func listRepoTrusts(screen core.Screen, env *core.Env, list *meta.TrustList, cmd core.ParsedCmd) {
+	metaPath := getReposInfoPath(env, cmd)
+	fieldSep := env.GetRaw("strs.proto-sep")
+	infos, _ := meta.ReadReposInfoFile(metaPath, true, fieldSep)
+
+	if len(list.Allows) != 0 {
+		display.PrintTipTitle(screen, env, "allowlist:")
+		for _, allow := range list.Allows {
+			screen.Print(fmt.Sprintf("    %s\n", allow))
+		}
+	}
+
+	display.PrintTipTitle(screen, env, "trust status of repos in hub:")
+	for _, info := range infos {
+		if info.IsLocal() {
+			continue
+		}
+		trusted, reason := getRepoTrust(env, list, info)
+		screen.Print(fmt.Sprintf("[%s]", repoDisplayName(info)))
+		if !trusted {
+			screen.Print(" (untrusted)")
+		}
+		screen.Print("\n")
+		screen.Print(fmt.Sprintf("    - trust: %s\n", reason))
+	}
+}
+
+func getHubTrustFilePath(env *core.Env, cmd core.ParsedCmd) string {
+	path := getHubPath(env, cmd)
+	trustFileName := env.GetRaw("strs.hub-trust-file-name")
+	if len(trustFileName) == 0 {
+		panic(core.NewCmdError(cmd, "cant't get hub trust file name"))
+	}
+	return filepath.Join(path, trustFileName)
+}
@@ -14,6 +14,7 @@ type Args struct {
 	orderedList []string
 	abbrs       map[string][]string
 	abbrsRevIdx map[string]string
+	helps       map[string]string
 }
 
 func newArgs() Args {
@@ -23,6 +24,7 @@ func newArgs() Args {
 		[]string{},
 		map[string][]string{},
 		map[string]string{},
+		map[string]string{},
 	}
 }
 
@@ -66,6 +68,21 @@ func (self *Args) DefVal(name string) string {
 	return self.defVals[name]
 }
 
+func (self *Args) SetHelp(owner *CmdTree, name string, help string) {
+	if _, ok := self.names[name]; !ok {
+		panic(fmt.Errorf("[Args.SetHelp] %s: arg '%s' not found", owner.DisplayPath(), name))
+	}
+	self.helps[name] = help
+}
+
+func (self *Args) Help(name string) string {
+	return self.helps[name]
+}
+
+func (self *Args) Has(name string) bool {
+	return self.names[name]
+}
+
 func (self *Args) Realname(nameOrAbbr string) string {
 	name, _ := self.abbrsRevIdx[nameOrAbbr]
 	return name
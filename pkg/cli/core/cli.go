@@ -8,6 +8,11 @@ type Screen interface {
 	OutputNum() int
 }
 
+// A screen could also capture the stdout/stderr of the mods, eg: to check the output in tests
+type ModOutputCapturer interface {
+	CaptureModOutput() bool
+}
+
 type Executor interface {
 	Execute(cc *Cli, input ...string) bool
 }
@@ -20,6 +25,10 @@ type Cli struct {
 	EnvAbbrs      *EnvAbbrs
 	TolerableErrs *TolerableErrs
 	Executor      Executor
+	// The flows being executed, for cycle detecting
+	FlowStack FlowStack
+	// Not nil when running flow tests
+	TestHooks *TestHooks
 }
 
 func NewCli(env *Env, screen Screen, cmds *CmdTree, parser CliParser, abbrs *EnvAbbrs) *Cli {
@@ -31,5 +40,7 @@ func NewCli(env *Env, screen Screen, cmds *CmdTree, parser CliParser, abbrs *Env
 		abbrs,
 		NewTolerableErrs(),
 		nil,
+		nil,
+		nil,
 	}
 }
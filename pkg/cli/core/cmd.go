@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -45,6 +46,13 @@ type Cmd struct {
 	metaFilePath string
 	val2env      *Val2Env
 	arg2env      *Arg2Env
+	stdout2env   *Stdout2Env
+	protocol     ModProtocol
+	// The priority of the repo/dir this cmd comes from, higher one could shadow lower one
+	sourcePriority int
+	shadowed       *Cmd
+	// The sub tree path the repo/dir of this cmd mounted to, empty means the root
+	mountPath []string
 }
 
 func defaultCmd(owner *CmdTree, help string) *Cmd {
@@ -65,6 +73,12 @@ func defaultCmd(owner *CmdTree, help string) *Cmd {
 		metaFilePath: "",
 		val2env:      newVal2Env(),
 		arg2env:      newArg2Env(),
+		stdout2env:   newStdout2Env(),
+		protocol:     ModProtocolEnvFile,
+
+		sourcePriority: 0,
+		shadowed:       nil,
+		mountPath:      nil,
 	}
 }
 
@@ -129,11 +143,11 @@ func (self *Cmd) Execute(
 	case CmdTypeNormal:
 		return currCmdIdx, self.normal(argv, cc, env, flow.Cmds[currCmdIdx])
 	case CmdTypeFile:
-		return currCmdIdx, self.executeFile(argv, cc, env)
+		return self.executeFile(argv, cc, env, flow, currCmdIdx)
 	case CmdTypeEmptyDir:
 		return currCmdIdx, true
 	case CmdTypeDirWithCmd:
-		return currCmdIdx, self.executeFile(argv, cc, env)
+		return self.executeFile(argv, cc, env, flow, currCmdIdx)
 	case CmdTypeFlow:
 		return currCmdIdx, self.executeFlow(argv, cc, env)
 	case CmdTypeEmpty:
@@ -162,6 +176,9 @@ func (self *Cmd) MatchFind(findStr string) bool {
 	if self.arg2env.MatchFind(findStr) {
 		return true
 	}
+	if self.stdout2env.MatchFind(findStr) {
+		return true
+	}
 	if self.envOps.MatchFind(findStr) {
 		return true
 	}
@@ -202,6 +219,11 @@ func (self *Cmd) AddArg(name string, defVal string, abbrs ...string) *Cmd {
 	return self
 }
 
+func (self *Cmd) SetArgHelp(name string, help string) *Cmd {
+	self.args.SetHelp(self.owner, name, help)
+	return self
+}
+
 func (self *Cmd) AddEnvOp(name string, op uint) *Cmd {
 	self.envOps.AddOp(name, op)
 	return self
@@ -216,11 +238,31 @@ func (self *Cmd) SetSource(s string) *Cmd {
 	return self
 }
 
+func (self *Cmd) SetSourcePriority(priority int) *Cmd {
+	self.sourcePriority = priority
+	return self
+}
+
+func (self *Cmd) SetShadowed(old *Cmd) *Cmd {
+	self.shadowed = old
+	return self
+}
+
+func (self *Cmd) SetMountPath(path []string) *Cmd {
+	self.mountPath = path
+	return self
+}
+
 func (self *Cmd) SetMetaFile(path string) *Cmd {
 	self.metaFilePath = path
 	return self
 }
 
+func (self *Cmd) SetProtocol(protocol ModProtocol) *Cmd {
+	self.protocol = protocol
+	return self
+}
+
 func (self *Cmd) AddDepend(dep string, reason string) *Cmd {
 	self.depends = append(self.depends, Depend{dep, reason})
 	return self
@@ -246,6 +288,11 @@ func (self *Cmd) AddArg2Env(envKey string, argName string) *Cmd {
 	return self
 }
 
+func (self *Cmd) AddStdout2Env(envKey string, pattern string) *Cmd {
+	self.stdout2env.Add(envKey, pattern)
+	return self
+}
+
 func (self *Cmd) GetVal2Env() *Val2Env {
 	return self.val2env
 }
@@ -254,6 +301,10 @@ func (self *Cmd) GetArg2Env() *Arg2Env {
 	return self.arg2env
 }
 
+func (self *Cmd) GetStdout2Env() *Stdout2Env {
+	return self.stdout2env
+}
+
 func (self *Cmd) GetDepends() []Depend {
 	return self.depends
 }
@@ -262,6 +313,10 @@ func (self *Cmd) MetaFile() string {
 	return self.metaFilePath
 }
 
+func (self *Cmd) Protocol() ModProtocol {
+	return self.protocol
+}
+
 func (self *Cmd) Owner() *CmdTree {
 	return self.owner
 }
@@ -270,6 +325,32 @@ func (self *Cmd) Source() string {
 	return self.source
 }
 
+func (self *Cmd) SourcePriority() int {
+	return self.sourcePriority
+}
+
+// The cmd with the same path from a lower priority repo/dir
+func (self *Cmd) Shadowed() *Cmd {
+	return self.shadowed
+}
+
+func (self *Cmd) MountPath() []string {
+	return self.mountPath
+}
+
+// The parser for the flow of this cmd, if the repo/dir of this cmd is mounted to a sub tree,
+// the cmds are looked up in that sub tree first, then from the root
+func (self *Cmd) FlowParser(cc *Cli) CliParser {
+	parser := cc.Parser
+	if mounted, ok := parser.(mountedParser); ok {
+		parser = mounted.parser
+	}
+	if len(self.mountPath) == 0 {
+		return parser
+	}
+	return mountedParser{parser, self.mountPath, self.owner.Strs.PathSep}
+}
+
 func (self *Cmd) Help() string {
 	return self.help
 }
@@ -324,47 +405,62 @@ func (self *Cmd) FlowStrs() []string {
 }
 
 // TODO: move to parser ?
-func (self *Cmd) RenderedFlowStrs(env *Env, allowFlowTemplateRenderError bool) (flow []string, rendered bool) {
+// The args of a flow are its params, a template '[[key]]' is rendered by the arg value first, then the env value.
+// All templates are checked before rendering, so all missed keys are reported at once
+func (self *Cmd) RenderedFlowStrs(
+	env *Env,
+	argv ArgVals,
+	allowFlowTemplateRenderError bool) (flow []string, rendered bool) {
+
 	templBracketLeft := self.owner.Strs.FlowTemplateBracketLeft
 	templBracketRight := self.owner.Strs.FlowTemplateBracketRight
+	templDefValSep := self.owner.Strs.FlowTemplateDefaultSep
 	for _, it := range self.flow {
-		for {
-			i := strings.Index(it, templBracketLeft)
-			if i < 0 {
-				break
-			}
-			tail := it[i+len(templBracketLeft):]
-			j := strings.Index(tail, templBracketRight)
-			if j < 0 {
-				break
-			}
-			key := tail[0:j]
-			if env == nil {
-				return self.flow, false
-			}
-			val, ok := env.GetEx(key)
-			if !ok {
-				if allowFlowTemplateRenderError {
-					return self.flow, false
-				}
-				err := CmdMissedEnvValWhenRenderFlow{
-					"render flow template failed, env value missed.",
-					self.owner.DisplayPath(),
-					self.metaFilePath,
-					self.source,
-					key}
-				panic(err)
+		if strings.Index(it, templBracketLeft) >= 0 && env == nil {
+			return self.flow, false
+		}
+	}
+
+	lookup := func(key string) (string, bool) {
+		if val, ok := argv[key]; ok && (val.Provided || len(val.Raw) != 0) {
+			return val.Raw, true
+		}
+		val, ok := env.GetRenderedEx(key)
+		return val.Raw, ok
+	}
+
+	missedKeys := TemplateMissedKeys(strings.Join(self.flow, "\n"),
+		templBracketLeft, templBracketRight, templDefValSep, lookup)
+	if len(missedKeys) != 0 {
+		if allowFlowTemplateRenderError {
+			return self.flow, false
+		}
+		var missedParams []string
+		for _, key := range missedKeys {
+			if self.args.Has(key) {
+				missedParams = append(missedParams, key)
 			}
-			it = it[0:i] + val.Raw + tail[j+len(templBracketRight):]
 		}
+		err := CmdMissedEnvValWhenRenderFlow{
+			"render flow template failed, env value missed.",
+			self.owner.DisplayPath(),
+			self.metaFilePath,
+			self.source,
+			missedKeys,
+			missedParams}
+		panic(err)
+	}
+
+	for _, it := range self.flow {
+		it, _, _ = RenderTemplateStr(it, templBracketLeft, templBracketRight, templDefValSep, lookup)
 		flow = append(flow, it)
 	}
 	rendered = true
 	return
 }
 
-func (self *Cmd) Flow(env *Env, allowFlowTemplateRenderError bool) (flow []string, rendered bool) {
-	flow, rendered = self.RenderedFlowStrs(env, allowFlowTemplateRenderError)
+func (self *Cmd) Flow(env *Env, argv ArgVals, allowFlowTemplateRenderError bool) (flow []string, rendered bool) {
+	flow, rendered = self.RenderedFlowStrs(env, argv, allowFlowTemplateRenderError)
 	if !rendered || len(flow) == 0 {
 		return
 	}
@@ -396,13 +492,23 @@ func (self *Cmd) IsTheSameFunc(fun interface{}) bool {
 }
 
 func (self *Cmd) executeFlow(argv ArgVals, cc *Cli, env *Env) bool {
-	flow, _ := self.Flow(env, false)
-	return cc.Executor.Execute(cc, flow...)
+	flow, _ := self.Flow(env, argv, false)
+	inner := *cc
+	inner.Parser = self.FlowParser(cc)
+	inner.FlowStack = cc.FlowStack.Push(self, flow)
+	inner.FlowStack.CheckDepth(env.GetInt("sys.stack-depth"), env.GetInt("sys.flow.max-depth"))
+	return cc.Executor.Execute(&inner, flow...)
 }
 
-func (self *Cmd) executeFile(argv ArgVals, cc *Cli, env *Env) bool {
+func (self *Cmd) executeFile(
+	argv ArgVals,
+	cc *Cli,
+	env *Env,
+	flow *ParsedCmds,
+	currCmdIdx int) (int, bool) {
+
 	if len(self.cmdLine) == 0 {
-		return true
+		return currCmdIdx, true
 	}
 
 	for _, dep := range self.depends {
@@ -435,8 +541,17 @@ func (self *Cmd) executeFile(argv ArgVals, cc *Cli, env *Env) bool {
 
 	sessionDir, sessionPath := saveEnvToSessionFile(cc, env)
 
-	args = append(args, self.cmdLine)
-	args = append(args, sessionDir)
+	// The env file is still provided in json protocol, for calling ticat inside the mod
+	var inputPath string
+	var outputPath string
+	if self.protocol == ModProtocolJson {
+		inputPath, outputPath = self.prepareJsonProtocolFiles(argv, env, sessionDir, sessionPath)
+		defer os.Remove(inputPath)
+		defer os.Remove(outputPath)
+		args = append(args, self.cmdLine, inputPath)
+	} else {
+		args = append(args, self.cmdLine, sessionDir)
+	}
 	for _, k := range self.args.Names() {
 		args = append(args, argv[k].Raw)
 	}
@@ -445,8 +560,19 @@ func (self *Cmd) executeFile(argv ArgVals, cc *Cli, env *Env) bool {
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if capturer, ok := cc.Screen.(ModOutputCapturer); ok && capturer.CaptureModOutput() {
+		cmd.Stdout = screenWriter{cc.Screen}
+		cmd.Stderr = screenWriter{cc.Screen}
+	}
 
-	err := cmd.Run()
+	var err error
+	var stdout string
+	capture := len(self.stdout2env.EnvKeys()) != 0
+	if capture {
+		stdout, err = RunAndCaptureStdout(cmd, cc.Screen)
+	} else {
+		err = cmd.Run()
+	}
 	if err != nil {
 		indent1 := strings.Repeat(" ", 4)
 		indent2 := strings.Repeat(" ", 8)
@@ -462,13 +588,65 @@ func (self *Cmd) executeFile(argv ArgVals, cc *Cli, env *Env) bool {
 		cc.Screen.Print(fmt.Sprintf("%s- file: %s\n", indent1, self.cmdLine))
 		cc.Screen.Print(fmt.Sprintf("%s- env:  %s\n", indent1, sessionPath))
 		cc.Screen.Print(fmt.Sprintf("%s- err:  %s\n", indent1, err))
-		return false
+		return currCmdIdx, false
+	}
+
+	LoadRenderedEnvFromFile(env.GetLayer(EnvLayerSession), sessionPath, sep)
+
+	if capture && !self.applyStdout2Env(cc, env, stdout) {
+		return currCmdIdx, false
 	}
 
-	LoadEnvFromFile(env.GetLayer(EnvLayerSession), sessionPath, sep)
+	if self.protocol == ModProtocolJson {
+		output, ok := LoadModJsonOutput(outputPath)
+		if ok {
+			ApplyModJsonOutput(output, env, cc.Screen)
+			if newCmdIdx, skipped := ModJsonOutputNextCmdIdx(output, flow, currCmdIdx); skipped {
+				cc.Screen.Print(fmt.Sprintf("[%s] skipped the remaining commands of the flow\n",
+					self.owner.DisplayPath()))
+				return newCmdIdx, true
+			}
+		}
+	}
+	return currCmdIdx, true
+}
+
+func (self *Cmd) applyStdout2Env(cc *Cli, env *Env, stdout string) bool {
+	sessionEnv := env.GetLayer(EnvLayerSession)
+	for _, key := range self.stdout2env.EnvKeys() {
+		val, ok := self.stdout2env.Extract(key, stdout)
+		if !ok {
+			cc.Screen.Print(fmt.Sprintf("\n[%s] failed:\n", self.owner.DisplayPath()))
+			cc.Screen.Print(fmt.Sprintf("    - err: pattern '%s' of env key '%s' not found in stdout\n",
+				self.stdout2env.Pattern(key), key))
+			return false
+		}
+		sessionEnv.Set(key, val)
+	}
 	return true
 }
 
+func (self *Cmd) prepareJsonProtocolFiles(
+	argv ArgVals,
+	env *Env,
+	sessionDir string,
+	sessionPath string) (inputPath string, outputPath string) {
+
+	file, err := ioutil.TempFile(sessionDir, "mod-*.input.json")
+	if err != nil {
+		panic(fmt.Errorf("[Cmd.executeFile] create json input file in '%s' failed: %v",
+			sessionDir, err))
+	}
+	inputPath = file.Name()
+	file.Close()
+	outputPath = strings.TrimSuffix(inputPath, ".input.json") + ".output.json"
+
+	input := NewModJsonInput(self.owner.DisplayPath(), self.args.Names(), argv, env,
+		sessionDir, sessionPath, outputPath)
+	SaveModJsonInput(input, inputPath)
+	return
+}
+
 func saveEnvToSessionFile(cc *Cli, env *Env) (sessionDir string, sessionPath string) {
 	sep := cc.Cmds.Strs.ProtoSep
 
@@ -481,7 +659,7 @@ func saveEnvToSessionFile(cc *Cli, env *Env) (sessionDir string, sessionPath str
 		panic(fmt.Errorf("[Cmd.executeFile] session env file name not found in env"))
 	}
 	sessionPath = filepath.Join(sessionDir, sessionFileName)
-	SaveEnvToFile(env.GetLayer(EnvLayerSession), sessionPath, sep)
+	SaveRenderedEnvToFile(env.GetLayer(EnvLayerSession), sessionPath, sep)
 	return
 }
 
@@ -20,6 +20,7 @@ type CmdTreeStrs struct {
 	ProtoSep                 string
 	FlowTemplateBracketLeft  string
 	FlowTemplateBracketRight string
+	FlowTemplateDefaultSep   string
 }
 
 type CmdTree struct {
@@ -80,6 +81,25 @@ func (self *CmdTree) cmdConflictCheck(help string, funName string) {
 	panic(err)
 }
 
+// Remove the cmd if it's from a lower priority repo/dir, return the removed one.
+// Builtin cmds and cmds from the same source can't be shadowed.
+func (self *CmdTree) ShadowCmd(source string, priority int) *Cmd {
+	old := self.cmd
+	if old == nil || old.Type() == CmdTypeEmptyDir {
+		return nil
+	}
+	if len(old.Source()) == 0 || old.Source() == source || priority <= old.SourcePriority() {
+		return nil
+	}
+	self.cmd = nil
+	return old
+}
+
+// Put back a shadowed cmd, when the new one failed to register
+func (self *CmdTree) RestoreCmd(old *Cmd) {
+	self.cmd = old
+}
+
 func (self *CmdTree) SetHidden() *CmdTree {
 	self.hidden = true
 	return self
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestCmdTreeShadowCmd(t *testing.T) {
+	tree := NewCmdTree(&CmdTreeStrs{PathSep: "."})
+	sub := tree.GetOrAddSub("a", "b")
+
+	sub.RegEmptyCmd("builtin")
+	if sub.ShadowCmd("repo-x", 10) != nil {
+		t.Fatalf("builtin cmd should not be shadowed")
+	}
+
+	tree = NewCmdTree(&CmdTreeStrs{PathSep: "."})
+	sub = tree.GetOrAddSub("a", "b")
+	sub.RegEmptyCmd("from x").SetSource("repo-x").SetSourcePriority(1)
+
+	if sub.ShadowCmd("repo-x", 2) != nil {
+		t.Fatalf("cmd should not be shadowed by the same source")
+	}
+	if sub.ShadowCmd("repo-y", 1) != nil {
+		t.Fatalf("cmd should not be shadowed by the same priority")
+	}
+	old := sub.ShadowCmd("repo-y", 2)
+	if old == nil || old.Source() != "repo-x" || sub.Cmd() != nil {
+		t.Fatalf("cmd should be shadowed by a higher priority")
+	}
+	cmd := sub.RegEmptyCmd("from y").SetSource("repo-y").SetSourcePriority(2).SetShadowed(old)
+	if cmd.Shadowed().Help() != "from x" {
+		t.Fatalf("wrong shadowed cmd")
+	}
+}
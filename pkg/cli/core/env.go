@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 )
 
 type EnvLayerType string
@@ -19,27 +20,30 @@ func EnvLayerName(ty EnvLayerType) string {
 	return string(ty)
 }
 
+// The pairs map of a layer could be shared by clones, it's copied before the first write (COW).
+// Every write gives the layer a new global unique version, so a cached flatten result
+// could be verified by comparing the versions of all layers it covered.
 type Env struct {
 	pairs  map[string]EnvVal
+	shared bool
+	ver    uint64
 	parent *Env
 	ty     EnvLayerType
+	flat   *envFlattenCache
 }
 
 func NewEnv() *Env {
-	return &Env{map[string]EnvVal{}, nil, EnvLayerDefault}
+	return &Env{map[string]EnvVal{}, false, newEnvVer(), nil, EnvLayerDefault, nil}
 }
 
-// TODO: COW ?
+// Clone is O(layers), no matter how many KVs in the env
 func (self *Env) Clone() (env *Env) {
-	pairs := map[string]EnvVal{}
-	for k, v := range self.pairs {
-		pairs[k] = EnvVal{v.Raw, v.IsArg}
-	}
 	var parent *Env
 	if self.parent != nil {
 		parent = self.parent.Clone()
 	}
-	return &Env{pairs, parent, self.ty}
+	self.shared = true
+	return &Env{self.pairs, true, self.ver, parent, self.ty, self.flat}
 }
 
 func (self *Env) NewLayer(ty EnvLayerType) *Env {
@@ -83,30 +87,37 @@ func (self *Env) getLayer(ty EnvLayerType) *Env {
 	return self.parent.getLayer(ty)
 }
 
-func (self Env) DeleteInSelfLayer(name string) {
-	delete(self.pairs, name)
+func (self *Env) DeleteInSelfLayer(name string) {
+	if _, ok := self.pairs[name]; !ok {
+		return
+	}
+	delete(self.writablePairs(), name)
 }
 
-func (self Env) Delete(name string) {
-	delete(self.pairs, name)
+func (self *Env) Delete(name string) {
+	self.DeleteInSelfLayer(name)
 	if self.parent != nil {
 		self.parent.Delete(name)
 	}
 }
 
-func (self Env) DeleteEx(name string, stopLayer EnvLayerType) {
+func (self *Env) DeleteEx(name string, stopLayer EnvLayerType) {
 	if self.ty == stopLayer {
 		return
 	}
-	delete(self.pairs, name)
+	self.DeleteInSelfLayer(name)
 	if self.parent != nil {
 		self.parent.DeleteEx(name, stopLayer)
 	}
 }
 
 func (self *Env) Merge(x *Env) {
+	if len(x.pairs) == 0 {
+		return
+	}
+	pairs := self.writablePairs()
 	for k, v := range x.pairs {
-		self.pairs[k] = EnvVal{v.Raw, false}
+		pairs[k] = EnvVal{v.Raw, false}
 	}
 }
 
@@ -114,12 +125,20 @@ func (self *Env) Deduplicate() {
 	if self.parent == nil {
 		return
 	}
+	var dups []string
 	for k, v := range self.pairs {
 		old, ok := self.parent.GetEx(k)
 		if ok && old.Raw == v.Raw {
-			delete(self.pairs, k)
+			dups = append(dups, k)
 		}
 	}
+	if len(dups) == 0 {
+		return
+	}
+	pairs := self.writablePairs()
+	for _, k := range dups {
+		delete(pairs, k)
+	}
 }
 
 func (self *Env) Set(name string, val string) (old EnvVal) {
@@ -132,7 +151,7 @@ func (self *Env) SetIfEmpty(name string, val string) (old EnvVal) {
 	if exists {
 		return
 	}
-	self.pairs[name] = EnvVal{val, false}
+	self.writablePairs()[name] = EnvVal{val, false}
 	return
 }
 
@@ -146,7 +165,7 @@ func (self *Env) SetEx(name string, val string, isArg bool) (old EnvVal) {
 	if exists && old.Raw == val {
 		return
 	}
-	self.pairs[name] = EnvVal{val, isArg}
+	self.writablePairs()[name] = EnvVal{val, isArg}
 	return
 }
 
@@ -159,7 +178,7 @@ func (self *Env) GetArgv(path []string, sep string, args Args) ArgVals {
 	list := args.Names()
 	for _, it := range list {
 		key := strings.Join(append(path, it), sep)
-		val, ok := self.GetEx(key)
+		val, ok := self.GetRenderedEx(key)
 		if ok {
 			argv[it] = ArgVal{val.Raw, true}
 		} else {
@@ -172,15 +191,12 @@ func (self *Env) GetArgv(path []string, sep string, args Args) ArgVals {
 	return argv
 }
 
-func (self Env) Get(name string) EnvVal {
-	val, ok := self.pairs[name]
-	if !ok && self.parent != nil {
-		return self.parent.Get(name)
-	}
+func (self *Env) Get(name string) EnvVal {
+	val, _ := self.GetEx(name)
 	return val
 }
 
-func (self Env) GetEx(name string) (EnvVal, bool) {
+func (self *Env) GetEx(name string) (EnvVal, bool) {
 	val, ok := self.pairs[name]
 	if !ok && self.parent != nil {
 		return self.parent.GetEx(name)
@@ -188,7 +204,41 @@ func (self Env) GetEx(name string) (EnvVal, bool) {
 	return val, ok
 }
 
-func (self Env) Pairs() (keys []string, vals []EnvVal) {
+// The templates like '[[key]]' in the value are rendered, for the places consuming values: args, flows
+func (self *Env) GetRendered(name string) EnvVal {
+	val, _ := self.GetRenderedEx(name)
+	return val
+}
+
+func (self *Env) GetRenderedEx(name string) (EnvVal, bool) {
+	val, ok := self.GetEx(name)
+	if ok {
+		val.Raw = self.renderVal(name, val.Raw, nil)
+	}
+	return val, ok
+}
+
+// For exporting env to mods, the value is kept as it is if it can't be rendered,
+// it may refer to a key which will be provided later
+func (self *Env) GetRenderedOrRaw(name string) (raw string) {
+	val, ok := self.GetEx(name)
+	if !ok {
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			switch err.(type) {
+			case EnvValErrRefMissed, EnvValErrRefCycle:
+				raw = val.Raw
+			default:
+				panic(err)
+			}
+		}
+	}()
+	return self.renderVal(name, val.Raw, nil)
+}
+
+func (self *Env) Pairs() (keys []string, vals []EnvVal) {
 	for k, v := range self.pairs {
 		keys = append(keys, k)
 		vals = append(vals, v)
@@ -196,31 +246,72 @@ func (self Env) Pairs() (keys []string, vals []EnvVal) {
 	return
 }
 
-func (self Env) LayerType() EnvLayerType {
+func (self *Env) LayerType() EnvLayerType {
 	return self.ty
 }
 
-func (self Env) LayerTypeName() string {
+func (self *Env) LayerTypeName() string {
 	return EnvLayerName(self.ty)
 }
 
-func (self Env) FlattenAll() map[string]string {
+func (self *Env) FlattenAll() map[string]string {
 	return self.Flatten(true, nil, false)
 }
 
-func (self Env) Flatten(
+// The result is a new map, callers could modify it
+func (self *Env) Flatten(
 	includeDefault bool,
 	filterPrefixs []string,
 	filterArgs bool) map[string]string {
 
+	all := self.flattenAll(includeDefault, filterArgs)
+	res := make(map[string]string, len(all))
+	for k, v := range all {
+		filtered := false
+		for _, filterPrefix := range filterPrefixs {
+			if len(filterPrefix) != 0 && strings.HasPrefix(k, filterPrefix) {
+				filtered = true
+				break
+			}
+		}
+		if !filtered {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// Same as Flatten without filters, but the result is cached and shared, callers must not modify it
+func (self *Env) FlattenShared(includeDefault bool, filterArgs bool) map[string]string {
+	return self.flattenAll(includeDefault, filterArgs)
+}
+
+func (self *Env) flattenAll(includeDefault bool, filterArgs bool) map[string]string {
+	vers := self.layerVers(includeDefault)
+	idx := envFlattenCacheIdx(includeDefault, filterArgs)
+
+	cache := self.flat
+	if cache != nil && cache.results[idx] != nil && cache.match(idx, vers) {
+		return cache.results[idx]
+	}
+
 	res := map[string]string{}
-	self.flatten(includeDefault, filterPrefixs, res, filterArgs)
+	self.flatten(includeDefault, res, filterArgs)
+
+	// Clones share the cache object, so don't modify an existed one
+	updated := &envFlattenCache{}
+	if cache != nil {
+		*updated = *cache
+		updated.dropStale(self.ver)
+	}
+	updated.vers[idx] = vers
+	updated.results[idx] = res
+	self.flat = updated
 	return res
 }
 
 func (self *Env) flatten(
 	includeDefault bool,
-	filterPrefixs []string,
 	res map[string]string,
 	filterArgs bool) {
 
@@ -228,20 +319,118 @@ func (self *Env) flatten(
 		return
 	}
 	if self.parent != nil {
-		self.parent.flatten(includeDefault, filterPrefixs, res, filterArgs)
+		self.parent.flatten(includeDefault, res, filterArgs)
 	}
 	for k, v := range self.pairs {
-		filtered := false
-		for _, filterPrefix := range filterPrefixs {
-			if len(filterPrefix) != 0 && strings.HasPrefix(k, filterPrefix) {
-				filtered = true
-				break
-			}
+		if !filterArgs || !v.IsArg {
+			res[k] = v.Raw
 		}
-		if !filtered {
-			if !filterArgs || !v.IsArg {
-				res[k] = v.Raw
+	}
+}
+
+func (self *Env) layerVers(includeDefault bool) (vers []uint64) {
+	for env := self; env != nil; env = env.parent {
+		if env.ty == EnvLayerDefault && !includeDefault {
+			break
+		}
+		vers = append(vers, env.ver)
+	}
+	return
+}
+
+func (self *Env) renderVal(key string, val string, refPath []string) string {
+	bracketLeft := self.Get("strs.flow-template-bracket-left").Raw
+	bracketRight := self.Get("strs.flow-template-bracket-right").Raw
+	if len(bracketLeft) == 0 || len(bracketRight) == 0 || strings.Index(val, bracketLeft) < 0 {
+		return val
+	}
+	defValSep := self.Get("strs.flow-template-default-sep").Raw
+
+	refPath = append(refPath, key)
+	rendered, missedKey, ok := RenderTemplateStr(val, bracketLeft, bracketRight, defValSep,
+		func(ref string) (string, bool) {
+			for i, it := range refPath {
+				if it == ref {
+					path := append(append([]string{}, refPath[i:]...), ref)
+					panic(EnvValErrRefCycle{
+						fmt.Sprintf("[Env.GetRendered] key '%s' = '%s' has cyclic reference: %s",
+							key, val, strings.Join(path, " -> ")),
+						refPath[0], path,
+					})
+				}
+			}
+			refVal, ok := self.GetEx(ref)
+			if !ok {
+				return "", false
 			}
+			return self.renderVal(ref, refVal.Raw, refPath), true
+		})
+	if !ok {
+		panic(EnvValErrRefMissed{
+			fmt.Sprintf("[Env.GetRendered] key '%s' = '%s' refers to a not existed key '%s'",
+				key, val, missedKey),
+			refPath[0], key, val, missedKey,
+		})
+	}
+	return rendered
+}
+
+func (self *Env) writablePairs() map[string]EnvVal {
+	if self.shared {
+		pairs := make(map[string]EnvVal, len(self.pairs)+1)
+		for k, v := range self.pairs {
+			pairs[k] = v
 		}
+		self.pairs = pairs
+		self.shared = false
 	}
+	self.ver = newEnvVer()
+	return self.pairs
+}
+
+type envFlattenCache struct {
+	vers    [4][]uint64
+	results [4]map[string]string
+}
+
+func envFlattenCacheIdx(includeDefault bool, filterArgs bool) (idx int) {
+	if includeDefault {
+		idx += 1
+	}
+	if filterArgs {
+		idx += 2
+	}
+	return
+}
+
+func (self *envFlattenCache) match(idx int, vers []uint64) bool {
+	return equalVers(self.vers[idx], vers)
+}
+
+// The first ver of each entry is from the layer owns this cache
+func (self *envFlattenCache) dropStale(ver uint64) {
+	for i, it := range self.vers {
+		if len(it) != 0 && it[0] != ver {
+			self.vers[i] = nil
+			self.results[i] = nil
+		}
+	}
+}
+
+func equalVers(a []uint64, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, it := range a {
+		if it != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var envVerCounter uint64
+
+func newEnvVer() uint64 {
+	return atomic.AddUint64(&envVerCounter, 1)
 }
@@ -118,6 +118,11 @@ func (self EnvOpsChecker) OnCallCmd(
 	return
 }
 
+// All the ops on the key during checking, or-ed together
+func (self EnvOpsChecker) KeyOps(key string) uint {
+	return self[key].val
+}
+
 type envOpsCheckerKeyInfo struct {
 	mayWriteCmds []MayWriteCmd
 	val          uint
@@ -136,6 +141,18 @@ func CheckEnvOps(
 	ignoreMaybe bool,
 	result *[]EnvOpsCheckResult) {
 
+	checkEnvOps(cc, flow, env, checker, ignoreMaybe, result, cc.FlowStack)
+}
+
+func checkEnvOps(
+	cc *Cli,
+	flow *ParsedCmds,
+	env *Env,
+	checker *EnvOpsChecker,
+	ignoreMaybe bool,
+	result *[]EnvOpsCheckResult,
+	stack FlowStack) {
+
 	if len(flow.Cmds) == 0 {
 		return
 	}
@@ -148,14 +165,16 @@ func CheckEnvOps(
 			continue
 		}
 		displayPath := cmd.DisplayPath(sep, true)
-		cmdEnv, _ := cmd.GenEnvAndArgv(env, cc.Cmds.Strs.EnvValDelAllMark, cc.Cmds.Strs.PathSep)
+		cmdEnv, argv := cmd.GenEnvAndArgv(env, cc.Cmds.Strs.EnvValDelAllMark, cc.Cmds.Strs.PathSep)
 		res := checker.OnCallCmd(cmdEnv, cmd, sep, last, ignoreMaybe, displayPath)
 
 		*result = append(*result, res...)
 
 		if last.Type() == CmdTypeFlow {
-			subFlow, _ := last.Flow(cmdEnv, false)
-			parsedFlow := cc.Parser.Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
+			subFlow, _ := last.Flow(cmdEnv, argv, false)
+			subStack := stack.Push(last, subFlow)
+			subStack.CheckDepth(len(subStack), env.GetInt("sys.flow.max-depth"))
+			parsedFlow := last.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
 			err := parsedFlow.FirstErr()
 			if err != nil {
 				panic(err.Error)
@@ -164,7 +183,7 @@ func CheckEnvOps(
 				env = env.GetOrNewLayer(EnvLayerTmp)
 				parsedFlow.GlobalEnv.WriteNotArgTo(env, cc.Cmds.Strs.EnvValDelAllMark)
 			}
-			CheckEnvOps(cc, parsedFlow, env, checker, ignoreMaybe, result)
+			checkEnvOps(cc, parsedFlow, env, checker, ignoreMaybe, result, subStack)
 		}
 	}
 }
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestEnv(keyCount int) *Env {
+	env := NewEnv().NewLayers(EnvLayerPersisted, EnvLayerSession)
+	def := env.GetLayer(EnvLayerDefault)
+	for i := 0; i < keyCount; i++ {
+		def.Set(fmt.Sprintf("sys.bench.key-%d", i), fmt.Sprintf("%d", i))
+	}
+	session := env.GetLayer(EnvLayerSession)
+	for i := 0; i < keyCount/10; i++ {
+		session.Set(fmt.Sprintf("bench.session.key-%d", i), fmt.Sprintf("%d", i))
+	}
+	return env
+}
+
+func TestEnvCloneIsolation(t *testing.T) {
+	env := NewEnv().NewLayers(EnvLayerPersisted, EnvLayerSession)
+	env.GetLayer(EnvLayerDefault).Set("a", "A")
+	env.Set("b", "B")
+
+	cloned := env.Clone()
+	cloned.Set("b", "BB")
+	cloned.GetLayer(EnvLayerDefault).Set("c", "C")
+	cloned.Delete("a")
+
+	if env.GetRaw("a") != "A" || env.GetRaw("b") != "B" || env.GetRaw("c") != "" {
+		t.Fatalf("origin env modified by clone: %#v", env.FlattenAll())
+	}
+	if cloned.GetRaw("a") != "" || cloned.GetRaw("b") != "BB" || cloned.GetRaw("c") != "C" {
+		t.Fatalf("clone env not modified: %#v", cloned.FlattenAll())
+	}
+
+	env.Set("d", "D")
+	if cloned.GetRaw("d") != "" {
+		t.Fatalf("clone env modified by origin: %#v", cloned.FlattenAll())
+	}
+}
+
+func TestEnvFlattenCache(t *testing.T) {
+	env := NewEnv().NewLayers(EnvLayerPersisted, EnvLayerSession)
+	env.GetLayer(EnvLayerDefault).Set("a", "A")
+	env.SetAsArg("b", "B")
+
+	test := func(includeDefault bool, filterArgs bool, expected map[string]string) {
+		flatten := env.Flatten(includeDefault, nil, filterArgs)
+		if fmt.Sprintf("%v", flatten) != fmt.Sprintf("%v", expected) {
+			t.Fatalf("flatten(%v, %v): %v != %v", includeDefault, filterArgs, flatten, expected)
+		}
+	}
+
+	test(true, false, map[string]string{"a": "A", "b": "B"})
+	test(false, false, map[string]string{"b": "B"})
+	test(true, true, map[string]string{"a": "A"})
+
+	env.GetLayer(EnvLayerDefault).Set("a", "AA")
+	test(true, false, map[string]string{"a": "AA", "b": "B"})
+	test(false, false, map[string]string{"b": "B"})
+
+	cloned := env.Clone()
+	cloned.Set("c", "C")
+	test(true, false, map[string]string{"a": "AA", "b": "B"})
+
+	filtered := cloned.Flatten(true, []string{"a"}, false)
+	if fmt.Sprintf("%v", filtered) != fmt.Sprintf("%v", map[string]string{"b": "B", "c": "C"}) {
+		t.Fatalf("flatten with filter: %v", filtered)
+	}
+
+	// The result of Flatten is owned by the caller, modifying it doesn't affect the cache
+	flatten := env.Flatten(true, nil, false)
+	flatten["a"] = "X"
+	test(true, false, map[string]string{"a": "AA", "b": "B"})
+	if shared := env.FlattenShared(true, false); shared["a"] != "AA" {
+		t.Fatalf("shared flatten result modified: %v", shared)
+	}
+}
+
+func BenchmarkEnvClone(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.Clone()
+	}
+}
+
+func BenchmarkEnvCloneAndWrite(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cloned := env.Clone()
+		cloned.NewLayer(EnvLayerCmd).Set("bench.cmd.key", "v")
+	}
+}
+
+func BenchmarkEnvNewLayer(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.NewLayer(EnvLayerCmd)
+	}
+}
+
+// The old Clone copied all KVs of all layers, kept for comparing
+func deepCloneEnv(env *Env) *Env {
+	var parent *Env
+	if env.parent != nil {
+		parent = deepCloneEnv(env.parent)
+	}
+	pairs := make(map[string]EnvVal, len(env.pairs))
+	for k, v := range env.pairs {
+		pairs[k] = v
+	}
+	return &Env{pairs, false, newEnvVer(), parent, env.ty, nil}
+}
+
+func BenchmarkEnvCloneDeepCopy(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		deepCloneEnv(env)
+	}
+}
+
+func BenchmarkEnvFlatten(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.Flatten(true, nil, true)
+	}
+}
+
+func BenchmarkEnvFlattenShared(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.FlattenShared(true, true)
+	}
+}
+
+// The old Flatten walked all layers every time, kept for comparing
+func BenchmarkEnvFlattenUncached(b *testing.B) {
+	env := newTestEnv(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.flatten(true, map[string]string{}, true)
+	}
+}
+
+func TestEnvValRender(t *testing.T) {
+	env := NewEnv().NewLayers(EnvLayerPersisted, EnvLayerSession)
+	def := env.GetLayer(EnvLayerDefault)
+	def.Set("strs.flow-template-bracket-left", "[[")
+	def.Set("strs.flow-template-bracket-right", "]]")
+	def.Set("strs.flow-template-default-sep", "|")
+
+	env.Set("tidb.host", "127.0.0.1")
+	env.Set("tidb.addr", "[[tidb.host]]:[[tidb.port|4000]]")
+	env.Set("tidb.url", "mysql://[[tidb.addr]]")
+
+	if env.GetRendered("tidb.addr").Raw != "127.0.0.1:4000" {
+		t.Fatalf("render with default value failed: %s", env.GetRendered("tidb.addr").Raw)
+	}
+	env.Set("tidb.port", "4001")
+	if env.GetRendered("tidb.url").Raw != "mysql://127.0.0.1:4001" {
+		t.Fatalf("nested render failed: %s", env.GetRendered("tidb.url").Raw)
+	}
+	if env.GetRaw("tidb.addr") != "[[tidb.host]]:[[tidb.port|4000]]" {
+		t.Fatalf("raw value should not be rendered: %s", env.GetRaw("tidb.addr"))
+	}
+
+	// Values are only rendered when asked, and a bash test is not a template
+	env.Set("my.check", "[[ -f /tmp/x ]] && echo ok")
+	if env.GetRaw("my.check") != "[[ -f /tmp/x ]] && echo ok" ||
+		env.GetRendered("my.check").Raw != "[[ -f /tmp/x ]] && echo ok" {
+		t.Fatalf("value with bash test changed: %s", env.GetRendered("my.check").Raw)
+	}
+	env.Set("my.json", "[[1,2]]")
+	if env.GetRaw("my.json") != "[[1,2]]" {
+		t.Fatalf("raw value should not be rendered: %s", env.GetRaw("my.json"))
+	}
+
+	expectPanic := func(key string, check func(err interface{}) bool) {
+		defer func() {
+			err := recover()
+			if err == nil || !check(err) {
+				t.Fatalf("get '%s': unexpected result: %#v", key, err)
+			}
+		}()
+		env.GetRendered(key)
+	}
+
+	env.Set("a", "[[b]]")
+	env.Set("b", "x[[c]]")
+	env.Set("c", "[[a]]")
+	expectPanic("a", func(err interface{}) bool {
+		e, ok := err.(EnvValErrRefCycle)
+		return ok && fmt.Sprintf("%v", e.RefPath) == "[a b c a]"
+	})
+
+	env.Set("d", "[[not-exists]]")
+	expectPanic("d", func(err interface{}) bool {
+		e, ok := err.(EnvValErrRefMissed)
+		return ok && e.MissedKey == "not-exists"
+	})
+}
+
+func TestRenderTemplateStr(t *testing.T) {
+	vals := map[string]string{"a": "A", "b": ""}
+	lookup := func(key string) (string, bool) {
+		val, ok := vals[key]
+		return val, ok
+	}
+
+	test := func(str string, expected string, expectedMissed string) {
+		res, missed, ok := RenderTemplateStr(str, "[[", "]]", "|", lookup)
+		if missed != expectedMissed || ok != (len(expectedMissed) == 0) || (ok && res != expected) {
+			t.Fatalf("render '%s': ('%s', '%s', %v)", str, res, missed, ok)
+		}
+	}
+
+	test("", "", "")
+	test("a", "a", "")
+	test("[[a]]", "A", "")
+	test("x[[a]]y[[a]]z", "xAyAz", "")
+	test("[[ a ]]", "A", "")
+	test("[[b|B]]", "", "")
+	test("[[c|C]]", "C", "")
+	test("[[c|]]", "", "")
+	test("[[c]]", "", "c")
+	test("[[a", "[[a", "")
+	test("[[ -f /tmp/x ]] && [[a]]", "[[ -f /tmp/x ]] && A", "")
+	test("[[]]", "[[]]", "")
+}
+
+func TestRenderedEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env-file-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "env")
+
+	env := NewEnv().NewLayers(EnvLayerPersisted, EnvLayerSession)
+	def := env.GetLayer(EnvLayerDefault)
+	def.Set("strs.flow-template-bracket-left", "[[")
+	def.Set("strs.flow-template-bracket-right", "]]")
+	def.Set("strs.flow-template-default-sep", "|")
+	env.Set("tidb.host", "127.0.0.1")
+	env.Set("tidb.addr", "[[tidb.host]]:4000")
+	env.Set("tidb.url", "mysql://[[tidb.user]]@[[tidb.addr]]")
+
+	SaveRenderedEnvToFile(env, path, "\t")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The value refers to a not existed key is kept as it is
+	expected := "tidb.addr\t127.0.0.1:4000\ntidb.host\t127.0.0.1\ntidb.url\tmysql://[[tidb.user]]@[[tidb.addr]]\n"
+	if string(data) != expected {
+		t.Fatalf("wrong rendered env file:\n%s", data)
+	}
+
+	// A mod changes 'tidb.host' and leaves others unchanged, the templates are kept
+	ioutil.WriteFile(path, []byte(strings.Replace(string(data), "\t127.0.0.1\n", "\t10.0.0.1\n", 1)), 0644)
+	LoadRenderedEnvFromFile(env, path, "\t")
+	if env.GetRaw("tidb.host") != "10.0.0.1" || env.GetRaw("tidb.addr") != "[[tidb.host]]:4000" ||
+		env.GetRendered("tidb.addr").Raw != "10.0.0.1:4000" {
+		t.Fatalf("wrong env after loading: %v", env.FlattenAll())
+	}
+
+	// A changed value overwrites the template
+	ioutil.WriteFile(path, []byte("tidb.addr\t10.0.0.2:4000\n"), 0644)
+	LoadRenderedEnvFromFile(env, path, "\t")
+	if env.GetRaw("tidb.addr") != "10.0.0.2:4000" {
+		t.Fatalf("changed value not loaded: %s", env.GetRaw("tidb.addr"))
+	}
+
+	// The raw env file keeps the templates
+	SaveEnvToFile(env, path, "\t")
+	data, _ = ioutil.ReadFile(path)
+	if !strings.Contains(string(data), "tidb.url\tmysql://[[tidb.user]]@[[tidb.addr]]\n") {
+		t.Fatalf("templates should be saved as they are:\n%s", data)
+	}
+}
@@ -5,15 +5,15 @@ import (
 	"strconv"
 )
 
-func (self Env) GetRaw(name string) string {
+func (self *Env) GetRaw(name string) string {
 	return self.Get(name).Raw
 }
 
-func (self Env) SetInt(name string, val int) {
+func (self *Env) SetInt(name string, val int) {
 	self.Set(name, fmt.Sprintf("%d", val))
 }
 
-func (self Env) GetInt(name string) int {
+func (self *Env) GetInt(name string) int {
 	val := self.Get(name).Raw
 	intVal, err := strconv.Atoi(val)
 	if err != nil {
@@ -25,17 +25,17 @@ func (self Env) GetInt(name string) int {
 	return int(intVal)
 }
 
-func (self Env) PlusInt(name string, val int) {
+func (self *Env) PlusInt(name string, val int) {
 	self.SetInt(name, self.GetInt(name)+val)
 }
 
-func (self Env) SetBool(name string, val bool) bool {
+func (self *Env) SetBool(name string, val bool) bool {
 	old := StrToBool(self.Get(name).Raw)
 	self.Set(name, fmt.Sprintf("%v", val))
 	return old
 }
 
-func (self Env) GetBool(name string) bool {
+func (self *Env) GetBool(name string) bool {
 	return StrToBool(self.Get(name).Raw)
 }
 
@@ -55,3 +55,25 @@ type EnvValErrWrongType struct {
 func (self EnvValErrWrongType) Error() string {
 	return self.Str
 }
+
+type EnvValErrRefMissed struct {
+	Str       string
+	Key       string
+	RefBy     string
+	Val       string
+	MissedKey string
+}
+
+func (self EnvValErrRefMissed) Error() string {
+	return self.Str
+}
+
+type EnvValErrRefCycle struct {
+	Str     string
+	Key     string
+	RefPath []string
+}
+
+func (self EnvValErrRefCycle) Error() string {
+	return self.Str
+}
@@ -1,5 +1,9 @@
 package core
 
+import (
+	"strings"
+)
+
 type TolerableErr struct {
 	Err    interface{}
 	File   string
@@ -9,10 +13,21 @@ type TolerableErr struct {
 
 type ConflictedWithSameSource map[string][]TolerableErr
 
+// A cmd intentionally shadowed by the one from a higher priority repo/dir, not an error
+type ShadowedCmd struct {
+	CmdPath     []string
+	File        string
+	Source      string
+	Priority    int
+	OldSource   string
+	OldPriority int
+}
+
 type TolerableErrs struct {
 	Uncatalogeds          []TolerableErr
 	ConflictedWithBuiltin ConflictedWithSameSource
 	Conflicteds           map[string]ConflictedWithSameSource
+	Shadoweds             []ShadowedCmd
 }
 
 func NewTolerableErrs() *TolerableErrs {
@@ -20,9 +35,14 @@ func NewTolerableErrs() *TolerableErrs {
 		nil,
 		ConflictedWithSameSource{},
 		map[string]ConflictedWithSameSource{},
+		nil,
 	}
 }
 
+func (self *TolerableErrs) OnShadowed(shadowed ShadowedCmd) {
+	self.Shadoweds = append(self.Shadoweds, shadowed)
+}
+
 func (self *TolerableErrs) OnErr(err interface{}, source string, file string, reason string) {
 	conflicted, ok := err.(ErrConflicted)
 	if !ok {
@@ -110,9 +130,41 @@ type CmdMissedEnvValWhenRenderFlow struct {
 	CmdPath      string
 	MetaFilePath string
 	Source       string
-	MissedKey    string
+	MissedKeys   []string
+	// The missed keys which are declared params (args) of the flow
+	MissedParams []string
 }
 
 func (self CmdMissedEnvValWhenRenderFlow) Error() string {
 	return self.Str
 }
+
+type FlowCallInfo struct {
+	CmdPath      string
+	Source       string
+	MetaFilePath string
+}
+
+type CmdFlowCycleDetected struct {
+	Str string
+	// The first and the last are the same flow
+	Cycle []FlowCallInfo
+}
+
+func (self CmdFlowCycleDetected) Error() string {
+	var path []string
+	for _, it := range self.Cycle {
+		path = append(path, it.CmdPath)
+	}
+	return self.Str + " " + strings.Join(path, " -> ")
+}
+
+type CmdFlowTooDeep struct {
+	Str      string
+	MaxDepth int
+	Stack    []FlowCallInfo
+}
+
+func (self CmdFlowTooDeep) Error() string {
+	return self.Str
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The flows being called (or checked), from outer to inner.
+// A flow is in a cycle if it's called again with the same rendered content,
+// nothing could be different in the next round, so it never ends.
+type FlowStack []flowCall
+
+type flowCall struct {
+	cmd  *Cmd
+	flow string
+}
+
+func (self FlowStack) Push(cmd *Cmd, flow []string) FlowStack {
+	flowStr := strings.Join(flow, " ")
+	for i, it := range self {
+		if it.cmd == cmd && it.flow == flowStr {
+			cycle := append(self[i:].Infos(), cmd.flowCallInfo())
+			panic(CmdFlowCycleDetected{
+				"flow cycle detected, the flow calls itself endlessly.",
+				cycle,
+			})
+		}
+	}
+	// Copy on push, the stack is shared by the callers
+	stack := make(FlowStack, len(self), len(self)+1)
+	copy(stack, self)
+	return append(stack, flowCall{cmd, flowStr})
+}
+
+// Check the nesting depth of flows, 'max <= 0' means no limit.
+// When executing, the depth is 'sys.stack-depth', it's the same as the stack size
+func (self FlowStack) CheckDepth(depth int, max int) {
+	if max <= 0 || depth <= max {
+		return
+	}
+	panic(CmdFlowTooDeep{
+		fmt.Sprintf("flow nesting depth exceeded the max value %d.", max),
+		max,
+		self.Infos(),
+	})
+}
+
+func (self FlowStack) Infos() (infos []FlowCallInfo) {
+	for _, it := range self {
+		infos = append(infos, it.cmd.flowCallInfo())
+	}
+	return
+}
+
+func (self *Cmd) flowCallInfo() FlowCallInfo {
+	return FlowCallInfo{self.owner.DisplayPath(), self.source, self.metaFilePath}
+}
+
+// Find the cycles of the statically referred flows in the tree by one pass of DFS,
+// the flows not rendered (by missed template keys) are skipped.
+// Return the flows closing the cycles (referring back to a flow being visited) and the cycle paths,
+// the first and the last of a path are the same flow
+func FindFlowCycles(cc *Cli, env *Env) (closers []*Cmd, cycles []CmdFlowCycleDetected) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := map[*Cmd]int{}
+	var path []*Cmd
+
+	var visit func(cmd *Cmd)
+	visit = func(cmd *Cmd) {
+		states[cmd] = visiting
+		path = append(path, cmd)
+		for _, ref := range cmd.FlowRefs(cc, env) {
+			if states[ref] == 0 {
+				visit(ref)
+				continue
+			}
+			if states[ref] != visiting {
+				continue
+			}
+			var cycle []FlowCallInfo
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == ref {
+					for _, it := range path[i:] {
+						cycle = append(cycle, it.flowCallInfo())
+					}
+					break
+				}
+			}
+			closers = append(closers, cmd)
+			cycles = append(cycles, CmdFlowCycleDetected{
+				"flow cycle detected, the flows call each other endlessly.",
+				append(cycle, ref.flowCallInfo()),
+			})
+			// It will be removed, no need to find more
+			break
+		}
+		path = path[:len(path)-1]
+		states[cmd] = visited
+	}
+
+	var walk func(tree *CmdTree)
+	walk = func(tree *CmdTree) {
+		if cmd := tree.Cmd(); cmd != nil && cmd.Type() == CmdTypeFlow && states[cmd] == 0 {
+			visit(cmd)
+		}
+		for _, name := range tree.SubNames() {
+			walk(tree.GetSub(name))
+		}
+	}
+	walk(cc.Cmds)
+	return
+}
+
+// The flow commands directly referred by this flow, rendered by the default values of args
+func (self *Cmd) FlowRefs(cc *Cli, env *Env) (refs []*Cmd) {
+	if self.ty != CmdTypeFlow {
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			refs = nil
+		}
+	}()
+	argv := ArgVals{}
+	for _, name := range self.args.Names() {
+		argv[name] = ArgVal{self.args.DefVal(name), false}
+	}
+	flow, rendered := self.Flow(env, argv, true)
+	if !rendered || len(flow) == 0 {
+		return
+	}
+	parsed := self.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, flow...)
+	for _, cmd := range parsed.Cmds {
+		last := cmd.LastCmd()
+		if last != nil && last.Type() == CmdTypeFlow {
+			refs = append(refs, last)
+		}
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestFlowStack(t *testing.T) {
+	tree := NewCmdTree(&CmdTreeStrs{PathSep: "."})
+	a := tree.GetOrAddSub("a").RegFlowCmd([]string{"b"}, "").SetSource("repo-x")
+	b := tree.GetOrAddSub("b").RegFlowCmd([]string{"a"}, "").SetSource("repo-y")
+
+	var stack FlowStack
+	stack = stack.Push(a, []string{"b"})
+	stack = stack.Push(b, []string{"a x=1"})
+	if len(stack.Push(a, []string{"b x=1"})) != 3 {
+		t.Fatalf("flow with different content should not be treated as a cycle")
+	}
+
+	func() {
+		defer func() {
+			err, ok := recover().(CmdFlowCycleDetected)
+			if !ok {
+				t.Fatalf("cycle should be detected")
+			}
+			if len(err.Cycle) != 3 || err.Cycle[0].CmdPath != "a" || err.Cycle[1].Source != "repo-y" ||
+				err.Cycle[2].CmdPath != "a" {
+				t.Fatalf("wrong cycle path: %v", err.Cycle)
+			}
+		}()
+		stack.Push(a, []string{"b"})
+	}()
+
+	func() {
+		defer func() {
+			if _, ok := recover().(CmdFlowTooDeep); !ok {
+				t.Fatalf("too deep flow should be detected")
+			}
+		}()
+		stack.CheckDepth(len(stack), 0)
+		stack.CheckDepth(len(stack), 2)
+		stack.CheckDepth(len(stack), 1)
+	}()
+}
@@ -18,6 +18,39 @@ type CliParser interface {
 	Parse(cmds *CmdTree, envAbbrs *EnvAbbrs, input ...string) *ParsedCmds
 }
 
+// Parse with the mount path as prefix first, so the cmds of the mounted repo/dir win,
+// the cmds not found there are looked up from the root
+type mountedParser struct {
+	parser    CliParser
+	mountPath []string
+	pathSep   string
+}
+
+func (self mountedParser) Parse(cmds *CmdTree, envAbbrs *EnvAbbrs, input ...string) *ParsedCmds {
+	flow := self.parser.Parse(cmds, envAbbrs, input...)
+	prefix := strings.Join(self.mountPath, self.pathSep) + self.pathSep
+	for i, cmd := range flow.Cmds {
+		if len(cmd.ParseResult.Input) == 0 {
+			continue
+		}
+		mounted := self.parser.Parse(cmds, envAbbrs, append([]string{prefix}, cmd.ParseResult.Input...)...)
+		if len(mounted.Cmds) != 1 || mounted.Cmds[0].ParseResult.Error != nil {
+			continue
+		}
+		// The input only matched the mount path itself, eg: an env-only sequence
+		last := mounted.Cmds[0].LastCmdNode()
+		if last == nil || len(last.Path()) <= len(self.mountPath) {
+			continue
+		}
+		// A dir in the mounted tree doesn't shadow an executable cmd from the root
+		if last.IsNoExecutableCmd() && cmd.LastCmdNode() != nil && !cmd.LastCmdNode().IsNoExecutableCmd() {
+			continue
+		}
+		flow.Cmds[i] = mounted.Cmds[0]
+	}
+	return flow
+}
+
 type ParsedCmds struct {
 	GlobalEnv    ParsedEnv
 	Cmds         ParsedCmdSeq
@@ -10,6 +10,15 @@ import (
 )
 
 func EnvOutput(env *Env, writer io.Writer, sep string) error {
+	return envOutput(env, writer, sep, false)
+}
+
+// Same as EnvOutput, but the templates in the values are rendered, for mods reading env
+func RenderedEnvOutput(env *Env, writer io.Writer, sep string) error {
+	return envOutput(env, writer, sep, true)
+}
+
+func envOutput(env *Env, writer io.Writer, sep string, render bool) error {
 	// TODO: move to default config
 	filtered := []string{
 		"session",
@@ -32,6 +41,9 @@ func EnvOutput(env *Env, writer io.Writer, sep string) error {
 	sort.Strings(keys)
 	for _, k := range keys {
 		v := env.GetRaw(k)
+		if render {
+			v = env.GetRenderedOrRaw(k)
+		}
 		_, err := fmt.Fprintf(writer, "%s%s%s\n", k, sep, v)
 		if err != nil {
 			return err
@@ -62,6 +74,15 @@ func EnvInput(env *Env, reader io.Reader, sep string) error {
 }
 
 func SaveEnvToFile(env *Env, path string, sep string) {
+	saveEnvToFile(env, path, sep, false)
+}
+
+// The session env file for mods, the values are rendered
+func SaveRenderedEnvToFile(env *Env, path string, sep string) {
+	saveEnvToFile(env, path, sep, true)
+}
+
+func saveEnvToFile(env *Env, path string, sep string, render bool) {
 	tmp := path + ".tmp"
 	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -69,7 +90,7 @@ func SaveEnvToFile(env *Env, path string, sep string) {
 	}
 	defer file.Close()
 
-	err = EnvOutput(env, file, sep)
+	err = envOutput(env, file, sep, render)
 	if err != nil {
 		panic(fmt.Errorf("[SaveEnvToLocal] write env file '%s' failed: %v", tmp, err))
 	}
@@ -99,3 +120,17 @@ func LoadEnvFromFile(env *Env, path string, sep string) {
 			path, err))
 	}
 }
+
+// Load the file saved by SaveRenderedEnvToFile, the keys with unchanged rendered values keep their templates
+func LoadRenderedEnvFromFile(env *Env, path string, sep string) {
+	loaded := NewEnv()
+	LoadEnvFromFile(loaded, path, sep)
+	keys, vals := loaded.Pairs()
+	for i, k := range keys {
+		_, ok := env.GetEx(k)
+		if ok && env.GetRenderedOrRaw(k) == vals[i].Raw {
+			continue
+		}
+		env.Set(k, vals[i].Raw)
+	}
+}
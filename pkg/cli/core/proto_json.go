@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// How ticat talks with an executable mod, declared by 'protocol' in the mod meta file
+type ModProtocol string
+
+const (
+	// Pass session dir as arg-1, mod read and write the 'env' file in it
+	ModProtocolEnvFile ModProtocol = "env"
+	// Pass a json file path as arg-1, mod could write structured results to another json file
+	ModProtocolJson ModProtocol = "json"
+)
+
+const ModJsonProtocolVersion = 1
+
+func ParseModProtocol(str string) (ModProtocol, bool) {
+	switch str {
+	case "", string(ModProtocolEnvFile):
+		return ModProtocolEnvFile, true
+	case string(ModProtocolJson):
+		return ModProtocolJson, true
+	}
+	return "", false
+}
+
+type ModJsonInput struct {
+	Protocol string            `json:"protocol"`
+	Version  int               `json:"version"`
+	Cmd      string            `json:"cmd"`
+	ArgNames []string          `json:"arg-names"`
+	Args     map[string]string `json:"args"`
+	Env      map[string]string `json:"env"`
+	Session  ModJsonSession    `json:"session"`
+	Output   string            `json:"output"`
+}
+
+type ModJsonSession struct {
+	Dir        string `json:"dir"`
+	EnvFile    string `json:"env-file"`
+	StackDepth int    `json:"stack-depth"`
+}
+
+type ModJsonOutput struct {
+	Env           map[string]string `json:"env"`
+	Delete        []string          `json:"delete"`
+	Messages      []string          `json:"messages"`
+	Artifacts     []ModJsonArtifact `json:"artifacts"`
+	SkipRemaining bool              `json:"skip-remaining"`
+}
+
+type ModJsonArtifact struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Desc string `json:"desc"`
+}
+
+// The env values are the same as what the 'env' file protocol provides, the templates are rendered
+func NewModJsonInput(
+	cmdPath string,
+	argNames []string,
+	argv ArgVals,
+	env *Env,
+	sessionDir string,
+	sessionPath string,
+	outputPath string) ModJsonInput {
+
+	args := map[string]string{}
+	for _, k := range argNames {
+		args[k] = argv[k].Raw
+	}
+	vals := env.Flatten(true, []string{"session"}, false)
+	for k, _ := range vals {
+		vals[k] = env.GetRenderedOrRaw(k)
+	}
+	return ModJsonInput{
+		string(ModProtocolJson),
+		ModJsonProtocolVersion,
+		cmdPath,
+		argNames,
+		args,
+		vals,
+		ModJsonSession{sessionDir, sessionPath, env.GetInt("sys.stack-depth")},
+		outputPath,
+	}
+}
+
+func SaveModJsonInput(input ModJsonInput, path string) {
+	data, err := json.MarshalIndent(input, "", "    ")
+	if err != nil {
+		panic(fmt.Errorf("[SaveModJsonInput] encode json failed: %v", err))
+	}
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[SaveModJsonInput] write json file '%s' failed: %v", path, err))
+	}
+}
+
+// A mod could write nothing to the output file
+func LoadModJsonOutput(path string) (output ModJsonOutput, exists bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return
+		}
+		panic(fmt.Errorf("[LoadModJsonOutput] read json file '%s' failed: %v", path, err))
+	}
+	if len(data) == 0 {
+		return
+	}
+	err = json.Unmarshal(data, &output)
+	if err != nil {
+		panic(fmt.Errorf("[LoadModJsonOutput] decode json file '%s' failed: %v", path, err))
+	}
+	return output, true
+}
+
+// Writes go to the session layer, deletes affect all layers except the default one
+func ApplyModJsonOutput(output ModJsonOutput, env *Env, screen Screen) {
+	sessionEnv := env.GetLayer(EnvLayerSession)
+	var keys []string
+	for k, _ := range output.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sessionEnv.Set(k, output.Env[k])
+	}
+	for _, k := range output.Delete {
+		env.DeleteEx(k, EnvLayerDefault)
+	}
+
+	for _, msg := range output.Messages {
+		screen.Print(msg + "\n")
+	}
+	for _, it := range output.Artifacts {
+		line := "[artifact] "
+		if len(it.Name) != 0 {
+			line += it.Name + ": "
+		}
+		line += it.Path
+		if len(it.Desc) != 0 {
+			line += " '" + it.Desc + "'"
+		}
+		screen.Print(line + "\n")
+	}
+}
+
+// The executor will continue from the returned index, so the remaining commands are skipped by
+// returning the last index
+func ModJsonOutputNextCmdIdx(output ModJsonOutput, flow *ParsedCmds, currCmdIdx int) (int, bool) {
+	if !output.SkipRemaining || flow == nil || currCmdIdx >= len(flow.Cmds)-1 {
+		return currCmdIdx, false
+	}
+	return len(flow.Cmds) - 1, true
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testScreen struct {
+	lines []string
+}
+
+func (self *testScreen) Print(text string) {
+	self.lines = append(self.lines, text)
+}
+
+func (self *testScreen) Error(text string) {
+	self.lines = append(self.lines, text)
+}
+
+func (self *testScreen) OutputNum() int {
+	return len(self.lines)
+}
+
+func TestModJsonInputAndOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proto-json-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := NewEnv().NewLayers(EnvLayerPersisted, EnvLayerSession)
+	env.GetLayer(EnvLayerDefault).Set("sys.stack-depth", "2")
+	env.GetLayer(EnvLayerDefault).Set("strs.flow-template-bracket-left", "[[")
+	env.GetLayer(EnvLayerDefault).Set("strs.flow-template-bracket-right", "]]")
+	env.GetLayer(EnvLayerPersisted).Set("tidb.host", "127.0.0.1")
+	env.Set("tidb.addr", "[[tidb.host]]:4000")
+	env.Set("session", dir)
+
+	inputPath := filepath.Join(dir, "mod.input.json")
+	outputPath := filepath.Join(dir, "mod.output.json")
+	argv := ArgVals{"host": ArgVal{"h1", true}, "port": ArgVal{"", false}}
+	input := NewModJsonInput("tidb.start", []string{"port", "host"}, argv, env,
+		dir, filepath.Join(dir, "env"), outputPath)
+	SaveModJsonInput(input, inputPath)
+
+	data, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded ModJsonInput
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("decode input failed: %v", err)
+	}
+	if loaded.Protocol != "json" || loaded.Version != ModJsonProtocolVersion || loaded.Cmd != "tidb.start" ||
+		strings.Join(loaded.ArgNames, ",") != "port,host" || loaded.Output != outputPath {
+		t.Fatalf("wrong input envelope: %#v", loaded)
+	}
+	if fmt.Sprintf("%v", loaded.Args) != "map[host:h1 port:]" {
+		t.Fatalf("wrong input args: %v", loaded.Args)
+	}
+	// The 'session' key is in the session field, env values are rendered as the env file
+	if _, ok := loaded.Env["session"]; ok || loaded.Env["tidb.addr"] != "127.0.0.1:4000" {
+		t.Fatalf("wrong input env: %v", loaded.Env)
+	}
+	if loaded.Session.Dir != dir || loaded.Session.StackDepth != 2 {
+		t.Fatalf("wrong input session: %#v", loaded.Session)
+	}
+
+	if _, exists := LoadModJsonOutput(outputPath); exists {
+		t.Fatalf("output file not written by mod should not exist")
+	}
+	ioutil.WriteFile(outputPath, nil, 0644)
+	if _, exists := LoadModJsonOutput(outputPath); exists {
+		t.Fatalf("empty output file should be ignored")
+	}
+
+	ioutil.WriteFile(outputPath, []byte(`{
+		"env": {"tidb.port": "4001", "tidb.host": "10.0.0.1"},
+		"delete": ["tidb.addr", "sys.stack-depth"],
+		"messages": ["started"],
+		"artifacts": [{"name": "log", "path": "/tmp/tidb.log", "desc": "tidb log"}, {"path": "/tmp/x"}],
+		"skip-remaining": true
+	}`), 0644)
+	output, exists := LoadModJsonOutput(outputPath)
+	if !exists || !output.SkipRemaining {
+		t.Fatalf("load output failed: %#v", output)
+	}
+
+	screen := &testScreen{}
+	ApplyModJsonOutput(output, env, screen)
+	session := env.GetLayer(EnvLayerSession)
+	if session.GetRaw("tidb.port") != "4001" || session.GetRaw("tidb.host") != "10.0.0.1" {
+		t.Fatalf("writes should go to the session layer")
+	}
+	if _, ok := env.GetEx("tidb.addr"); ok {
+		t.Fatalf("deleted key still exists")
+	}
+	if env.GetRaw("sys.stack-depth") != "2" {
+		t.Fatalf("keys in the default layer should not be deleted")
+	}
+	expected := "started\n[artifact] log: /tmp/tidb.log 'tidb log'\n[artifact] /tmp/x\n"
+	if strings.Join(screen.lines, "") != expected {
+		t.Fatalf("wrong screen output: %q", strings.Join(screen.lines, ""))
+	}
+}
+
+func TestLoadModJsonOutputMalformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proto-json-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	test := func(content string) {
+		path := filepath.Join(dir, "mod.output.json")
+		ioutil.WriteFile(path, []byte(content), 0644)
+		defer func() {
+			err := recover()
+			if err == nil || !strings.Contains(fmt.Sprintf("%v", err), "decode json file") {
+				t.Fatalf("load malformed output '%s': unexpected result: %v", content, err)
+			}
+		}()
+		LoadModJsonOutput(path)
+	}
+
+	test("not json")
+	test(`{"env": {"a": 1}}`)
+	test(`{"delete": "a"}`)
+	test(`{"skip-remaining": "yes"}`)
+}
+
+func TestModJsonOutputNextCmdIdx(t *testing.T) {
+	flow := &ParsedCmds{Cmds: make([]ParsedCmd, 4)}
+
+	test := func(skip bool, flow *ParsedCmds, currCmdIdx int, expected int, expectedSkipped bool) {
+		idx, skipped := ModJsonOutputNextCmdIdx(ModJsonOutput{SkipRemaining: skip}, flow, currCmdIdx)
+		if idx != expected || skipped != expectedSkipped {
+			t.Fatalf("next cmd idx(%v, %d): (%d, %v) != (%d, %v)",
+				skip, currCmdIdx, idx, skipped, expected, expectedSkipped)
+		}
+	}
+
+	test(false, flow, 1, 1, false)
+	test(true, flow, 0, 3, true)
+	test(true, flow, 2, 3, true)
+	// The last command has nothing to skip
+	test(true, flow, 3, 3, false)
+	test(true, nil, 0, 0, false)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Use the whole stdout (spaces trimmed) as the value
+const Stdout2EnvWhole = "whole"
+
+// Capture a mod's stdout into env keys, each key has a pattern: 'whole' or a regex.
+// If the regex has groups, the first group is the value, otherwise the whole matched part.
+type Stdout2Env struct {
+	orderedKeys []string
+	patterns    map[string]string
+	regexps     map[string]*regexp.Regexp
+}
+
+func newStdout2Env() *Stdout2Env {
+	return &Stdout2Env{nil, map[string]string{}, map[string]*regexp.Regexp{}}
+}
+
+func (self *Stdout2Env) Add(envKey string, pattern string) {
+	_, ok := self.patterns[envKey]
+	if ok {
+		panic(fmt.Errorf("[Stdout2Env.Add] duplicated key: %s", envKey))
+	}
+	re, err := CompileStdoutPattern(pattern)
+	if err != nil {
+		panic(fmt.Errorf("[Stdout2Env.Add] bad pattern of key '%s': %v", envKey, err))
+	}
+	self.orderedKeys = append(self.orderedKeys, envKey)
+	self.patterns[envKey] = pattern
+	self.regexps[envKey] = re
+}
+
+func (self *Stdout2Env) EnvKeys() []string {
+	return self.orderedKeys
+}
+
+func (self *Stdout2Env) Pattern(envKey string) string {
+	return self.patterns[envKey]
+}
+
+func (self *Stdout2Env) Extract(envKey string, stdout string) (string, bool) {
+	return ExtractStdoutVal(stdout, self.regexps[envKey])
+}
+
+func (self *Stdout2Env) MatchFind(findStr string) bool {
+	if strings.Index("stdout", findStr) >= 0 {
+		return true
+	}
+	for _, envKey := range self.orderedKeys {
+		if strings.Index(envKey, findStr) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Return nil if the pattern is 'whole'
+func CompileStdoutPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) == 0 || pattern == Stdout2EnvWhole {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func ExtractStdoutVal(stdout string, re *regexp.Regexp) (string, bool) {
+	if re == nil {
+		return strings.TrimSpace(stdout), true
+	}
+	matched := re.FindStringSubmatch(stdout)
+	if matched == nil {
+		return "", false
+	}
+	if len(matched) > 1 {
+		return matched[1], true
+	}
+	return matched[0], true
+}
+
+// Run the command, stdout is still streamed to the screen while being captured
+func RunAndCaptureStdout(cmd *exec.Cmd, screen Screen) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	cmd.Stdout = io.MultiWriter(screenWriter{screen}, buf)
+	err := cmd.Run()
+	return buf.String(), err
+}
+
+type screenWriter struct {
+	screen Screen
+}
+
+func (self screenWriter) Write(data []byte) (int, error) {
+	self.screen.Print(string(data))
+	return len(data), nil
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestExtractStdoutVal(t *testing.T) {
+	stdout := "starting\naddr: 10.0.0.1:4000\ndone\n"
+
+	test := func(pattern string, expected string, expectedOk bool) {
+		re, err := CompileStdoutPattern(pattern)
+		if err != nil {
+			t.Fatalf("compile '%s' failed: %v", pattern, err)
+		}
+		val, ok := ExtractStdoutVal(stdout, re)
+		if ok != expectedOk || val != expected {
+			t.Fatalf("extract by '%s': ('%s', %v) != ('%s', %v)", pattern, val, ok, expected, expectedOk)
+		}
+	}
+
+	test(Stdout2EnvWhole, "starting\naddr: 10.0.0.1:4000\ndone", true)
+	test("", "starting\naddr: 10.0.0.1:4000\ndone", true)
+	test(`addr: (\S+)`, "10.0.0.1:4000", true)
+	test(`addr: \S+`, "addr: 10.0.0.1:4000", true)
+	test(`port: (\d+)`, "", false)
+}
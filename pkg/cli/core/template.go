@@ -0,0 +1,104 @@
+package core
+
+import (
+	"strings"
+)
+
+// Render templates like '[[key]]' or '[[key|default-value]]' in a string.
+// When a key can't be found by 'lookup' and it has no default value, return the key as 'missedKey'.
+// A key with spaces is not a template, like '[[ -f file ]]' in a bash command, it's kept as it is
+func RenderTemplateStr(
+	str string,
+	bracketLeft string,
+	bracketRight string,
+	defValSep string,
+	lookup func(key string) (string, bool)) (res string, missedKey string, ok bool) {
+
+	if len(bracketLeft) == 0 || len(bracketRight) == 0 {
+		return str, "", true
+	}
+
+	var rendered []string
+	for {
+		i := strings.Index(str, bracketLeft)
+		if i < 0 {
+			break
+		}
+		tail := str[i+len(bracketLeft):]
+		j := strings.Index(tail, bracketRight)
+		if j < 0 {
+			break
+		}
+
+		key, defVal, hasDefVal := splitTemplateKey(tail[0:j], defValSep)
+		if !isTemplateKey(key) {
+			end := i + len(bracketLeft) + j + len(bracketRight)
+			rendered = append(rendered, str[0:end])
+			str = str[end:]
+			continue
+		}
+		val, found := lookup(key)
+		if !found {
+			if !hasDefVal {
+				return "", key, false
+			}
+			val = defVal
+		}
+
+		rendered = append(rendered, str[0:i], val)
+		str = tail[j+len(bracketRight):]
+	}
+	rendered = append(rendered, str)
+	return strings.Join(rendered, ""), "", true
+}
+
+func splitTemplateKey(str string, defValSep string) (key string, defVal string, hasDefVal bool) {
+	if len(defValSep) == 0 {
+		return strings.TrimSpace(str), "", false
+	}
+	i := strings.Index(str, defValSep)
+	if i < 0 {
+		return strings.TrimSpace(str), "", false
+	}
+	return strings.TrimSpace(str[0:i]), str[i+len(defValSep):], true
+}
+
+func isTemplateKey(key string) bool {
+	return len(key) != 0 && strings.IndexAny(key, " \t\r\n") < 0
+}
+
+// The keys can't be found and have no default values, duplicated ones are only returned once
+func TemplateMissedKeys(
+	str string,
+	bracketLeft string,
+	bracketRight string,
+	defValSep string,
+	lookup func(key string) (string, bool)) (missedKeys []string) {
+
+	if len(bracketLeft) == 0 || len(bracketRight) == 0 {
+		return
+	}
+	metKeys := map[string]bool{}
+	for {
+		i := strings.Index(str, bracketLeft)
+		if i < 0 {
+			break
+		}
+		tail := str[i+len(bracketLeft):]
+		j := strings.Index(tail, bracketRight)
+		if j < 0 {
+			break
+		}
+		key, _, hasDefVal := splitTemplateKey(tail[0:j], defValSep)
+		if !isTemplateKey(key) {
+			str = tail[j+len(bracketRight):]
+			continue
+		}
+		if _, found := lookup(key); !found && !hasDefVal && !metKeys[key] {
+			metKeys[key] = true
+			missedKeys = append(missedKeys, key)
+		}
+		str = tail[j+len(bracketRight):]
+	}
+	return
+}
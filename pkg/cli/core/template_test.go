@@ -0,0 +1,26 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateMissedKeys(t *testing.T) {
+	vals := map[string]string{"host": "h1"}
+	lookup := func(key string) (string, bool) {
+		val, ok := vals[key]
+		return val, ok
+	}
+	test := func(str string, expected ...string) {
+		missed := TemplateMissedKeys(str, "[[", "]]", "|", lookup)
+		if strings.Join(missed, ",") != strings.Join(expected, ",") {
+			t.Fatalf("missed keys of '%s': %v != %v", str, missed, expected)
+		}
+	}
+
+	test("echo [[host]]")
+	test("echo [[threads]] [[host]] [[port]]", "threads", "port")
+	test("echo [[threads]]\necho [[threads]]", "threads")
+	test("echo [[threads|8]] [[port|]]")
+	test("echo [[threads")
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The hooks of executing, for flow tests: replace commands by mocks, and trace the executed commands
+type TestHooks struct {
+	Mocks map[*Cmd]CmdMock
+	// The display paths of the executed (or mocked) commands, flows are not included
+	Executed []string
+}
+
+func NewTestHooks() *TestHooks {
+	return &TestHooks{map[*Cmd]CmdMock{}, nil}
+}
+
+func (self *TestHooks) Trace(cmd *Cmd) {
+	if cmd.Type() == CmdTypeFlow {
+		if _, ok := self.Mocks[cmd]; !ok {
+			return
+		}
+	}
+	self.Executed = append(self.Executed, cmd.Owner().DisplayPath())
+}
+
+// A mock writes the env key-values to session, as if the command did it
+type CmdMock struct {
+	Env map[string]string
+}
+
+func (self CmdMock) Execute(cc *Cli, env *Env, cmd *Cmd) bool {
+	var keys []string
+	for key, _ := range self.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sessionEnv := env.GetLayer(EnvLayerSession)
+	for _, key := range keys {
+		sessionEnv.Set(key, self.Env[key])
+	}
+	cc.Screen.Print(fmt.Sprintf("[%s] (mocked)\n", cmd.Owner().DisplayPath()))
+	return true
+}
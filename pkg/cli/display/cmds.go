@@ -1,6 +1,7 @@
 package display
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/pingcap/ticat/pkg/cli/core"
@@ -219,6 +220,9 @@ func dumpCmd(
 				val := args.DefVal(name)
 				nameStr := strings.Join(args.Abbrs(name), abbrsSep)
 				prt(2, nameStr+" = "+mayQuoteStr(val))
+				if help := args.Help(name); len(help) != 0 {
+					prt(2, " '"+help+"'")
+				}
 			}
 
 			val2env := cic.GetVal2Env()
@@ -237,6 +241,14 @@ func dumpCmd(
 				prt(2, k+" <- "+mayQuoteStr(arg2env.GetArgName(k)))
 			}
 
+			stdout2env := cic.GetStdout2Env()
+			if len(stdout2env.EnvKeys()) != 0 {
+				prt(1, "- env-from-stdout:")
+			}
+			for _, k := range stdout2env.EnvKeys() {
+				prt(2, k+" <- "+mayQuoteStr(stdout2env.Pattern(k)))
+			}
+
 			envOps := cic.EnvOps()
 			envOpKeys := envOps.EnvKeys()
 			if len(envOpKeys) != 0 {
@@ -262,6 +274,9 @@ func dumpCmd(
 				if cic.IsPriority() {
 					line += " (priority)"
 				}
+				if cic.Protocol() != core.ModProtocolEnvFile {
+					line += " (protocol: " + string(cic.Protocol()) + ")"
+				}
 				prt(1, "- cmd-type:")
 				prt(2, line)
 			}
@@ -275,6 +290,14 @@ func dumpCmd(
 				}
 			}
 
+			if cic.Shadowed() != nil {
+				prt(1, "- shadowed:")
+				for it := cic.Shadowed(); it != nil; it = it.Shadowed() {
+					prt(2, fmt.Sprintf("%s (priority %d, this one %d)", it.Source(),
+						it.SourcePriority(), cic.SourcePriority()))
+				}
+			}
+
 			if cic.Type() != core.CmdTypeNormal && cic.Type() != core.CmdTypePower {
 				if len(cic.CmdLine()) != 0 || len(cic.FlowStrs()) != 0 {
 					if cic.Type() == core.CmdTypeFlow {
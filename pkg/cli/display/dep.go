@@ -76,6 +76,17 @@ func CollectDepends(
 	res Depends,
 	allowFlowTemplateRenderError bool) {
 
+	collectDepends(cc, env, flow, res, allowFlowTemplateRenderError, cc.FlowStack)
+}
+
+func collectDepends(
+	cc *core.Cli,
+	env *core.Env,
+	flow []core.ParsedCmd,
+	res Depends,
+	allowFlowTemplateRenderError bool,
+	stack core.FlowStack) {
+
 	for _, it := range flow {
 		cic := it.LastCmd()
 		if cic == nil {
@@ -90,15 +101,20 @@ func CollectDepends(
 				res[dep.OsCmd] = map[*core.Cmd]DependInfo{cic: DependInfo{dep.Reason, it}}
 			}
 		}
-		cmdEnv, _ := it.GenEnvAndArgv(env, cc.Cmds.Strs.EnvValDelAllMark, cc.Cmds.Strs.PathSep)
+		cmdEnv, argv := it.GenEnvAndArgv(env, cc.Cmds.Strs.EnvValDelAllMark, cc.Cmds.Strs.PathSep)
 		if cic.Type() != core.CmdTypeFlow {
 			continue
 		}
-		subFlow, rendered := cic.Flow(cmdEnv, allowFlowTemplateRenderError)
+		subFlow, rendered := cic.Flow(cmdEnv, argv, allowFlowTemplateRenderError)
 		if rendered && len(subFlow) != 0 {
-			parsedFlow := cc.Parser.Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
+			// Cycles and too deep flows are reported by env-ops checking, here just stop collecting
+			subStack, ok := pushFlowStack(stack, cic, subFlow, env.GetInt("sys.flow.max-depth"))
+			if !ok {
+				continue
+			}
+			parsedFlow := cic.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
 			// Allow parse errors here
-			CollectDepends(cc, cmdEnv, parsedFlow.Cmds, res, allowFlowTemplateRenderError)
+			collectDepends(cc, cmdEnv, parsedFlow.Cmds, res, allowFlowTemplateRenderError, subStack)
 		}
 	}
 }
@@ -107,3 +123,14 @@ func isOsCmdExists(cmd string) bool {
 	path, err := exec.LookPath(cmd)
 	return err == nil && len(path) > 0
 }
+
+func pushFlowStack(stack core.FlowStack, cic *core.Cmd, flow []string, maxDepth int) (pushed core.FlowStack, ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			ok = false
+		}
+	}()
+	pushed = stack.Push(cic, flow)
+	pushed.CheckDepth(len(pushed), maxDepth)
+	return pushed, true
+}
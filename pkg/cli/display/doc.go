@@ -0,0 +1,416 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+const (
+	CmdDocFormatMarkdown = "markdown"
+	CmdDocFormatMan      = "man"
+)
+
+// A generated doc file, the name is relative to the output dir
+type CmdDocPage struct {
+	File  string
+	Lines []string
+}
+
+// Generate one page for each command, and an index page grouped by repo and by tags (eg: '@ready').
+// If repo is not empty, only the commands from that repo are included, 'builtin' means the builtin ones
+func GenCmdDocs(cc *core.Cli, env *core.Env, format string, repo string) (pages []CmdDocPage) {
+	if format != CmdDocFormatMarkdown && format != CmdDocFormatMan {
+		panic(fmt.Errorf("[GenCmdDocs] unknown doc format '%s', should be '%s' or '%s'",
+			format, CmdDocFormatMarkdown, CmdDocFormatMan))
+	}
+	env = env.Clone()
+	selfName := env.GetRaw("strs.self-name")
+	builtinName := cc.Cmds.Strs.BuiltinDisplayName
+	if repo == "builtin" {
+		repo = builtinName
+	}
+
+	var docs []*cmdDoc
+	var collect func(node *core.CmdTree)
+	collect = func(node *core.CmdTree) {
+		if node.IsHidden() {
+			return
+		}
+		cic := node.Cmd()
+		if cic != nil && cic.Type() != core.CmdTypeEmptyDir {
+			source := cic.Source()
+			if len(source) == 0 {
+				source = builtinName
+			}
+			if len(repo) == 0 || repo == source {
+				docs = append(docs, newCmdDoc(cc, env, node, source, selfName))
+			}
+		}
+		for _, name := range node.SubNames() {
+			collect(node.GetSub(name))
+		}
+	}
+	collect(cc.Cmds)
+
+	for _, doc := range docs {
+		if format == CmdDocFormatMarkdown {
+			pages = append(pages, CmdDocPage{doc.mdFile(), doc.markdown()})
+		} else {
+			pages = append(pages, CmdDocPage{doc.manFile(selfName), doc.man(selfName)})
+		}
+	}
+	if format == CmdDocFormatMarkdown {
+		pages = append(pages, CmdDocPage{"README.md", cmdDocIndexMarkdown(docs, selfName)})
+	} else {
+		pages = append(pages, CmdDocPage{selfName + ".1", cmdDocIndexMan(docs, selfName)})
+	}
+	return
+}
+
+type cmdDoc struct {
+	path     string
+	help     string
+	tags     []string
+	source   string
+	usage    string
+	sections []cmdDocSection
+}
+
+// A section is a list of items (term and description lines), or a preformatted block
+type cmdDocSection struct {
+	title string
+	items []cmdDocItem
+	code  []string
+}
+
+type cmdDocItem struct {
+	term string
+	desc []string
+}
+
+func newCmdDoc(cc *core.Cli, env *core.Env, node *core.CmdTree, source string, selfName string) *cmdDoc {
+	cic := node.Cmd()
+	abbrsSep := cc.Cmds.Strs.AbbrsSep
+	doc := &cmdDoc{path: node.DisplayPath(), help: cic.Help(), source: source}
+	for _, word := range strings.Fields(cic.Help()) {
+		if len(word) > 1 && word[0] == '@' {
+			doc.tags = append(doc.tags, word)
+		}
+	}
+
+	args := cic.Args()
+	usage := []string{selfName, doc.path}
+	argsSection := cmdDocSection{title: "Args"}
+	for _, name := range args.Names() {
+		usage = append(usage, "["+name+"=...]")
+		desc := []string{"default: " + mayQuoteStr(args.DefVal(name))}
+		if help := args.Help(name); len(help) != 0 {
+			desc = append(desc, help)
+		}
+		argsSection.items = append(argsSection.items,
+			cmdDocItem{strings.Join(args.Abbrs(name), abbrsSep), desc})
+	}
+	doc.usage = strings.Join(usage, " ")
+
+	if abbrs := node.DisplayAbbrsPath(); len(abbrs) != 0 && abbrs != doc.path {
+		doc.add(cmdDocSection{title: "Abbrs", code: []string{abbrs}})
+	}
+	doc.add(argsSection)
+
+	envOps := cmdDocSection{title: "Env ops"}
+	ops := cic.EnvOps()
+	for _, key := range ops.EnvKeys() {
+		envOps.items = append(envOps.items,
+			cmdDocItem{key, []string{dumpEnvOps(ops.Ops(key), " "+cc.Cmds.Strs.EnvOpSep+" ")}})
+	}
+	doc.add(envOps)
+
+	val2env := cmdDocSection{title: "Env from values (val2env)"}
+	for _, key := range cic.GetVal2Env().EnvKeys() {
+		val2env.items = append(val2env.items,
+			cmdDocItem{key, []string{"= " + mayQuoteStr(cic.GetVal2Env().Val(key))}})
+	}
+	doc.add(val2env)
+
+	arg2env := cmdDocSection{title: "Env from args (arg2env)"}
+	for _, key := range cic.GetArg2Env().EnvKeys() {
+		arg2env.items = append(arg2env.items,
+			cmdDocItem{key, []string{"<- arg " + mayQuoteStr(cic.GetArg2Env().GetArgName(key))}})
+	}
+	doc.add(arg2env)
+
+	stdout2env := cmdDocSection{title: "Env from stdout"}
+	for _, key := range cic.GetStdout2Env().EnvKeys() {
+		stdout2env.items = append(stdout2env.items,
+			cmdDocItem{key, []string{"<- pattern " + mayQuoteStr(cic.GetStdout2Env().Pattern(key))}})
+	}
+	doc.add(stdout2env)
+
+	deps := cmdDocSection{title: "OS dependencies"}
+	for _, dep := range cic.GetDepends() {
+		deps.items = append(deps.items, cmdDocItem{dep.OsCmd, []string{dep.Reason}})
+	}
+	doc.add(deps)
+
+	from := cmdDocSection{title: "Source"}
+	from.items = append(from.items, cmdDocItem{"repo", []string{source}})
+	from.items = append(from.items, cmdDocItem{"type", []string{string(cic.Type())}})
+	if len(cic.MetaFile()) != 0 {
+		from.items = append(from.items, cmdDocItem{"meta", []string{cic.MetaFile()}})
+	}
+	doc.add(from)
+
+	if cic.Type() == core.CmdTypeFlow {
+		doc.add(cmdDocSection{title: "Flow", code: cic.FlowStrs()})
+		doc.add(cmdDocSection{title: "Expanded flow", code: expandFlowForDoc(cc, env, cic, 0, nil)})
+	}
+	return doc
+}
+
+func (self *cmdDoc) add(section cmdDocSection) {
+	if len(section.items) != 0 || len(section.code) != 0 {
+		self.sections = append(self.sections, section)
+	}
+}
+
+func (self *cmdDoc) mdFile() string {
+	return self.path + ".md"
+}
+
+func (self *cmdDoc) manFile(selfName string) string {
+	return selfName + "-" + self.path + ".1"
+}
+
+// The flow rendered by the default values of args, the sub flows are expanded recursively
+func expandFlowForDoc(
+	cc *core.Cli,
+	env *core.Env,
+	cic *core.Cmd,
+	depth int,
+	stack []*core.Cmd) (lines []string) {
+
+	indent := rpt(" ", depth*4)
+	for _, it := range stack {
+		if it == cic {
+			return []string{indent + "(flow cycle detected, not expanded)"}
+		}
+	}
+	maxDepth := env.GetInt("sys.flow.max-depth")
+	if maxDepth > 0 && depth >= maxDepth {
+		return []string{indent + "(too deep, not expanded)"}
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			lines = append(lines, indent+fmt.Sprintf("(failed to expand: %v)", err))
+		}
+	}()
+
+	argv := core.ArgVals{}
+	args := cic.Args()
+	for _, name := range args.Names() {
+		argv[name] = core.ArgVal{args.DefVal(name), false}
+	}
+	flow, rendered := cic.Flow(env, argv, true)
+	if !rendered {
+		return []string{indent + "(need args to render, not expanded)"}
+	}
+	parsedFlow := cic.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, flow...)
+	if err := parsedFlow.FirstErr(); err != nil {
+		return []string{indent + fmt.Sprintf("(failed to parse: %v)", err.Error)}
+	}
+	flowEnv := env
+	if parsedFlow.GlobalEnv != nil {
+		flowEnv = env.Clone().GetOrNewLayer(core.EnvLayerTmp)
+		parsedFlow.GlobalEnv.WriteNotArgTo(flowEnv, cc.Cmds.Strs.EnvValDelAllMark)
+	}
+
+	sep := cc.Cmds.Strs.PathSep
+	stack = append(stack, cic)
+	for _, parsedCmd := range parsedFlow.Cmds {
+		sub := parsedCmd.LastCmd()
+		if parsedCmd.IsEmpty() || sub == nil {
+			continue
+		}
+		_, subArgv := parsedCmd.GenEnvAndArgv(flowEnv, cc.Cmds.Strs.EnvValDelAllMark, sep)
+		line := indent + strings.Join(parsedCmd.Path(), sep)
+		subArgs := parsedCmd.Args()
+		for _, name := range subArgs.Names() {
+			if val := subArgv[name]; val.Provided {
+				line += " " + name + "=" + mayQuoteStr(val.Raw)
+			}
+		}
+		lines = append(lines, line)
+		if sub.Type() == core.CmdTypeFlow {
+			lines = append(lines, expandFlowForDoc(cc, flowEnv, sub, depth+1, stack)...)
+		}
+	}
+	return
+}
+
+func (self *cmdDoc) markdown() (lines []string) {
+	lines = append(lines, "# "+self.path, "")
+	if len(self.help) != 0 {
+		lines = append(lines, mdEscape(self.help), "")
+	}
+	if len(self.tags) != 0 {
+		lines = append(lines, "Tags: `"+strings.Join(self.tags, "` `")+"`", "")
+	}
+	lines = append(lines, "## Usage", "```", self.usage, "```", "")
+	for _, section := range self.sections {
+		lines = append(lines, "## "+section.title)
+		if len(section.code) != 0 {
+			lines = append(lines, "```")
+			lines = append(lines, section.code...)
+			lines = append(lines, "```")
+		}
+		for _, item := range section.items {
+			line := "- `" + item.term + "`"
+			if len(item.desc) != 0 {
+				line += ": " + mdEscape(item.desc[0])
+			}
+			lines = append(lines, line)
+			for _, desc := range item.desc[1:] {
+				lines = append(lines, "  "+mdEscape(desc))
+			}
+		}
+		lines = append(lines, "")
+	}
+	return
+}
+
+func (self *cmdDoc) man(selfName string) (lines []string) {
+	title := strings.ToUpper(selfName + "-" + self.path)
+	lines = append(lines, fmt.Sprintf(".TH \"%s\" \"1\" \"\" \"%s\" \"%s commands\"", title, selfName, selfName))
+	lines = append(lines, ".SH NAME")
+	if len(self.help) != 0 {
+		lines = append(lines, manEscape(self.path+" - "+self.help))
+	} else {
+		lines = append(lines, manEscape(self.path))
+	}
+	lines = append(lines, ".SH SYNOPSIS", manEscape(self.usage))
+	if len(self.tags) != 0 {
+		lines = append(lines, ".SH TAGS", manEscape(strings.Join(self.tags, " ")))
+	}
+	for _, section := range self.sections {
+		lines = append(lines, ".SH "+manEscape(strings.ToUpper(section.title)))
+		if len(section.code) != 0 {
+			lines = append(lines, ".nf")
+			for _, line := range section.code {
+				lines = append(lines, manEscape(line))
+			}
+			lines = append(lines, ".fi")
+		}
+		for _, item := range section.items {
+			lines = append(lines, ".TP", ".B "+manEscape(item.term))
+			for i, desc := range item.desc {
+				if i != 0 {
+					lines = append(lines, ".br")
+				}
+				lines = append(lines, manEscape(desc))
+			}
+		}
+	}
+	lines = append(lines, ".SH SEE ALSO", manEscape(selfName+"(1)"))
+	return
+}
+
+// Group the commands by repo and by tag, both sorted by name
+func groupCmdDocs(docs []*cmdDoc) (repos []string, byRepo map[string][]*cmdDoc, tags []string, byTag map[string][]*cmdDoc) {
+	byRepo = map[string][]*cmdDoc{}
+	byTag = map[string][]*cmdDoc{}
+	for _, doc := range docs {
+		if _, ok := byRepo[doc.source]; !ok {
+			repos = append(repos, doc.source)
+		}
+		byRepo[doc.source] = append(byRepo[doc.source], doc)
+		for _, tag := range doc.tags {
+			if _, ok := byTag[tag]; !ok {
+				tags = append(tags, tag)
+			}
+			byTag[tag] = append(byTag[tag], doc)
+		}
+	}
+	sort.Strings(repos)
+	sort.Strings(tags)
+	return
+}
+
+func cmdDocIndexMarkdown(docs []*cmdDoc, selfName string) (lines []string) {
+	repos, byRepo, tags, byTag := groupCmdDocs(docs)
+	link := func(doc *cmdDoc) string {
+		line := "- [" + doc.path + "](" + doc.mdFile() + ")"
+		if len(doc.help) != 0 {
+			line += ": " + mdEscape(doc.help)
+		}
+		return line
+	}
+	lines = append(lines, "# "+selfName+" commands", "")
+	lines = append(lines, fmt.Sprintf("%d commands from %d repos.", len(docs), len(repos)), "")
+	lines = append(lines, "## By repo", "")
+	for _, repo := range repos {
+		lines = append(lines, "### "+mdEscape(repo), "")
+		for _, doc := range byRepo[repo] {
+			lines = append(lines, link(doc))
+		}
+		lines = append(lines, "")
+	}
+	if len(tags) != 0 {
+		lines = append(lines, "## By tag", "")
+		for _, tag := range tags {
+			lines = append(lines, "### "+tag, "")
+			for _, doc := range byTag[tag] {
+				lines = append(lines, link(doc))
+			}
+			lines = append(lines, "")
+		}
+	}
+	return
+}
+
+func cmdDocIndexMan(docs []*cmdDoc, selfName string) (lines []string) {
+	repos, byRepo, tags, byTag := groupCmdDocs(docs)
+	item := func(doc *cmdDoc) []string {
+		lines := []string{".TP", ".B " + manEscape(doc.path)}
+		if len(doc.help) != 0 {
+			lines = append(lines, manEscape(doc.help))
+		}
+		return lines
+	}
+	lines = append(lines, fmt.Sprintf(".TH \"%s\" \"1\" \"\" \"%s\" \"%s commands\"",
+		strings.ToUpper(selfName), selfName, selfName))
+	lines = append(lines, ".SH NAME", manEscape(selfName+" - commands index"))
+	lines = append(lines, ".SH BY REPO")
+	for _, repo := range repos {
+		lines = append(lines, ".SS "+manEscape(repo))
+		for _, doc := range byRepo[repo] {
+			lines = append(lines, item(doc)...)
+		}
+	}
+	if len(tags) != 0 {
+		lines = append(lines, ".SH BY TAG")
+		for _, tag := range tags {
+			lines = append(lines, ".SS "+manEscape(tag))
+			for _, doc := range byTag[tag] {
+				lines = append(lines, item(doc)...)
+			}
+		}
+	}
+	return
+}
+
+func mdEscape(text string) string {
+	replacer := strings.NewReplacer("<", "&lt;", ">", "&gt;", "|", "\\|", "*", "\\*", "_", "\\_")
+	return replacer.Replace(text)
+}
+
+func manEscape(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\e")
+	text = strings.ReplaceAll(text, "-", "\\-")
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = "\\&" + text
+	}
+	return text
+}
@@ -26,15 +26,64 @@ func PrintError(cc *core.Cli, env *core.Env, err error) {
 	switch err.(type) {
 	case core.CmdMissedEnvValWhenRenderFlow:
 		e := err.(core.CmdMissedEnvValWhenRenderFlow)
-		PrintErrTitle(cc.Screen, env,
-			e.Error()+" from repo/dir:",
-			"    - '"+e.Source+"'",
+		lines := []interface{}{
+			e.Error() + " from repo/dir:",
+			"    - '" + e.Source + "'",
 			"command:",
-			"    - '"+e.CmdPath+"'",
+			"    - '" + e.CmdPath + "'",
 			"file:",
-			"    - '"+e.MetaFilePath+"'",
+			"    - '" + e.MetaFilePath + "'",
+			"missed-keys:",
+		}
+		params := map[string]bool{}
+		for _, key := range e.MissedParams {
+			params[key] = true
+		}
+		for _, key := range e.MissedKeys {
+			if params[key] {
+				lines = append(lines, "    - "+key+" (param)")
+			} else {
+				lines = append(lines, "    - "+key)
+			}
+		}
+		if len(e.MissedParams) != 0 {
+			lines = append(lines, "", "the params have no default values, pass them as args:", "",
+				"    "+e.CmdPath+" "+strings.Join(e.MissedParams, "=<value> ")+"=<value>")
+		}
+		PrintErrTitle(cc.Screen, env, lines...)
+	case core.CmdFlowCycleDetected:
+		e := err.(core.CmdFlowCycleDetected)
+		PrintErrTitle(cc.Screen, env, flowCycleErrLines(e)...)
+	case core.CmdFlowTooDeep:
+		e := err.(core.CmdFlowTooDeep)
+		lines := []interface{}{e.Error(), "", "flow call stack:"}
+		lines = append(lines, flowCallInfoLines(e.Stack)...)
+		lines = append(lines, "", fmt.Sprintf("if it's expected, set a bigger value to 'sys.flow.max-depth' (now %d).",
+			e.MaxDepth))
+		PrintErrTitle(cc.Screen, env, lines...)
+	case core.EnvValErrRefMissed:
+		e := err.(core.EnvValErrRefMissed)
+		templ := env.Get("strs.flow-template-bracket-left").Raw + e.MissedKey +
+			env.Get("strs.flow-template-default-sep").Raw + "default" +
+			env.Get("strs.flow-template-bracket-right").Raw
+		PrintErrTitle(cc.Screen, env,
+			"render env value failed, referred key missed.",
+			"reading key:",
+			"    - "+e.Key,
+			"referred by:",
+			"    - "+e.RefBy+" = "+e.Val,
 			"missed-key:",
-			"    - "+e.MissedKey)
+			"    - "+e.MissedKey,
+			"",
+			"provide the key, or give it a default value like '"+templ+"'.")
+	case core.EnvValErrRefCycle:
+		e := err.(core.EnvValErrRefCycle)
+		PrintErrTitle(cc.Screen, env,
+			"render env value failed, cyclic reference found.",
+			"reading key:",
+			"    - "+e.Key,
+			"reference path:",
+			"    - "+strings.Join(e.RefPath, " -> "))
 	case *core.CmdError:
 		e := err.(*core.CmdError)
 		sep := cc.Cmds.Strs.PathSep
@@ -58,7 +107,14 @@ func PrintSepTitle(screen core.Screen, env *core.Env, msg string) {
 }
 
 func PrintTolerableErrs(screen core.Screen, env *core.Env, errs *core.TolerableErrs) {
+	printShadowedCmds(screen, env, errs.Shadoweds)
+
 	for _, err := range errs.Uncatalogeds {
+		if e, ok := err.Err.(core.CmdFlowCycleDetected); ok {
+			lines := []interface{}{err.Reason + ", from repo/dir:", "    - '" + err.Source + "'", ""}
+			PrintErrTitle(screen, env, append(lines, flowCycleErrLines(e)...)...)
+			continue
+		}
 		PrintErrTitle(screen, env,
 			err.Reason+", from repo/dir:",
 			"    - '"+err.Source+"'",
@@ -111,7 +167,8 @@ func PrintTolerableErrs(screen core.Screen, env *core.Env, errs *core.TolerableE
 					"    - '"+oldSource+"'",
 					"    - '"+newSource+"' (conflicteds are not loaded)",
 					"",
-					"use command 'h.disable' to disable one of them.",
+					"use command 'h.disable' to disable one of them,",
+					"or 'h.priority' to let one of them shadow the other.",
 				)
 			} else {
 				for _, err := range list {
@@ -125,10 +182,49 @@ func PrintTolerableErrs(screen core.Screen, env *core.Env, errs *core.TolerableE
 						"detail:",
 						"    - "+err.Err.(error).Error(),
 						"",
-						"use command 'h.disable' to disable one of the repo/dir, or edit the command.",
+						"use command 'h.disable' to disable one of the repo/dir, or edit the command,",
+						"or 'h.priority' to let one of them shadow the other.",
 					)
 				}
 			}
 		}
 	}
 }
+
+// Shadowing is intentional (by setting repo priority), so it's a tip, not an error
+func printShadowedCmds(screen core.Screen, env *core.Env, shadoweds []core.ShadowedCmd) {
+	if len(shadoweds) == 0 || !env.GetBool("display.mod.shadowed") {
+		return
+	}
+	sep := env.GetRaw("strs.cmd-path-sep")
+	msgs := []interface{}{"commands shadowed by higher priority repos/dirs:", ""}
+	for _, it := range shadoweds {
+		msgs = append(msgs,
+			"    - "+strings.Join(it.CmdPath, sep),
+			fmt.Sprintf("        '%s' (priority %d) shadows '%s' (priority %d)",
+				it.Source, it.Priority, it.OldSource, it.OldPriority))
+	}
+	msgs = append(msgs, "", "use 'hub.priority' to change priorities, set 'display.mod.shadowed=false' to hide this.")
+	PrintTipTitle(screen, env, msgs...)
+}
+
+func flowCycleErrLines(e core.CmdFlowCycleDetected) []interface{} {
+	lines := []interface{}{e.Str, "", "cycle path:"}
+	lines = append(lines, flowCallInfoLines(e.Cycle)...)
+	return append(lines, "", "edit one of the flows to break the cycle.")
+}
+
+func flowCallInfoLines(infos []core.FlowCallInfo) (lines []interface{}) {
+	for i, it := range infos {
+		prefix := "    -> "
+		if i == 0 {
+			prefix = "    "
+		}
+		source := it.Source
+		if len(source) == 0 {
+			source = "<builtin>"
+		}
+		lines = append(lines, prefix+"["+it.CmdPath+"]", "        from '"+source+"'")
+	}
+	return
+}
@@ -130,6 +130,14 @@ func dumpFlowCmd(
 			prt(2, k+" <- "+mayQuoteStr(arg2env.GetArgName(k)))
 		}
 
+		stdout2env := cic.GetStdout2Env()
+		if len(stdout2env.EnvKeys()) != 0 {
+			prt(1, "- env-from-stdout:")
+		}
+		for _, k := range stdout2env.EnvKeys() {
+			prt(2, k+" <- "+mayQuoteStr(stdout2env.Pattern(k)))
+		}
+
 		envOps := cic.EnvOps()
 		envOpKeys := envOps.EnvKeys()
 		if len(envOpKeys) != 0 {
@@ -161,7 +169,7 @@ func dumpFlowCmd(
 		cic.Type() != core.CmdTypeNormal && cic.Type() != core.CmdTypePower {
 		metFlow := false
 		if cic.Type() == core.CmdTypeFlow {
-			flowStrs, _ := cic.RenderedFlowStrs(cmdEnv, true)
+			flowStrs, _ := cic.RenderedFlowStrs(cmdEnv, argv, true)
 			flowStr := strings.Join(flowStrs, " ")
 			metFlow = metFlows[flowStr]
 			if metFlow {
@@ -187,11 +195,11 @@ func dumpFlowCmd(
 			}
 		}
 		if cic.Type() == core.CmdTypeFlow && maxDepth > 1 {
-			subFlow, rendered := cic.Flow(cmdEnv, true)
+			subFlow, rendered := cic.Flow(cmdEnv, argv, true)
 			if rendered && len(subFlow) != 0 {
 				if !metFlow {
 					prt(2, "--->>>")
-					parsedFlow := cc.Parser.Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
+					parsedFlow := cic.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
 					err := parsedFlow.FirstErr()
 					if err != nil {
 						panic(err.Error)
@@ -0,0 +1,232 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+const (
+	FlowGraphFormatDot     = "dot"
+	FlowGraphFormatMermaid = "mermaid"
+)
+
+// Output the flattened flow as a graph:
+//   - nodes are commands, annotated with args
+//   - solid edges are the execution order
+//   - dashed edges are env-key data flow, from the writer to the readers (by env-ops)
+//   - nested flows are drawn as clusters
+func DumpFlowGraph(
+	cc *core.Cli,
+	env *core.Env,
+	flow []core.ParsedCmd,
+	format string) {
+
+	env = env.Clone()
+	maxDepth := env.GetInt("display.flow.depth")
+
+	graph := newFlowGraph()
+	graph.collect(cc, env, flow, graph.root, maxDepth)
+
+	var lines []string
+	switch format {
+	case FlowGraphFormatDot:
+		lines = graph.dot()
+	case FlowGraphFormatMermaid:
+		lines = graph.mermaid()
+	default:
+		panic(fmt.Errorf("[DumpFlowGraph] unknown graph format '%s', should be '%s' or '%s'",
+			format, FlowGraphFormatDot, FlowGraphFormatMermaid))
+	}
+	for _, line := range lines {
+		cc.Screen.Print(line + "\n")
+	}
+}
+
+type flowGraphNode struct {
+	id   string
+	name string
+	args []string
+	// Not nil if it's a cluster (a nested flow)
+	nodes []*flowGraphNode
+}
+
+type flowGraphEdge struct {
+	from string
+	to   string
+	// Empty for execution order edges
+	key   string
+	maybe bool
+}
+
+type flowGraph struct {
+	root    *flowGraphNode
+	edges   []flowGraphEdge
+	lastCmd string
+	writers map[string]flowGraphEdge
+	metEdge map[flowGraphEdge]bool
+	count   int
+}
+
+func newFlowGraph() *flowGraph {
+	root := &flowGraphNode{"", "", nil, []*flowGraphNode{}}
+	return &flowGraph{root, nil, "", map[string]flowGraphEdge{}, map[flowGraphEdge]bool{}, 0}
+}
+
+func (self *flowGraph) collect(
+	cc *core.Cli,
+	env *core.Env,
+	flow []core.ParsedCmd,
+	parent *flowGraphNode,
+	maxDepth int) {
+
+	sep := cc.Cmds.Strs.PathSep
+	for _, parsedCmd := range flow {
+		cic := parsedCmd.LastCmd()
+		if parsedCmd.IsEmpty() || cic == nil {
+			continue
+		}
+		cmdEnv, argv := parsedCmd.GenEnvAndArgv(env, cc.Cmds.Strs.EnvValDelAllMark, sep)
+		self.count += 1
+		node := &flowGraphNode{fmt.Sprintf("n%d", self.count), strings.Join(parsedCmd.Path(), sep), nil, nil}
+		args := parsedCmd.Args()
+		for _, name := range args.Names() {
+			if val := argv[name].Raw; len(val) != 0 {
+				node.args = append(node.args, name+"="+val)
+			}
+		}
+		parent.nodes = append(parent.nodes, node)
+
+		if cic.Type() != core.CmdTypeFlow || maxDepth <= 1 {
+			self.addCmd(node.id, cic.EnvOps())
+			continue
+		}
+
+		subFlow, rendered := cic.Flow(cmdEnv, argv, true)
+		if !rendered || len(subFlow) == 0 {
+			self.addCmd(node.id, cic.EnvOps())
+			continue
+		}
+		parsedFlow := cic.FlowParser(cc).Parse(cc.Cmds, cc.EnvAbbrs, subFlow...)
+		err := parsedFlow.FirstErr()
+		if err != nil {
+			panic(err.Error)
+		}
+		subEnv := env
+		if parsedFlow.GlobalEnv != nil {
+			subEnv = env.GetOrNewLayer(core.EnvLayerTmp)
+			parsedFlow.GlobalEnv.WriteNotArgTo(subEnv, cc.Cmds.Strs.EnvValDelAllMark)
+		}
+		node.nodes = []*flowGraphNode{}
+		self.collect(cc, subEnv, parsedFlow.Cmds, node, maxDepth-1)
+	}
+}
+
+func (self *flowGraph) addCmd(id string, ops core.EnvOps) {
+	if len(self.lastCmd) != 0 {
+		self.addEdge(flowGraphEdge{self.lastCmd, id, "", false})
+	}
+	self.lastCmd = id
+
+	for _, key := range ops.EnvKeys() {
+		for _, op := range ops.Ops(key) {
+			if (op & (core.EnvOpTypeRead | core.EnvOpTypeMayRead)) != 0 {
+				writer, ok := self.writers[key]
+				if ok && writer.from != id {
+					maybe := writer.maybe || (op&core.EnvOpTypeRead) == 0
+					self.addEdge(flowGraphEdge{writer.from, id, key, maybe})
+				}
+			}
+			if (op & (core.EnvOpTypeWrite | core.EnvOpTypeMayWrite)) != 0 {
+				self.writers[key] = flowGraphEdge{id, "", key, (op & core.EnvOpTypeWrite) == 0}
+			}
+		}
+	}
+}
+
+func (self *flowGraph) addEdge(edge flowGraphEdge) {
+	if self.metEdge[edge] {
+		return
+	}
+	self.metEdge[edge] = true
+	self.edges = append(self.edges, edge)
+}
+
+func (self *flowGraph) dot() (lines []string) {
+	quote := func(str string) string {
+		str = strings.ReplaceAll(str, "\\", "\\\\")
+		return "\"" + strings.ReplaceAll(str, "\"", "\\\"") + "\""
+	}
+
+	lines = append(lines, "digraph flow {")
+	lines = append(lines, "    node [shape=box];")
+	var dump func(node *flowGraphNode, indent string)
+	dump = func(node *flowGraphNode, indent string) {
+		if node.nodes == nil {
+			label := quote(strings.Join(append([]string{node.name}, node.args...), "\n"))
+			label = strings.ReplaceAll(label, "\n", "\\n")
+			lines = append(lines, indent+node.id+" [label="+label+"];")
+			return
+		}
+		lines = append(lines, indent+"subgraph cluster_"+node.id+" {")
+		lines = append(lines, indent+"    label="+quote(strings.Join(append([]string{node.name}, node.args...), " "))+";")
+		for _, it := range node.nodes {
+			dump(it, indent+"    ")
+		}
+		lines = append(lines, indent+"}")
+	}
+	for _, node := range self.root.nodes {
+		dump(node, "    ")
+	}
+	for _, edge := range self.edges {
+		line := "    " + edge.from + " -> " + edge.to
+		if len(edge.key) != 0 {
+			style := "dashed"
+			if edge.maybe {
+				style = "dotted"
+			}
+			line += " [style=" + style + ", color=gray, label=" + quote(edge.key) + "]"
+		}
+		lines = append(lines, line+";")
+	}
+	lines = append(lines, "}")
+	return
+}
+
+func (self *flowGraph) mermaid() (lines []string) {
+	quote := func(str string) string {
+		return "\"" + strings.ReplaceAll(str, "\"", "#quot;") + "\""
+	}
+
+	lines = append(lines, "flowchart TD")
+	var dump func(node *flowGraphNode, indent string)
+	dump = func(node *flowGraphNode, indent string) {
+		if node.nodes == nil {
+			label := quote(strings.Join(append([]string{node.name}, node.args...), "<br/>"))
+			lines = append(lines, indent+node.id+"["+label+"]")
+			return
+		}
+		label := quote(strings.Join(append([]string{node.name}, node.args...), " "))
+		lines = append(lines, indent+"subgraph "+node.id+" ["+label+"]")
+		for _, it := range node.nodes {
+			dump(it, indent+"    ")
+		}
+		lines = append(lines, indent+"end")
+	}
+	for _, node := range self.root.nodes {
+		dump(node, "    ")
+	}
+	for _, edge := range self.edges {
+		if len(edge.key) == 0 {
+			lines = append(lines, "    "+edge.from+" --> "+edge.to)
+		} else {
+			label := edge.key
+			if edge.maybe {
+				label += " (maybe)"
+			}
+			lines = append(lines, "    "+edge.from+" -.->|"+quote(label)+"| "+edge.to)
+		}
+	}
+	return
+}
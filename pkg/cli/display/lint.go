@@ -0,0 +1,98 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+const (
+	FlowLintError = "error"
+	FlowLintWarn  = "warn"
+	FlowLintInfo  = "info"
+)
+
+type FlowLintFinding struct {
+	Source   string
+	CmdPath  string
+	MetaFile string
+	Severity string
+	Msg      string
+}
+
+type FlowLintFindings []FlowLintFinding
+
+func (self *FlowLintFindings) Add(cic *core.Cmd, severity string, format string, a ...interface{}) {
+	*self = append(*self, FlowLintFinding{
+		cic.Source(),
+		cic.Owner().DisplayPath(),
+		cic.MetaFile(),
+		severity,
+		fmt.Sprintf(format, a...),
+	})
+}
+
+func (self FlowLintFindings) Count(severity string) (count int) {
+	for _, it := range self {
+		if it.Severity == severity {
+			count += 1
+		}
+	}
+	return
+}
+
+// Findings are grouped by repo (source), then by flow
+func DumpFlowLintFindings(
+	screen core.Screen,
+	env *core.Env,
+	findings FlowLintFindings,
+	flowCount int) {
+
+	var sources []string
+	bySource := map[string]FlowLintFindings{}
+	for _, it := range findings {
+		if _, ok := bySource[it.Source]; !ok {
+			sources = append(sources, it.Source)
+		}
+		bySource[it.Source] = append(bySource[it.Source], it)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		name := source
+		if len(name) == 0 {
+			name = "<builtin>"
+		}
+		screen.Print(fmt.Sprintf("[%s]\n", name))
+		flows := bySource[source]
+		sort.SliceStable(flows, func(i, j int) bool {
+			return flows[i].CmdPath < flows[j].CmdPath
+		})
+		lastPath := ""
+		for _, it := range flows {
+			if it.CmdPath != lastPath {
+				screen.Print(fmt.Sprintf("    [%s]\n", it.CmdPath))
+				if len(it.MetaFile) != 0 {
+					screen.Print(fmt.Sprintf("        '%s'\n", it.MetaFile))
+				}
+				lastPath = it.CmdPath
+			}
+			screen.Print(fmt.Sprintf("        - %s: %s\n", it.Severity, it.Msg))
+		}
+	}
+	if len(sources) != 0 {
+		screen.Print("\n")
+	}
+
+	errs := findings.Count(FlowLintError)
+	warns := findings.Count(FlowLintWarn)
+	infos := findings.Count(FlowLintInfo)
+	summary := fmt.Sprintf("%d flows checked: %d errors, %d warnings, %d infos.",
+		flowCount, errs, warns, infos)
+	if errs != 0 {
+		PrintErrTitle(screen, env, summary)
+	} else {
+		PrintTipTitle(screen, env, summary)
+	}
+}
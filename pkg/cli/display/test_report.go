@@ -0,0 +1,67 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+type FlowTestResult struct {
+	// The repo (source) of the test
+	Suite    string
+	Name     string
+	File     string
+	Failures []string
+	Output   string
+	Duration time.Duration
+}
+
+func (self FlowTestResult) Passed() bool {
+	return len(self.Failures) == 0
+}
+
+// One line for a passed test, the failed ones have the reasons below
+func PrintFlowTestResult(screen core.Screen, result FlowTestResult, printSuite bool) {
+	if printSuite {
+		name := result.Suite
+		if len(name) == 0 {
+			name = "<builtin>"
+		}
+		screen.Print(fmt.Sprintf("[%s]\n", name))
+	}
+	status := "PASS"
+	if !result.Passed() {
+		status = "FAIL"
+	}
+	screen.Print(fmt.Sprintf("    %s  %s (%.2fs)\n", status, result.Name, result.Duration.Seconds()))
+	for _, it := range result.Failures {
+		screen.Print(fmt.Sprintf("        - %s\n", it))
+	}
+}
+
+func DumpFlowTestSummary(
+	screen core.Screen,
+	env *core.Env,
+	results []FlowTestResult,
+	junitPath string) {
+
+	failed := 0
+	for _, it := range results {
+		if !it.Passed() {
+			failed += 1
+		}
+	}
+	lines := []interface{}{
+		fmt.Sprintf("%d tests: %d passed, %d failed.", len(results), len(results)-failed, failed),
+	}
+	if len(junitPath) != 0 {
+		lines = append(lines, "", "junit report is written to:", "", "    "+junitPath)
+	}
+	screen.Print("\n")
+	if failed != 0 {
+		PrintErrTitle(screen, env, lines...)
+	} else {
+		PrintTipTitle(screen, env, lines...)
+	}
+}
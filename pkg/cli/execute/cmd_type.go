@@ -27,6 +27,7 @@ func allowCheckEnvOpsFail(flow *core.ParsedCmds) bool {
 		builtin.DumpFlowSimple,
 		builtin.DumpFlowDepends,
 		builtin.DumpFlowSkeleton,
+		builtin.DumpFlowGraph,
 		builtin.DumpFlowEnvOpsCheckResult,
 	}
 	for _, allow := range allows {
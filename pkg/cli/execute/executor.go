@@ -4,24 +4,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/pingcap/ticat/pkg/cli/core"
 	"github.com/pingcap/ticat/pkg/cli/display"
+	meta "github.com/pingcap/ticat/pkg/proto/session_meta"
 	"github.com/pingcap/ticat/pkg/utils"
 )
 
 type ExecFunc func(cc *core.Cli, flow *core.ParsedCmds, env *core.Env) bool
 
 type Executor struct {
-	funcs           []ExecFunc
-	sessionFileName string
+	// Run before the top level flow
+	funcs []ExecFunc
+	// Run before each flow, including the sub flows of flow cmds
+	flowFuncs []ExecFunc
+	// Run after the top level flow succeeded, with the flow before executing
+	finishFuncs           []ExecFunc
+	sessionFileName       string
+	sessionStatusFileName string
 }
 
-func NewExecutor(sessionFileName string) *Executor {
+func NewExecutor(sessionFileName string, sessionStatusFileName string) *Executor {
 	return &Executor{
 		[]ExecFunc{
 			// TODO: functions: flowFlatten, mockModInject
@@ -29,10 +34,23 @@ func NewExecutor(sessionFileName string) *Executor {
 			verifyEnvOps,
 			verifyOsDepCmds,
 		},
+		nil,
+		nil,
 		sessionFileName,
+		sessionStatusFileName,
 	}
 }
 
+// The checkers from other packages, eg: repo trust checking
+func (self *Executor) AddFlowFuncs(funcs ...ExecFunc) {
+	self.flowFuncs = append(self.flowFuncs, funcs...)
+}
+
+// The handlers from other packages, eg: flow recording
+func (self *Executor) AddFinishFuncs(funcs ...ExecFunc) {
+	self.finishFuncs = append(self.finishFuncs, funcs...)
+}
+
 func (self *Executor) Run(cc *core.Cli, bootstrap string, input ...string) bool {
 	overWriteBootstrap := cc.GlobalEnv.Get("sys.bootstrap").Raw
 	if len(overWriteBootstrap) != 0 {
@@ -41,7 +59,14 @@ func (self *Executor) Run(cc *core.Cli, bootstrap string, input ...string) bool
 	if !self.execute(cc, true, false, bootstrap) {
 		return false
 	}
-	return self.execute(cc, false, false, input...)
+
+	// Record the result even if it panics, the error will be handled by the caller
+	succeeded := false
+	defer func() {
+		self.sessionFinish(cc, succeeded)
+	}()
+	succeeded = self.execute(cc, false, false, input...)
+	return succeeded
 }
 
 // Implement core.Executor
@@ -83,10 +108,25 @@ func (self *Executor) execute(cc *core.Cli, bootstrap bool, innerCall bool, inpu
 		}
 	}
 
-	if !innerCall && !bootstrap && !self.sessionInit(cc, flow, env) {
+	if !bootstrap {
+		for _, function := range self.flowFuncs {
+			if !function(cc, flow, env) {
+				return false
+			}
+		}
+	}
+
+	if !innerCall && !bootstrap && !self.sessionInit(cc, flow, env, input) {
 		return false
 	}
 
+	// The flow could be modified when executing, so keep a copy for the finish funcs
+	var origin core.ParsedCmds
+	if !innerCall && !bootstrap {
+		origin = *flow
+		origin.Cmds = append([]core.ParsedCmd(nil), flow.Cmds...)
+	}
+
 	if !bootstrap {
 		env.PlusInt("sys.stack-depth", 1)
 	}
@@ -96,6 +136,13 @@ func (self *Executor) execute(cc *core.Cli, bootstrap bool, innerCall bool, inpu
 	if !bootstrap {
 		env.PlusInt("sys.stack-depth", -1)
 	}
+	if !innerCall && !bootstrap {
+		for _, function := range self.finishFuncs {
+			if !function(cc, &origin, env) {
+				return false
+			}
+		}
+	}
 	return true
 }
 
@@ -151,7 +198,17 @@ func (self *Executor) executeCmd(
 		} else {
 			// This cmdEnv is different, it included values from 'val2env' and 'arg2env'
 			cmdEnv, argv := cmd.GenEnvAndArgv(env, cc.Cmds.Strs.EnvValDelAllMark, cc.Cmds.Strs.PathSep)
-			newCurrCmdIdx, succeeded = last.Execute(argv, cc, cmdEnv, flow, currCmdIdx)
+			var mock core.CmdMock
+			mocked := false
+			if cc.TestHooks != nil {
+				cc.TestHooks.Trace(last.Cmd())
+				mock, mocked = cc.TestHooks.Mocks[last.Cmd()]
+			}
+			if mocked {
+				newCurrCmdIdx, succeeded = currCmdIdx, mock.Execute(cc, cmdEnv, last.Cmd())
+			} else {
+				newCurrCmdIdx, succeeded = last.Execute(argv, cc, cmdEnv, flow, currCmdIdx)
+			}
 		}
 	} else {
 		newCurrCmdIdx, succeeded = currCmdIdx, false
@@ -207,7 +264,12 @@ func reorderByPriority(
 	return true
 }
 
-func (self *Executor) sessionInit(cc *core.Cli, flow *core.ParsedCmds, env *core.Env) bool {
+func (self *Executor) sessionInit(
+	cc *core.Cli,
+	flow *core.ParsedCmds,
+	env *core.Env,
+	input []string) bool {
+
 	sessionDir := env.GetRaw("session")
 	sessionPath := filepath.Join(sessionDir, self.sessionFileName)
 	if len(sessionDir) != 0 {
@@ -221,50 +283,58 @@ func (self *Executor) sessionInit(cc *core.Cli, flow *core.ParsedCmds, env *core
 		return false
 	}
 
-	os.MkdirAll(sessionsRoot, os.ModePerm)
-	dirs, err := os.ReadDir(sessionsRoot)
-	if err != nil {
-		cc.Screen.Print(fmt.Sprintf("[sessionInit] can't read sessions' root path '%s'\n",
+	err := os.MkdirAll(sessionsRoot, os.ModePerm)
+	if err != nil && !os.IsExist(err) {
+		cc.Screen.Print(fmt.Sprintf("[sessionInit] can't create sessions' root path '%s'\n",
 			sessionsRoot))
 		return false
 	}
 
-	pid := fmt.Sprintf("%d", os.Getpid())
-
-	for _, dir := range dirs {
-		pid, err := strconv.Atoi(dir.Name())
-		if err != nil {
-			continue
+	// Old sessions are only removed by the retention policy, so they could be inspected after failures
+	if env.GetBool("sys.session.auto-gc") {
+		var policy meta.GcPolicy
+		policy, err = meta.ParseGcPolicy(env.GetRaw("sys.session.keep-duration"),
+			env.GetRaw("sys.session.keep-count"))
+		if err == nil {
+			_, err = meta.GcSessions(sessionsRoot, self.sessionStatusFileName, policy, time.Now())
 		}
-		err = syscall.Kill(pid, syscall.Signal(0))
-		if err != nil && err == syscall.ESRCH {
-			os.RemoveAll(filepath.Join(sessionsRoot, dir.Name()))
+		if err != nil {
+			cc.Screen.Print(fmt.Sprintf("[sessionInit] session gc failed: %v\n", err))
 		}
 	}
 
-	sessionDir = filepath.Join(sessionsRoot, pid)
-	err = os.MkdirAll(sessionDir, os.ModePerm)
+	status := meta.NewSessionStatus(sessionsRoot, os.Getpid(), time.Now(), strings.Join(input, " "))
+	err = os.MkdirAll(status.Dir, os.ModePerm)
 	if err != nil && !os.IsExist(err) {
 		cc.Screen.Print(fmt.Sprintf("[sessionInit] can't create session dir '%s'\n",
-			sessionDir))
+			status.Dir))
 		return false
 	}
+	meta.SaveSessionStatus(status, self.sessionStatusFileName)
 
-	env.GetLayer(core.EnvLayerSession).Set("session", sessionDir)
+	env.GetLayer(core.EnvLayerSession).Set("session", status.Dir)
 	return true
 }
 
-// TODO: clean ti
-// Seems not very useful, no user now.
-func (self *Executor) sessionFinish(cc *core.Cli, flow *core.ParsedCmds, env *core.Env) bool {
+// Only the sessions created (or attached) by this process are updated
+func (self *Executor) sessionFinish(cc *core.Cli, succeeded bool) {
+	env := cc.GlobalEnv.GetLayer(core.EnvLayerSession)
 	sessionDir := env.GetRaw("session")
 	if len(sessionDir) == 0 {
-		return true
+		return
 	}
-	kvSep := env.GetRaw("strs.proto-sep")
-	path := filepath.Join(sessionDir, self.sessionFileName)
-	core.SaveEnvToFile(env, path, kvSep)
-	return true
+	status := meta.LoadSessionStatus(sessionDir, self.sessionStatusFileName)
+	if status.Pid != os.Getpid() {
+		return
+	}
+	status.FinishTs = time.Now()
+	if succeeded {
+		status.Status = meta.StatusSucceeded
+	} else {
+		status.Status = meta.StatusFailed
+	}
+	meta.SaveSessionStatus(status, self.sessionStatusFileName)
+	core.SaveEnvToFile(env, filepath.Join(sessionDir, self.sessionFileName), cc.Cmds.Strs.ProtoSep)
 }
 
 func verifyEnvOps(cc *core.Cli, flow *core.ParsedCmds, env *core.Env) bool {
@@ -318,7 +388,7 @@ func useCmdsAbbrs(abbrs *core.EnvAbbrs, cmds *core.CmdTree) {
 }
 
 func useEnvAbbrs(abbrs *core.EnvAbbrs, env *core.Env, sep string) {
-	for k, _ := range env.Flatten(true, nil, true) {
+	for k, _ := range env.FlattenShared(true, true) {
 		curr := abbrs
 		for _, seg := range strings.Split(k, sep) {
 			curr = curr.GetOrAddSub(seg)
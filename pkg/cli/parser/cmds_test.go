@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+func newTestParser() *Parser {
+	seqParser := NewSequenceParser(":", []string{"http", "HTTP"}, []string{"/"})
+	envParser := NewEnvParser(Brackets{"{", "}"}, "\t\n\r ", "=", ".")
+	cmdParser := NewCmdParser(envParser, ".", "./", "\t\n\r ", "<root>")
+	return NewParser(seqParser, cmdParser)
+}
+
+func TestMountedFlowParser(t *testing.T) {
+	dummy := func(argv core.ArgVals, cc *core.Cli, env *core.Env, cmd core.ParsedCmd) bool {
+		return true
+	}
+
+	tree := newCmdTree()
+	tree.AddSub("bench").AddSub("run").RegCmd(dummy, "root")
+	tree.AddSub("echo").RegCmd(dummy, "root only")
+	tree.AddSub("dbg").RegCmd(dummy, "root, shadowed by a dir in the repo")
+
+	repo := tree.AddSub("vendor").AddSub("foo")
+	repo.AddSub("bench").AddSub("run").RegCmd(dummy, "repo")
+	repo.AddSub("only").RegCmd(dummy, "repo only")
+	repo.AddSub("dbg").AddSub("x").RegCmd(dummy, "repo")
+	flow := repo.AddSub("flow").RegFlowCmd([]string{"bench.run"}, "").SetMountPath([]string{"vendor", "foo"})
+	rootFlow := tree.AddSub("flow").RegFlowCmd([]string{"bench.run"}, "")
+
+	cc := &core.Cli{Parser: newTestParser()}
+
+	test := func(cmd *core.Cmd, input []string, expected ...string) {
+		parsed := cmd.FlowParser(cc).Parse(tree, nil, input...)
+		var paths []string
+		for _, it := range parsed.Cmds {
+			if it.ParseResult.Error != nil {
+				t.Fatalf("parse %v failed: %v", input, it.ParseResult.Error)
+			}
+			if last := it.LastCmdNode(); last != nil {
+				paths = append(paths, strings.Join(last.Path(), "."))
+			}
+		}
+		if strings.Join(paths, ",") != strings.Join(expected, ",") {
+			t.Fatalf("parse %v: %v != %v", input, paths, expected)
+		}
+	}
+
+	// The cmds of the mounted repo are looked up first
+	test(flow, []string{"bench.run"}, "vendor.foo.bench.run")
+	test(flow, []string{"only"}, "vendor.foo.only")
+	test(flow, []string{"dbg.x"}, "vendor.foo.dbg.x")
+
+	// Then the root
+	test(flow, []string{"echo"}, "echo")
+	test(flow, []string{"vendor.foo.only"}, "vendor.foo.only")
+	test(flow, []string{"dbg"}, "dbg")
+
+	test(flow, []string{"bench.run", ":", "echo", ":", "only"}, "vendor.foo.bench.run", "echo", "vendor.foo.only")
+	test(flow, []string{"{a=1}", "echo", ":", "bench.run"}, "echo", "vendor.foo.bench.run")
+
+	// Not mounted
+	test(rootFlow, []string{"bench.run"}, "bench.run")
+}
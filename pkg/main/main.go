@@ -24,6 +24,7 @@ func main() {
 	defEnv.Set("strs.cmd-builtin-display-name", CmdBuiltinDisplayName)
 	defEnv.Set("strs.meta-ext", MetaExt)
 	defEnv.Set("strs.flow-ext", FlowExt)
+	defEnv.Set("strs.flow-test-ext", FlowTestExt)
 	defEnv.Set("strs.abbrs-sep", AbbrsSep)
 	defEnv.Set("strs.seq-sep", SequenceSep)
 	defEnv.Set("strs.cmd-path-sep", CmdPathSep)
@@ -36,8 +37,14 @@ func main() {
 	defEnv.Set("strs.env-bracket-right", EnvBracketRight)
 	defEnv.Set("strs.env-file-name", EnvFileName)
 	defEnv.Set("strs.session-env-file", SessionEnvFileName)
+	defEnv.Set("strs.session-status-file", SessionStatusFileName)
+	defEnv.Set("strs.flow-record-file", FlowRecordFileName)
 	defEnv.Set("strs.hub-file-name", HubFileName)
 	defEnv.Set("strs.repos-file-name", ReposFileName)
+	defEnv.Set("strs.hub-lock-file-name", HubLockFileName)
+	defEnv.Set("strs.hub-changes-file-name", HubChangesFileName)
+	defEnv.Set("strs.hub-index-file-name", HubIndexFileName)
+	defEnv.Set("strs.hub-trust-file-name", HubTrustFileName)
 	defEnv.Set("strs.mods-repo-ext", ModsRepoExt)
 	defEnv.Set("strs.proto-sep", ProtoSep)
 	defEnv.Set("strs.tag-out-of-the-box", TagOutOfTheBox)
@@ -45,6 +52,7 @@ func main() {
 	defEnv.Set("strs.tag-self-test", TagSelfTest)
 	defEnv.Set("strs.flow-template-bracket-left", FlowTemplateBracketLeft)
 	defEnv.Set("strs.flow-template-bracket-right", FlowTemplateBracketRight)
+	defEnv.Set("strs.flow-template-default-sep", FlowTemplateDefaultSep)
 
 	// The available cmds are organized in a tree, will grow bigger after running bootstrap
 	tree := core.NewCmdTree(&core.CmdTreeStrs{
@@ -60,6 +68,7 @@ func main() {
 		ProtoSep,
 		FlowTemplateBracketLeft,
 		FlowTemplateBracketRight,
+		FlowTemplateDefaultSep,
 	})
 	builtin.RegisterCmds(tree)
 
@@ -98,6 +107,7 @@ func main() {
 		B.E.L.L:
 		B.M.L.F:
 		B.M.L.H:
+		B.M.C:
 		B.D.L.P:
 	`
 
@@ -113,7 +123,9 @@ func main() {
 	}()
 
 	// Main process
-	executor := execute.NewExecutor(SessionEnvFileName)
+	executor := execute.NewExecutor(SessionEnvFileName, SessionStatusFileName)
+	executor.AddFlowFuncs(builtin.NewRepoTrustChecker().CheckFlow)
+	executor.AddFinishFuncs(builtin.AppendFlowRecord)
 	cc.Executor = executor
 	succeeded := executor.Run(cc, bootstrap, os.Args[1:]...)
 
@@ -145,12 +157,20 @@ const (
 	ModsRepoExt              string = "." + SelfName
 	MetaExt                  string = "." + SelfName
 	FlowExt                  string = ".tiflow"
+	FlowTestExt              string = FlowExt + ".test"
 	HubFileName              string = "repos.hub"
 	ReposFileName            string = "hub.ticat"
+	HubLockFileName          string = "repos.lock"
+	HubChangesFileName       string = "repos.changes"
+	HubIndexFileName         string = "repos.index"
+	HubTrustFileName         string = "repos.trust"
 	SessionEnvFileName       string = "env"
+	SessionStatusFileName    string = "status"
+	FlowRecordFileName       string = "flow.recording"
 	TagOutOfTheBox           string = "@ready"
 	TagProvider              string = "@provider"
 	TagSelfTest              string = "@selftest"
 	FlowTemplateBracketLeft  string = "[["
 	FlowTemplateBracketRight string = "]]"
+	FlowTemplateDefaultSep   string = "|"
 )
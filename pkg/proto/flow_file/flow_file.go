@@ -1,9 +1,25 @@
 package flow_file
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/pingcap/ticat/pkg/proto/meta_file"
 )
 
+// A declared param of a flow, it's registered as an arg of the flow command
+type FlowParam struct {
+	// Name with abbrs, like 'threads|t'
+	Names   string
+	Default string
+	Help    string
+}
+
+func (self FlowParam) Name(abbrsSep string) string {
+	return strings.TrimSpace(strings.Split(self.Names, abbrsSep)[0])
+}
+
 func LoadFlowFile(path string) (flow []string, help string, abbrs string) {
 	meta := meta_file.NewMetaFile(path)
 	section := meta.GetGlobalSection()
@@ -13,7 +29,30 @@ func LoadFlowFile(path string) (flow []string, help string, abbrs string) {
 	return
 }
 
-func SaveFlowFile(path string, flow []string, help string, abbrs string) {
+func LoadFlowParams(path string, abbrsSep string) (params []FlowParam) {
+	meta := meta_file.NewMetaFile(path)
+	args := meta.GetSection("args")
+	if args == nil {
+		args = meta.GetSection("arg")
+	}
+	if args == nil {
+		return
+	}
+	helps := meta.GetSection("args.help")
+	if helps == nil {
+		helps = meta.GetSection("arg.help")
+	}
+	for _, names := range args.Keys() {
+		param := FlowParam{names, args.Get(names), ""}
+		if helps != nil {
+			param.Help = helps.Get(param.Name(abbrsSep))
+		}
+		params = append(params, param)
+	}
+	return
+}
+
+func SaveFlowFile(path string, flow []string, help string, abbrs string, params []FlowParam, abbrsSep string) {
 	meta := meta_file.CreateMetaFile(path)
 	section := meta.GetGlobalSection()
 	if len(help) != 0 {
@@ -25,5 +64,112 @@ func SaveFlowFile(path string, flow []string, help string, abbrs string) {
 	if len(flow) != 0 {
 		section.SetMultiLineVal("flow", flow)
 	}
+	if len(params) != 0 {
+		args := meta.NewOrGetSection("args")
+		for _, param := range params {
+			args.Set(param.Names, param.Default)
+		}
+	}
+	var helps *meta_file.Section
+	for _, param := range params {
+		if len(param.Help) == 0 {
+			continue
+		}
+		if helps == nil {
+			helps = meta.NewOrGetSection("args.help")
+		}
+		helps.Set(param.Name(abbrsSep), param.Help)
+	}
+	meta.Save()
+}
+
+// Update a flow file in place, the comments and the format of the unchanged parts are kept
+func UpdateFlowFile(path string, flow []string, help string, abbrs string, params []FlowParam, abbrsSep string) {
+	meta, err := meta_file.NewMetaFileEx(path)
+	if err != nil && !os.IsNotExist(err) {
+		panic(fmt.Errorf("[UpdateFlowFile] read flow file '%s' failed: %v", path, err))
+	}
+	section := meta.GetGlobalSection()
+	setOrDelete := func(section *meta_file.Section, key string, val string) {
+		if len(val) != 0 {
+			section.Set(key, val)
+		} else {
+			section.Delete(key)
+		}
+	}
+	abbrsKey := "abbrs"
+	if len(section.Get("abbrs")) == 0 && len(section.Get("abbr")) != 0 {
+		abbrsKey = "abbr"
+	}
+	setOrDelete(section, "help", help)
+	setOrDelete(section, abbrsKey, abbrs)
+	if len(flow) != 0 {
+		section.SetMultiLineVal("flow", flow)
+	} else {
+		section.Delete("flow")
+	}
+
+	argsName, helpsName := "args", "args.help"
+	if meta.GetSection("args") == nil && meta.GetSection("arg") != nil {
+		argsName, helpsName = "arg", "arg.help"
+	}
+	if len(params) == 0 {
+		meta.DeleteSection(argsName)
+	} else {
+		args := meta.NewOrGetSection(argsName)
+		names := map[string]bool{}
+		for _, param := range params {
+			args.Set(param.Names, param.Default)
+			names[param.Names] = true
+		}
+		for _, key := range append([]string{}, args.Keys()...) {
+			if !names[key] {
+				args.Delete(key)
+			}
+		}
+	}
+
+	helps := meta.GetSection(helpsName)
+	helpNames := map[string]bool{}
+	for _, param := range params {
+		if len(param.Help) == 0 {
+			continue
+		}
+		if helps == nil {
+			helps = meta.NewOrGetSection(helpsName)
+		}
+		name := param.Name(abbrsSep)
+		helps.Set(name, param.Help)
+		helpNames[name] = true
+	}
+	if helps != nil {
+		for _, key := range append([]string{}, helps.Keys()...) {
+			if !helpNames[key] {
+				helps.Delete(key)
+			}
+		}
+		if len(helps.Keys()) == 0 {
+			meta.DeleteSection(helpsName)
+		}
+	}
+	meta.Save()
+}
+
+// The draft of a recording flow, each line of the flow is a recorded command sequence
+func LoadFlowRecord(path string) (cmdPath string, flow []string) {
+	meta := meta_file.NewMetaFile(path)
+	section := meta.GetGlobalSection()
+	cmdPath = section.Get("cmd-path")
+	flow = section.GetMultiLineVal("flow", false)
+	return
+}
+
+func SaveFlowRecord(path string, cmdPath string, flow []string) {
+	meta := meta_file.CreateMetaFile(path)
+	section := meta.GetGlobalSection()
+	section.Set("cmd-path", cmdPath)
+	if len(flow) != 0 {
+		section.SetMultiLineVal("flow", flow)
+	}
 	meta.Save()
 }
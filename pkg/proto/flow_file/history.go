@@ -0,0 +1,87 @@
+package flow_file
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An old version of a flow file, the history of a flow is a dir of these files:
+//
+//	<history-dir>/<ver>.<action><flow-ext>
+//
+// The action is what replaced (or removed) this version, eg: 'save', 'edit', 'help'
+type FlowVersion struct {
+	Ver    int
+	Action string
+	Time   time.Time
+	Path   string
+}
+
+// Sorted by version, from old to new
+func ListFlowVersions(dir string, flowExt string) (versions []FlowVersion) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, flowExt) {
+			continue
+		}
+		fields := strings.SplitN(strings.TrimSuffix(name, flowExt), ".", 2)
+		ver, err := strconv.Atoi(fields[0])
+		if err != nil || ver <= 0 {
+			continue
+		}
+		action := ""
+		if len(fields) > 1 {
+			action = fields[1]
+		}
+		versions = append(versions, FlowVersion{ver, action, file.ModTime(), filepath.Join(dir, name)})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Ver < versions[j].Ver
+	})
+	return
+}
+
+func GetFlowVersion(dir string, flowExt string, ver int) (FlowVersion, bool) {
+	for _, it := range ListFlowVersions(dir, flowExt) {
+		if it.Ver == ver {
+			return it, true
+		}
+	}
+	return FlowVersion{}, false
+}
+
+// Save the content as a new version, skip if it's the same as the latest one
+func ArchiveFlowContent(dir string, flowExt string, content []byte, action string) (FlowVersion, bool) {
+	versions := ListFlowVersions(dir, flowExt)
+	ver := 1
+	if len(versions) != 0 {
+		latest := versions[len(versions)-1]
+		old, err := ioutil.ReadFile(latest.Path)
+		if err == nil && bytes.Equal(old, content) {
+			return latest, false
+		}
+		ver = latest.Ver + 1
+	}
+
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		panic(fmt.Errorf("[ArchiveFlowContent] create history dir '%s' failed: %v", dir, err))
+	}
+	path := filepath.Join(dir, strconv.Itoa(ver)+"."+action+flowExt)
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[ArchiveFlowContent] write flow version file '%s' failed: %v", path, err))
+	}
+	return FlowVersion{ver, action, time.Now(), path}, true
+}
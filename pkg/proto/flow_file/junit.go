@@ -0,0 +1,61 @@
+package flow_file
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// The JUnit XML report of flow tests, could be read by most CI systems
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (self *JUnitTestSuites) Add(suite JUnitTestSuite) {
+	self.Suites = append(self.Suites, suite)
+	self.Tests += suite.Tests
+	self.Failures += suite.Failures
+}
+
+func JUnitDuration(dur time.Duration) string {
+	return fmt.Sprintf("%.3f", dur.Seconds())
+}
+
+func SaveJUnitReport(path string, report JUnitTestSuites) {
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		panic(fmt.Errorf("[SaveJUnitReport] marshal junit report failed: %v", err))
+	}
+	data = append([]byte(xml.Header), data...)
+	err = ioutil.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		panic(fmt.Errorf("[SaveJUnitReport] write junit report to '%s' failed: %v", path, err))
+	}
+}
@@ -0,0 +1,96 @@
+package flow_file
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/proto/meta_file"
+)
+
+// A test of flows, one test in one file:
+//
+//	help = deploy then check
+//	flow = deploy : check
+//	[env]
+//	cluster.port = 4000
+//	[mock.tiup.deploy]
+//	cluster.name = test
+//	[expect]
+//	succeeded = true
+//	cmds = tiup.deploy : check
+//	output = deployed\
+//	    check ok
+//	[expect.env]
+//	cluster.name = test
+type FlowTest struct {
+	Path string
+	Help string
+	Flow []string
+	Env  []KeyVal
+	// Mocked command path => the env key-values the mock writes
+	Mocks map[string][]KeyVal
+	// Expected results after the flow is executed
+	Succeeded bool
+	Cmds      []string
+	Output    []string
+	ExpectEnv []KeyVal
+}
+
+type KeyVal struct {
+	Key string
+	Val string
+}
+
+func LoadFlowTest(path string, seqSep string) (test FlowTest) {
+	meta := meta_file.NewMetaFile(path)
+	test.Path = path
+	global := meta.GetGlobalSection()
+	test.Help = global.Get("help")
+	test.Flow = global.GetMultiLineVal("flow", false)
+	test.Env = sectionKeyVals(meta.GetSection("env"))
+
+	test.Mocks = map[string][]KeyVal{}
+	mockPrefix := "mock."
+	for name, section := range meta.GetAll() {
+		if strings.HasPrefix(name, mockPrefix) && len(name) > len(mockPrefix) {
+			test.Mocks[name[len(mockPrefix):]] = sectionKeyVals(section)
+		}
+	}
+
+	test.Succeeded = true
+	expect := meta.GetSection("expect")
+	if expect != nil {
+		test.Succeeded = expect.Get("succeeded") != "false"
+		for _, cmd := range strings.Split(expect.Get("cmds"), seqSep) {
+			cmd = strings.TrimSpace(cmd)
+			if len(cmd) != 0 {
+				test.Cmds = append(test.Cmds, cmd)
+			}
+		}
+		for _, pattern := range expect.GetMultiLineVal("output", false) {
+			if len(pattern) != 0 {
+				test.Output = append(test.Output, pattern)
+			}
+		}
+	}
+	test.ExpectEnv = sectionKeyVals(meta.GetSection("expect.env"))
+	return
+}
+
+func (self FlowTest) MockedCmdPaths() (paths []string) {
+	for path, _ := range self.Mocks {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return
+}
+
+func sectionKeyVals(section *meta_file.Section) (kvs []KeyVal) {
+	if section == nil {
+		return
+	}
+	for _, key := range section.Keys() {
+		kvs = append(kvs, KeyVal{key, section.Get(key)})
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package flow_file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFlowTest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flow-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "deploy.tiflow.test")
+	content := `help = deploy and bench
+flow = deploy : bench threads=8
+
+[env]
+cluster.port = 4000
+
+[mock.deploy]
+cluster.addr = 127.0.0.1
+
+[mock.bench/run]
+
+[expect]
+succeeded = false
+cmds = deploy : bench.run
+output = \
+    ^deployed \
+    done$
+
+[expect.env]
+cluster.addr = 127.0.0.1
+`
+	ioutil.WriteFile(path, []byte(content), 0644)
+
+	test := LoadFlowTest(path, ":")
+	if test.Path != path || test.Help != "deploy and bench" ||
+		!reflect.DeepEqual(test.Flow, []string{"deploy : bench threads=8"}) {
+		t.Fatalf("wrong test: %v", test)
+	}
+	if !reflect.DeepEqual(test.Env, []KeyVal{{"cluster.port", "4000"}}) {
+		t.Fatalf("wrong env: %v", test.Env)
+	}
+	if !reflect.DeepEqual(test.MockedCmdPaths(), []string{"bench/run", "deploy"}) ||
+		!reflect.DeepEqual(test.Mocks["deploy"], []KeyVal{{"cluster.addr", "127.0.0.1"}}) {
+		t.Fatalf("wrong mocks: %v", test.Mocks)
+	}
+	if test.Succeeded || !reflect.DeepEqual(test.Cmds, []string{"deploy", "bench.run"}) ||
+		!reflect.DeepEqual(test.Output, []string{"^deployed", "done$"}) {
+		t.Fatalf("wrong expects: %v %v %v", test.Succeeded, test.Cmds, test.Output)
+	}
+	if !reflect.DeepEqual(test.ExpectEnv, []KeyVal{{"cluster.addr", "127.0.0.1"}}) {
+		t.Fatalf("wrong expected env: %v", test.ExpectEnv)
+	}
+
+	ioutil.WriteFile(path, []byte("flow = dummy\n"), 0644)
+	test = LoadFlowTest(path, ":")
+	if !test.Succeeded || len(test.Cmds) != 0 || len(test.Mocks) != 0 {
+		t.Fatalf("a test succeeds by default: %v", test)
+	}
+}
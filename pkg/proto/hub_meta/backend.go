@@ -0,0 +1,209 @@
+package hub_meta
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	HubBackendGit     = "git"
+	HubBackendDir     = "dir"
+	HubBackendArchive = "archive"
+	HubBackendMirror  = "mirror"
+)
+
+// The way to fetch a repo to hub, selected by the address of the repo:
+//   - 'dir:<path>': copy a local dir, the content hash is used as the commit
+//   - 'mirror:<path>': use a read-only dir in place, the content hash is used as the commit
+//   - 'archive:<path-or-url>', or a path or url ends with '.tar.gz|.tgz|.tar|.zip': download and extract
+//   - others: git repo
+type HubBackend interface {
+	Name() string
+	Match(addr string) bool
+	NormalizeAddr(addr string) string
+	// The local path of the repo, usually a sub dir of hub
+	RepoPath(hubPath string, addr string) string
+	// Clone or update the repo to the latest of the ref, the outputs are written to 'out'
+	Update(repoPath string, addr string, ref string, out io.Writer) error
+	// Move the repo to the locked commit, return error if it can't be reached
+	Checkout(repoPath string, addr string, ref string, commit string, out io.Writer) error
+	Commit(repoPath string) (string, error)
+	// A read-only repo is used in place, it's never modified or removed by hub
+	ReadOnly() bool
+}
+
+// Git is the fallback, so it's the last one
+var hubBackends = []HubBackend{
+	dirBackend{},
+	mirrorBackend{},
+	archiveBackend{},
+	gitBackend{},
+}
+
+func GetHubBackend(addr string) HubBackend {
+	for _, backend := range hubBackends {
+		if backend.Match(addr) {
+			return backend
+		}
+	}
+	return gitBackend{}
+}
+
+func (self RepoInfo) Backend() HubBackend {
+	return GetHubBackend(self.Addr)
+}
+
+func NormalizeRepoAddr(addr string) string {
+	return GetHubBackend(addr).NormalizeAddr(addr)
+}
+
+func GetRepoPath(hubPath string, addr string) string {
+	return GetHubBackend(addr).RepoPath(hubPath, addr)
+}
+
+func GetRepoCommit(addr string, repoPath string) (string, error) {
+	return GetHubBackend(addr).Commit(repoPath)
+}
+
+// Refs are only meaningful to git
+func checkNoRef(backend HubBackend, ref string) error {
+	if len(ref) != 0 {
+		return fmt.Errorf("backend '%s' doesn't support ref '%s'", backend.Name(), ref)
+	}
+	return nil
+}
+
+type gitBackend struct{}
+
+func (self gitBackend) Name() string {
+	return HubBackendGit
+}
+
+func (self gitBackend) Match(addr string) bool {
+	return true
+}
+
+func (self gitBackend) NormalizeAddr(addr string) string {
+	return NormalizeGitAddr(addr)
+}
+
+func (self gitBackend) RepoPath(hubPath string, addr string) string {
+	return filepath.Join(hubPath, filepath.Base(addr))
+}
+
+func (self gitBackend) Update(repoPath string, addr string, ref string, out io.Writer) error {
+	git := gitRunner{out}
+	stat, err := os.Stat(repoPath)
+	if err == nil {
+		if !stat.IsDir() {
+			return fmt.Errorf("repo path '%v' exists but is not dir", repoPath)
+		}
+		if len(ref) == 0 {
+			// A repo pinned to a tag or commit before is in detached HEAD, back to the default branch
+			if _, err := gitOutput(repoPath, "symbolic-ref", "-q", "HEAD"); err != nil {
+				err = git.checkoutDefaultBranch(repoPath)
+				if err != nil {
+					return err
+				}
+			}
+			return git.run(repoPath, "pull", "--recurse-submodules")
+		}
+		err = git.run(repoPath, "fetch", "--tags", "origin")
+		if err != nil {
+			return err
+		}
+		return git.checkout(repoPath, ref)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("access repo path '%v' failed: %v", repoPath, err)
+	}
+	err = git.run("", "clone", "--recursive", NormalizeGitAddr(addr), repoPath)
+	if err == nil && len(ref) != 0 {
+		err = git.checkout(repoPath, ref)
+	}
+	return err
+}
+
+func (self gitBackend) Checkout(repoPath string, addr string, ref string, commit string, out io.Writer) error {
+	git := gitRunner{out}
+	stat, err := os.Stat(repoPath)
+	if err == nil {
+		if !stat.IsDir() {
+			return fmt.Errorf("repo path '%v' exists but is not dir", repoPath)
+		}
+		if current, _ := self.Commit(repoPath); current != commit {
+			err = git.run(repoPath, "fetch", "--tags", "origin")
+		}
+	} else if os.IsNotExist(err) {
+		err = git.run("", "clone", "--recursive", NormalizeGitAddr(addr), repoPath)
+	} else {
+		err = fmt.Errorf("access repo path '%v' failed: %v", repoPath, err)
+	}
+	if err != nil {
+		return err
+	}
+	err = git.run(repoPath, "checkout", "--quiet", commit)
+	if err != nil {
+		return err
+	}
+	return git.run(repoPath, "submodule", "update", "--init", "--recursive")
+}
+
+func (self gitBackend) Commit(repoPath string) (string, error) {
+	return gitOutput(repoPath, "rev-parse", "HEAD")
+}
+
+func (self gitBackend) ReadOnly() bool {
+	return false
+}
+
+// Run git commands with outputs redirected, the outputs of parallel running repos can't be mixed
+type gitRunner struct {
+	out io.Writer
+}
+
+func (self gitRunner) run(dir string, args ...string) error {
+	c := exec.Command("git", args...)
+	if len(dir) != 0 {
+		c.Dir = dir
+	}
+	c.Stdout = self.out
+	c.Stderr = self.out
+	err := c.Run()
+	if err != nil {
+		return fmt.Errorf("run '%s' failed: %v",
+			strings.Join(append([]string{"git"}, args...), " "), err)
+	}
+	return nil
+}
+
+// A branch will be pulled to the latest, a tag or commit will be in detached HEAD
+func (self gitRunner) checkout(repoPath string, ref string) error {
+	err := self.run(repoPath, "checkout", "--quiet", ref)
+	if err != nil {
+		return err
+	}
+	if _, err := gitOutput(repoPath, "symbolic-ref", "-q", "HEAD"); err == nil {
+		err = self.run(repoPath, "pull", "--recurse-submodules")
+		if err != nil {
+			return err
+		}
+	}
+	return self.run(repoPath, "submodule", "update", "--init", "--recursive")
+}
+
+// The default branch is the HEAD of the remote, it's refreshed because it could be changed
+func (self gitRunner) checkoutDefaultBranch(repoPath string) error {
+	err := self.run(repoPath, "remote", "set-head", "origin", "--auto")
+	if err != nil {
+		return err
+	}
+	head, err := gitOutput(repoPath, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return fmt.Errorf("get the default branch of repo '%s' failed: %v", repoPath, err)
+	}
+	return self.run(repoPath, "checkout", "--quiet", strings.TrimPrefix(head, "origin/"))
+}
@@ -0,0 +1,333 @@
+package hub_meta
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var archiveExts = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+const archiveDownloadTimeout = 10 * time.Minute
+
+// Download (or read from local path) and extract a tar, tar.gz or zip archive to hub,
+// the content hash is used as the commit
+type archiveBackend struct{}
+
+func (self archiveBackend) Name() string {
+	return HubBackendArchive
+}
+
+func (self archiveBackend) Match(addr string) bool {
+	if strings.HasPrefix(addr, HubBackendArchive+":") {
+		return true
+	}
+	return len(archiveExt(addr)) != 0
+}
+
+func (self archiveBackend) NormalizeAddr(addr string) string {
+	location := archiveLocation(addr)
+	if !isHttpAddr(location) {
+		location = absPath(location)
+	}
+	if strings.HasPrefix(addr, HubBackendArchive+":") {
+		return HubBackendArchive + ":" + location
+	}
+	return location
+}
+
+func (self archiveBackend) RepoPath(hubPath string, addr string) string {
+	location := archiveLocation(addr)
+	if i := strings.Index(location, "?"); i >= 0 && isHttpAddr(location) {
+		location = location[:i]
+	}
+	name := filepath.Base(location)
+	return filepath.Join(hubPath, name[:len(name)-len(archiveExt(name))])
+}
+
+func (self archiveBackend) Update(repoPath string, addr string, ref string, out io.Writer) error {
+	if err := checkNoRef(self, ref); err != nil {
+		return err
+	}
+	return replaceByFetched(repoPath, "", func(toDir string) error {
+		return self.fetch(addr, toDir)
+	})
+}
+
+func (self archiveBackend) Checkout(repoPath string, addr string, ref string, commit string, out io.Writer) error {
+	return replaceByFetched(repoPath, commit, func(toDir string) error {
+		return self.fetch(addr, toDir)
+	})
+}
+
+func (self archiveBackend) Commit(repoPath string) (string, error) {
+	return HashDirContent(repoPath)
+}
+
+func (self archiveBackend) ReadOnly() bool {
+	return false
+}
+
+func (self archiveBackend) fetch(addr string, toDir string) error {
+	location := archiveLocation(addr)
+	path, err := readArchive(location, filepath.Dir(toDir))
+	if err != nil {
+		return err
+	}
+	if path != location {
+		defer os.Remove(path)
+	}
+	err = ExtractArchive(path, toDir)
+	if err != nil {
+		return fmt.Errorf("extract archive '%s' failed: %v", location, err)
+	}
+	return nil
+}
+
+// A local archive is used in place, a remote one is downloaded to a temp file in 'tmpDir'
+func readArchive(location string, tmpDir string) (string, error) {
+	if !isHttpAddr(location) {
+		return location, nil
+	}
+	client := http.Client{Timeout: archiveDownloadTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return "", fmt.Errorf("download archive '%s' failed: %v", location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download archive '%s' failed: %s", location, resp.Status)
+	}
+	file, err := ioutil.TempFile(tmpDir, "archive-")
+	if err != nil {
+		return "", fmt.Errorf("download archive '%s' failed: %v", location, err)
+	}
+	defer file.Close()
+	_, err = io.Copy(file, resp.Body)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("download archive '%s' failed: %v", location, err)
+	}
+	return file.Name(), nil
+}
+
+// The format is detected by content. If all files are in one top dir (like the archives from
+// git platforms), the content of that dir is extracted to 'toDir'
+func ExtractArchive(path string, toDir string) error {
+	tmpDir := toDir + ".extracting"
+	defer os.RemoveAll(tmpDir)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(file, magic)
+	magic = magic[:n]
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(magic, []byte("PK\x03\x04")) {
+		err = extractZip(file, stat.Size(), tmpDir)
+	} else if bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(file)
+		if err == nil {
+			err = extractTar(tar.NewReader(gz), tmpDir)
+		}
+	} else {
+		err = extractTar(tar.NewReader(file), tmpDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	root := tmpDir
+	entries, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(tmpDir, entries[0].Name())
+	}
+	return os.Rename(root, toDir)
+}
+
+func extractTar(tr *tar.Reader, toDir string) error {
+	os.MkdirAll(toDir, os.ModePerm)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := archiveEntryTarget(toDir, header.Name)
+		if err != nil {
+			return err
+		}
+		err = extractTarEntry(tr, header, toDir, target)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(reader io.ReaderAt, size int64, toDir string) error {
+	zr, err := zip.NewReader(reader, size)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(toDir, os.ModePerm)
+	for _, file := range zr.File {
+		target, err := archiveEntryTarget(toDir, file.Name)
+		if err != nil {
+			return err
+		}
+		err = extractZipEntry(file, toDir, target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipEntry(file *zip.File, toDir string, target string) error {
+	mode := file.Mode()
+	if mode.IsDir() {
+		return os.MkdirAll(target, mode.Perm()|0700)
+	}
+	os.MkdirAll(filepath.Dir(target), os.ModePerm)
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if mode&os.ModeSymlink != 0 {
+		link, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		return extractSymlink(toDir, target, string(link))
+	}
+	if !mode.IsRegular() {
+		return nil
+	}
+	to, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+	_, err = io.Copy(to, reader)
+	return err
+}
+
+// The target should be in 'toDir', and should not go through a symlink extracted before,
+// or the files outside could be overwritten
+func archiveEntryTarget(toDir string, name string) (string, error) {
+	target := filepath.Join(toDir, filepath.FromSlash(name))
+	if !isInDir(toDir, target) {
+		return "", fmt.Errorf("bad file path '%s' in archive", name)
+	}
+	rel, err := filepath.Rel(toDir, target)
+	if err != nil {
+		return "", err
+	}
+	curr := toDir
+	for _, seg := range strings.Split(rel, string(filepath.Separator)) {
+		curr = filepath.Join(curr, seg)
+		info, err := os.Lstat(curr)
+		if err != nil {
+			break
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("file path '%s' in archive goes through symlink '%s'", name, curr)
+		}
+	}
+	return target, nil
+}
+
+// Symlinks with absolute paths or pointing to outside of 'toDir' are not allowed
+func extractSymlink(toDir string, target string, link string) error {
+	if filepath.IsAbs(link) || !isSymlinkInDir(toDir, target, link) {
+		return fmt.Errorf("symlink '%s' -> '%s' in archive points to outside", target, link)
+	}
+	return os.Symlink(link, target)
+}
+
+// Resolve the link by the extracted files. A '..' after a not existed path is not allowed,
+// because that path could be a symlink extracted later
+func isSymlinkInDir(toDir string, target string, link string) bool {
+	root, err := filepath.EvalSymlinks(toDir)
+	if err != nil {
+		return false
+	}
+	curr, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return false
+	}
+	for _, seg := range strings.Split(filepath.ToSlash(link), "/") {
+		switch seg {
+		case "", ".":
+			continue
+		case "..":
+			if _, err := os.Lstat(curr); err != nil {
+				return false
+			}
+			curr = filepath.Dir(curr)
+		default:
+			curr = filepath.Join(curr, seg)
+			if resolved, err := filepath.EvalSymlinks(curr); err == nil {
+				curr = resolved
+			}
+		}
+		if !isInDir(root, curr) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInDir(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+func archiveLocation(addr string) string {
+	return strings.TrimPrefix(addr, HubBackendArchive+":")
+}
+
+func archiveExt(location string) string {
+	if i := strings.Index(location, "?"); i >= 0 && isHttpAddr(location) {
+		location = location[:i]
+	}
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(strings.ToLower(location), ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+func isHttpAddr(addr string) bool {
+	lower := strings.ToLower(addr)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
@@ -0,0 +1,262 @@
+package hub_meta
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Copy a local dir to hub, a snapshot of it
+type dirBackend struct{}
+
+func (self dirBackend) Name() string {
+	return HubBackendDir
+}
+
+func (self dirBackend) Match(addr string) bool {
+	return strings.HasPrefix(addr, HubBackendDir+":")
+}
+
+func (self dirBackend) NormalizeAddr(addr string) string {
+	return HubBackendDir + ":" + absPath(strings.TrimPrefix(addr, HubBackendDir+":"))
+}
+
+func (self dirBackend) RepoPath(hubPath string, addr string) string {
+	return filepath.Join(hubPath, filepath.Base(strings.TrimPrefix(addr, HubBackendDir+":")))
+}
+
+func (self dirBackend) Update(repoPath string, addr string, ref string, out io.Writer) error {
+	if err := checkNoRef(self, ref); err != nil {
+		return err
+	}
+	return replaceByFetched(repoPath, "", func(toDir string) error {
+		return self.fetch(addr, toDir)
+	})
+}
+
+func (self dirBackend) Checkout(repoPath string, addr string, ref string, commit string, out io.Writer) error {
+	return replaceByFetched(repoPath, commit, func(toDir string) error {
+		return self.fetch(addr, toDir)
+	})
+}
+
+func (self dirBackend) Commit(repoPath string) (string, error) {
+	return HashDirContent(repoPath)
+}
+
+func (self dirBackend) ReadOnly() bool {
+	return false
+}
+
+func (self dirBackend) fetch(addr string, toDir string) error {
+	src := absPath(strings.TrimPrefix(addr, HubBackendDir+":"))
+	stat, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("access dir '%s' failed: %v", src, err)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("'%s' is not dir", src)
+	}
+	return copyDir(src, toDir)
+}
+
+// Use a dir which is maintained by others (eg: a shared mirror) in place
+type mirrorBackend struct{}
+
+func (self mirrorBackend) Name() string {
+	return HubBackendMirror
+}
+
+func (self mirrorBackend) Match(addr string) bool {
+	return strings.HasPrefix(addr, HubBackendMirror+":")
+}
+
+func (self mirrorBackend) NormalizeAddr(addr string) string {
+	return HubBackendMirror + ":" + absPath(strings.TrimPrefix(addr, HubBackendMirror+":"))
+}
+
+func (self mirrorBackend) RepoPath(hubPath string, addr string) string {
+	return absPath(strings.TrimPrefix(addr, HubBackendMirror+":"))
+}
+
+func (self mirrorBackend) Update(repoPath string, addr string, ref string, out io.Writer) error {
+	if err := checkNoRef(self, ref); err != nil {
+		return err
+	}
+	stat, err := os.Stat(repoPath)
+	if err != nil {
+		return fmt.Errorf("access mirror dir '%s' failed: %v", repoPath, err)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("mirror '%s' is not dir", repoPath)
+	}
+	return nil
+}
+
+func (self mirrorBackend) Checkout(repoPath string, addr string, ref string, commit string, out io.Writer) error {
+	err := self.Update(repoPath, addr, "", out)
+	if err != nil {
+		return err
+	}
+	hash, err := self.Commit(repoPath)
+	if err != nil {
+		return err
+	}
+	if hash != commit {
+		return fmt.Errorf("mirror '%s' is changed, content hash '%s' != locked '%s'", repoPath, hash, commit)
+	}
+	return nil
+}
+
+func (self mirrorBackend) Commit(repoPath string) (string, error) {
+	return HashDirContent(repoPath)
+}
+
+func (self mirrorBackend) ReadOnly() bool {
+	return true
+}
+
+// Fetch the whole content to a temp dir, then replace the repo dir with it.
+// If the commit is not empty, the fetched content hash must be the same as it
+func replaceByFetched(repoPath string, commit string, fetch func(toDir string) error) error {
+	tmpDir, err := ioutil.TempDir(filepath.Dir(repoPath), ".fetch-")
+	if err != nil {
+		return fmt.Errorf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	newPath := filepath.Join(tmpDir, "new")
+	err = fetch(newPath)
+	if err != nil {
+		return err
+	}
+	if len(commit) != 0 {
+		hash, err := HashDirContent(newPath)
+		if err != nil {
+			return err
+		}
+		if hash != commit {
+			return fmt.Errorf("content is changed, content hash '%s' != locked '%s'", hash, commit)
+		}
+	}
+
+	oldPath := ""
+	if _, err := os.Stat(repoPath); err == nil {
+		oldPath = filepath.Join(tmpDir, "old")
+		if err = os.Rename(repoPath, oldPath); err != nil {
+			return fmt.Errorf("move repo '%s' failed: %v", repoPath, err)
+		}
+	}
+	if err = os.Rename(newPath, repoPath); err != nil {
+		if len(oldPath) != 0 {
+			os.Rename(oldPath, repoPath)
+		}
+		return fmt.Errorf("move repo to '%s' failed: %v", repoPath, err)
+	}
+	return nil
+}
+
+// The hash of the file paths, file modes and contents in a dir, '.git' dirs are skipped
+func HashDirContent(dir string) (string, error) {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !stat.IsDir() {
+		return "", fmt.Errorf("'%s' is not dir", dir)
+	}
+	hash := sha1.New()
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00", filepath.ToSlash(rel))
+		if info.IsDir() {
+			return nil
+		}
+		fmt.Fprintf(hash, "%o\x00", info.Mode())
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%s\x00", link)
+		} else if info.Mode().IsRegular() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err = io.Copy(hash, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash dir '%s' failed: %v", dir, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Copy a dir with file modes and symlinks kept, '.git' dirs are skipped
+func copyDir(src string, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		from, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer from.Close()
+		to, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer to.Close()
+		_, err = io.Copy(to, from)
+		return err
+	})
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,251 @@
+package hub_meta
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetHubBackend(t *testing.T) {
+	test := func(addr string, expected string) {
+		if name := GetHubBackend(addr).Name(); name != expected {
+			t.Fatalf("backend of '%s': '%s' != '%s'", addr, name, expected)
+		}
+	}
+
+	test("innerr/tidb.ticat", HubBackendGit)
+	test("git@github.com:innerr/tidb.ticat", HubBackendGit)
+	test("https://github.com/innerr/tidb.ticat", HubBackendGit)
+	test("dir:/data/tidb.ticat", HubBackendDir)
+	test("mirror:/mnt/mirror/tidb.ticat", HubBackendMirror)
+	test("/data/tidb.ticat.tar.gz", HubBackendArchive)
+	test("http://127.0.0.1:8000/tidb.ticat.zip?token=x", HubBackendArchive)
+	test("archive:http://127.0.0.1:8000/download/tidb.ticat", HubBackendArchive)
+
+	if path := GetRepoPath("/hub", "http://127.0.0.1:8000/tidb.ticat.tgz?token=x"); path != "/hub/tidb.ticat" {
+		t.Fatalf("wrong repo path of archive: %s", path)
+	}
+	if path := GetRepoPath("/hub", "mirror:/mnt/mirror/tidb.ticat"); path != "/mnt/mirror/tidb.ticat" {
+		t.Fatalf("mirror should be used in place: %s", path)
+	}
+	if addr := NormalizeRepoAddr("innerr/tidb.ticat"); addr != "git@github.com:innerr/tidb.ticat" {
+		t.Fatalf("wrong normalized git addr: %s", addr)
+	}
+}
+
+func TestDirAndArchiveBackend(t *testing.T) {
+	root, err := ioutil.TempDir("", "backend-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	src := filepath.Join(root, "src", "x.ticat")
+	os.MkdirAll(filepath.Join(src, ".git"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(src, "hub.ticat"), []byte("help = x\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("x\n"), 0644)
+
+	hubPath := filepath.Join(root, "hub")
+	os.MkdirAll(hubPath, os.ModePerm)
+	addr := "dir:" + src
+	backend := GetHubBackend(addr)
+	repoPath := backend.RepoPath(hubPath, addr)
+	if err = backend.Update(repoPath, addr, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(repoPath, ".git")); !os.IsNotExist(err) {
+		t.Fatalf("'.git' should not be copied")
+	}
+	commit, _ := backend.Commit(repoPath)
+	srcCommit, _ := HashDirContent(src)
+	if len(commit) == 0 || commit != srcCommit {
+		t.Fatalf("content hash should be the same as the source: '%s' != '%s'", commit, srcCommit)
+	}
+	if err = backend.Update(repoPath, addr, "v1", nil); err == nil {
+		t.Fatalf("ref should not be supported by dir backend")
+	}
+
+	ioutil.WriteFile(filepath.Join(src, "hub.ticat"), []byte("help = y\n"), 0644)
+	if err = backend.Checkout(repoPath, addr, "", commit, nil); err == nil {
+		t.Fatalf("checkout should fail if the content is changed")
+	}
+	if now, _ := backend.Commit(repoPath); now != commit {
+		t.Fatalf("repo should be untouched if checkout failed")
+	}
+
+	var data bytes.Buffer
+	zw := zip.NewWriter(&data)
+	w, _ := zw.Create("x.ticat-main/hub.ticat")
+	w.Write([]byte("help = z\n"))
+	zw.Close()
+	archivePath := filepath.Join(root, "x.ticat.zip")
+	ioutil.WriteFile(archivePath, data.Bytes(), 0644)
+
+	backend = GetHubBackend(archivePath)
+	repoPath = backend.RepoPath(hubPath, archivePath)
+	if err = backend.Update(repoPath, archivePath, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(repoPath, "hub.ticat"))
+	if err != nil || string(content) != "help = z\n" {
+		t.Fatalf("the top dir in archive should be stripped: %v %s", err, content)
+	}
+}
+
+func TestGitUpdateFromDetachedHead(t *testing.T) {
+	root, err := ioutil.TempDir("", "backend-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	git := func(dir string, args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@test"}, args...)
+		out, err := gitOutput(dir, args...)
+		if err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+		return out
+	}
+	commit := func(dir string, content string) string {
+		ioutil.WriteFile(filepath.Join(dir, "hub.ticat"), []byte(content), 0644)
+		git(dir, "add", "-A")
+		git(dir, "commit", "-q", "-m", content)
+		return git(dir, "rev-parse", "HEAD")
+	}
+
+	origin := filepath.Join(root, "origin")
+	os.MkdirAll(origin, os.ModePerm)
+	git(origin, "init", "-q", "-b", "main")
+	commit(origin, "help = v1\n")
+	git(origin, "tag", "v1")
+
+	// Pinned to a tag before, then re-added without a ref
+	repoPath := filepath.Join(root, "hub", "x.ticat")
+	git("", "clone", "-q", origin, repoPath)
+	git(repoPath, "checkout", "-q", "v1")
+	latest := commit(origin, "help = v2\n")
+
+	if err = (gitBackend{}).Update(repoPath, "x.ticat", "", ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if branch := git(repoPath, "symbolic-ref", "--short", "HEAD"); branch != "main" {
+		t.Fatalf("repo should be back to the default branch: %s", branch)
+	}
+	if now := git(repoPath, "rev-parse", "HEAD"); now != latest {
+		t.Fatalf("repo should be updated to the latest commit: %s != %s", now, latest)
+	}
+}
+
+func TestArchiveBackendHttp(t *testing.T) {
+	root, err := ioutil.TempDir("", "backend-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	var data bytes.Buffer
+	zw := zip.NewWriter(&data)
+	w, _ := zw.Create("x.ticat-main/hub.ticat")
+	w.Write([]byte("help = z\n"))
+	zw.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x.ticat.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data.Bytes())
+	}))
+	defer server.Close()
+
+	hubPath := filepath.Join(root, "hub")
+	os.MkdirAll(hubPath, os.ModePerm)
+	addr := server.URL + "/x.ticat.zip"
+	backend := GetHubBackend(addr)
+	repoPath := backend.RepoPath(hubPath, addr)
+	if err = backend.Update(repoPath, addr, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(repoPath, "hub.ticat"))
+	if err != nil || string(content) != "help = z\n" {
+		t.Fatalf("wrong downloaded content: %v %s", err, content)
+	}
+	if err = backend.Update(repoPath, server.URL+"/y.ticat.zip", "", nil); err == nil {
+		t.Fatalf("download should fail if not found")
+	}
+	entries, _ := ioutil.ReadDir(hubPath)
+	if len(entries) != 1 {
+		t.Fatalf("temp files should be removed: %v", entries)
+	}
+}
+
+func TestExtractArchiveSymlinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "backend-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	outside := filepath.Join(root, "outside")
+	ioutil.WriteFile(outside, []byte("x"), 0644)
+
+	type entry struct {
+		name    string
+		link    string
+		content string
+	}
+	i := 0
+	extract := func(entries ...entry) (string, error) {
+		var data bytes.Buffer
+		tw := tar.NewWriter(&data)
+		for _, it := range entries {
+			if len(it.link) != 0 {
+				tw.WriteHeader(&tar.Header{Name: it.name, Typeflag: tar.TypeSymlink, Linkname: it.link, Mode: 0777})
+			} else {
+				tw.WriteHeader(&tar.Header{Name: it.name, Typeflag: tar.TypeReg, Mode: 0644,
+					Size: int64(len(it.content))})
+				tw.Write([]byte(it.content))
+			}
+		}
+		tw.Close()
+		i += 1
+		path := filepath.Join(root, "archive.tar")
+		ioutil.WriteFile(path, data.Bytes(), 0644)
+		toDir := filepath.Join(root, "repo"+string(rune('0'+i)))
+		return toDir, ExtractArchive(path, toDir)
+	}
+	test := func(ok bool, entries ...entry) string {
+		toDir, err := extract(entries...)
+		if ok && err != nil {
+			t.Fatalf("extract %v failed: %v", entries, err)
+		}
+		if !ok && err == nil {
+			t.Fatalf("extract %v should fail", entries)
+		}
+		return toDir
+	}
+
+	toDir := test(true, entry{"a/x", "", "x"}, entry{"a/l", "x", ""}, entry{"b/l", "../a/x", ""})
+	content, err := ioutil.ReadFile(filepath.Join(toDir, "b", "l"))
+	if err != nil || string(content) != "x" {
+		t.Fatalf("symlink in archive should be kept: %v %s", err, content)
+	}
+
+	test(false, entry{"a/l", outside, ""})
+	test(false, entry{"a/l", "../../outside", ""})
+	test(false, entry{"a/l", "../../../outside", ""})
+	// The '..' after a symlink extracted later
+	test(false, entry{"a/x", "", "x"}, entry{"a/l", "s/../../outside", ""}, entry{"a/s", ".", ""})
+	// Write through an extracted symlink
+	test(false, entry{"a/x", "", "x"}, entry{"a/s", ".", ""}, entry{"a/s/y", "", "y"})
+	test(false, entry{"a/x", "", "x"}, entry{"a/s", "x", ""}, entry{"a/s", "", "y"})
+
+	if content, _ := ioutil.ReadFile(outside); string(content) != "x" {
+		t.Fatalf("file outside should not be changed: %s", content)
+	}
+}
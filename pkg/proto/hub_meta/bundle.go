@@ -0,0 +1,175 @@
+package hub_meta
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The dir of repos in a bundle, each repo is a sub dir of it
+const bundleReposDir = "repos"
+
+// Pack repos (with the '.git' dirs) and their entries of the repos info file to a tar.gz file.
+// The paths in the packed repos info file are relative to the bundle root
+func ExportBundle(path string, infos []RepoInfo, reposInfoFileName string, sep string) {
+	tmpDir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		panic(fmt.Errorf("[ExportBundle] create temp dir failed: %v", err))
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var packeds []RepoInfo
+	for _, info := range infos {
+		info.Path = filepath.Join(bundleReposDir, filepath.Base(info.Path))
+		packeds = append(packeds, info)
+	}
+	infoPath := filepath.Join(tmpDir, reposInfoFileName)
+	WriteReposInfoFile(infoPath, packeds, sep)
+
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[ExportBundle] open file '%s' failed: %v", tmp, err))
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	err = addFileToTar(tw, infoPath, reposInfoFileName)
+	for i := 0; err == nil && i < len(infos); i++ {
+		err = addDirToTar(tw, infos[i].Path, packeds[i].Path)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		os.Remove(tmp)
+		panic(fmt.Errorf("[ExportBundle] write bundle '%s' failed: %v", path, err))
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		panic(fmt.Errorf("[ExportBundle] rename file '%s' to '%s' failed: %v", tmp, path, err))
+	}
+}
+
+// Extract a bundle to a dir, return the packed repos with the paths in that dir
+func ExtractBundle(path string, toDir string, reposInfoFileName string, sep string) []RepoInfo {
+	file, err := os.Open(path)
+	if err != nil {
+		panic(fmt.Errorf("[ExtractBundle] open bundle '%s' failed: %v", path, err))
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		panic(fmt.Errorf("[ExtractBundle] read bundle '%s' failed: %v", path, err))
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic(fmt.Errorf("[ExtractBundle] read bundle '%s' failed: %v", path, err))
+		}
+		target, err := archiveEntryTarget(toDir, header.Name)
+		if err != nil {
+			panic(fmt.Errorf("[ExtractBundle] bundle '%s': %v", path, err))
+		}
+		err = extractTarEntry(tr, header, toDir, target)
+		if err != nil {
+			panic(fmt.Errorf("[ExtractBundle] extract '%s' from bundle '%s' failed: %v",
+				header.Name, path, err))
+		}
+	}
+
+	infoPath := filepath.Join(toDir, reposInfoFileName)
+	infos, _ := ReadReposInfoFile(infoPath, false, sep)
+	for i, info := range infos {
+		if len(info.Addr) == 0 || filepath.IsAbs(info.Path) ||
+			filepath.Dir(filepath.Clean(info.Path)) != bundleReposDir {
+			panic(fmt.Errorf("[ExtractBundle] bundle '%s' has bad repo entry '%s' '%s'",
+				path, info.Addr, info.Path))
+		}
+		info.Path = filepath.Join(toDir, info.Path)
+		infos[i] = info
+	}
+	return infos
+}
+
+func addDirToTar(tw *tar.Writer, dir string, name string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addFileToTar(tw, path, filepath.ToSlash(filepath.Join(name, rel)))
+	})
+}
+
+func addFileToTar(tw *tar.Writer, path string, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+func extractTarEntry(tr *tar.Reader, header *tar.Header, toDir string, target string) error {
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, os.FileMode(header.Mode)|0700)
+	case tar.TypeSymlink:
+		os.MkdirAll(filepath.Dir(target), os.ModePerm)
+		return extractSymlink(toDir, target, header.Linkname)
+	case tar.TypeReg:
+		os.MkdirAll(filepath.Dir(target), os.ModePerm)
+		file, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(file, tr)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package hub_meta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundleExportAndExtract(t *testing.T) {
+	root, err := ioutil.TempDir("", "bundle-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	repoPath := filepath.Join(root, "hub", "x.ticat")
+	os.MkdirAll(filepath.Join(repoPath, "sub"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(repoPath, "hub.ticat"), []byte("help = x\n"), 0644)
+	ioutil.WriteFile(filepath.Join(repoPath, "sub", "run.sh"), []byte("echo x\n"), 0755)
+
+	infos := []RepoInfo{{Addr: "a/x.ticat", AddReason: "a/x.ticat", Path: repoPath, HelpStr: "x", OnOff: "on",
+		Ref: "v1", Priority: 2, MountPoint: "vendor"}}
+	bundlePath := filepath.Join(root, "x.bundle")
+	ExportBundle(bundlePath, infos, "repos.hub", "\t")
+
+	toDir := filepath.Join(root, "extracted")
+	extracteds := ExtractBundle(bundlePath, toDir, "repos.hub", "\t")
+	if len(extracteds) != 1 {
+		t.Fatalf("wrong extracted repos: %v", extracteds)
+	}
+	info := extracteds[0]
+	expected := infos[0]
+	expected.Path = filepath.Join(toDir, "repos", "x.ticat")
+	if info != expected {
+		t.Fatalf("wrong extracted repo: %v != %v", info, expected)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(info.Path, "sub", "run.sh"))
+	if err != nil || string(data) != "echo x\n" {
+		t.Fatalf("wrong extracted file: %v %s", err, data)
+	}
+	stat, err := os.Stat(filepath.Join(info.Path, "sub", "run.sh"))
+	if err != nil || stat.Mode()&0100 == 0 {
+		t.Fatalf("file mode should be kept: %v %v", err, stat)
+	}
+}
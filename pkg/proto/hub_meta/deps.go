@@ -0,0 +1,133 @@
+package hub_meta
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+const (
+	DependOk            = "ok"
+	DependMissing       = "missing"
+	DependDisabled      = "disabled"
+	DependMismatch      = "mismatch"
+	DependBadVersion    = "bad-version"
+	DependBadConstraint = "bad-constraint"
+)
+
+// The check result of a dependency declared by an enabled repo
+type DependCheck struct {
+	// The addr of the repo declares this dependency, or the path if it's a local dir
+	Repo   string
+	Depend RepoDepend
+	// The version of the depended repo, could be empty
+	Version string
+	Status  string
+}
+
+func (self DependCheck) Ok() bool {
+	return self.Status == DependOk
+}
+
+func (self DependCheck) IsMissing() bool {
+	return self.Status == DependMissing || self.Status == DependDisabled
+}
+
+// Repos require incompatible versions of a shared repo
+type DependConflict struct {
+	Addr     string
+	Version  string
+	Requires []DependCheck
+}
+
+// Check the dependencies of all enabled repos, by the versions of the repos in the hub
+func ResolveRepoDepends(
+	infos []RepoInfo,
+	listFileName string,
+	selfName string) (checks []DependCheck, conflicts []DependConflict) {
+
+	metas := map[string]RepoMeta{}
+	readMeta := func(info RepoInfo) RepoMeta {
+		meta, ok := metas[info.Path]
+		if !ok {
+			meta = ReadRepoMetaFromFile(selfName, filepath.Join(info.Path, listFileName))
+			metas[info.Path] = meta
+		}
+		return meta
+	}
+
+	var sharedAddrs []string
+	shareds := map[string][]DependCheck{}
+
+	for _, info := range infos {
+		if info.OnOff != "on" {
+			continue
+		}
+		repo := info.Addr
+		if info.IsLocal() {
+			repo = info.Path
+		}
+		for _, dep := range readMeta(info).Depends {
+			check := DependCheck{repo, dep, "", DependOk}
+			addr, _ := SplitAddrAndRef(dep.Addr)
+			constraint, err := ParseVersionConstraint(dep.Constraint)
+			target, found := FindRepoInfo(infos, addr)
+
+			if err != nil {
+				check.Status = DependBadConstraint
+			} else if !found {
+				check.Status = DependMissing
+			} else if target.OnOff != "on" {
+				check.Status = DependDisabled
+			} else {
+				check.Version = readMeta(target).Version
+				ver, err := ParseVersion(check.Version)
+				if err != nil && len(constraint.conds) != 0 {
+					check.Status = DependBadVersion
+				} else if !constraint.Match(ver) {
+					check.Status = DependMismatch
+				}
+			}
+			checks = append(checks, check)
+
+			if found && err == nil {
+				if _, ok := shareds[target.Addr]; !ok {
+					sharedAddrs = append(sharedAddrs, target.Addr)
+				}
+				shareds[target.Addr] = append(shareds[target.Addr], check)
+			}
+		}
+	}
+
+	for _, addr := range sharedAddrs {
+		requires := shareds[addr]
+		repos := map[string]bool{}
+		var constraints []VersionConstraint
+		for _, it := range requires {
+			repos[it.Repo] = true
+			constraint, _ := ParseVersionConstraint(it.Depend.Constraint)
+			constraints = append(constraints, constraint)
+		}
+		if len(repos) < 2 || VersionConstraintsCompatible(constraints) {
+			continue
+		}
+		conflicts = append(conflicts, DependConflict{addr, requires[0].Version, requires})
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Addr < conflicts[j].Addr
+	})
+	return
+}
+
+// The addrs in repo list files may be abbrs, compare them after normalizing
+func FindRepoInfo(infos []RepoInfo, addr string) (RepoInfo, bool) {
+	normalized := NormalizeRepoAddr(addr)
+	for _, info := range infos {
+		if info.IsLocal() {
+			continue
+		}
+		if info.Addr == addr || NormalizeRepoAddr(info.Addr) == normalized {
+			return info, true
+		}
+	}
+	return RepoInfo{}, false
+}
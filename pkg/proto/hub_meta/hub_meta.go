@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -14,12 +16,33 @@ type RepoInfo struct {
 	Path      string
 	HelpStr   string
 	OnOff     string
+	// Branch, tag or commit, empty means the default branch
+	Ref string
+	// When two repos/dirs have the same cmd, the higher priority one shadows the other one
+	Priority int
+	// The cmd path all cmds of this repo/dir mounted under, like 'vendor.foo', empty means the root
+	MountPoint string
 }
 
 func (self RepoInfo) IsLocal() bool {
 	return len(self.Addr) == 0
 }
 
+// The optional fields are omitted if they are the default values, so the line is the same as old versions
+func (self RepoInfo) fields() []string {
+	var priority string
+	if self.Priority != 0 {
+		priority = strconv.Itoa(self.Priority)
+	}
+	fields := []string{self.Addr, self.AddReason, self.Path, self.HelpStr, self.OnOff,
+		self.Ref, priority, self.MountPoint}
+	n := len(fields)
+	for n > 5 && len(fields[n-1]) == 0 {
+		n -= 1
+	}
+	return fields[:n]
+}
+
 func WriteReposInfoFile(path string, infos []RepoInfo, sep string) {
 	os.MkdirAll(filepath.Dir(path), os.ModePerm)
 	tmp := path + ".tmp"
@@ -30,8 +53,7 @@ func WriteReposInfoFile(path string, infos []RepoInfo, sep string) {
 	defer file.Close()
 
 	for _, info := range infos {
-		_, err = fmt.Fprintf(file, "%s%s%s%s%s%s%s%s%s\n", info.Addr, sep,
-			info.AddReason, sep, info.Path, sep, info.HelpStr, sep, info.OnOff)
+		_, err = fmt.Fprintf(file, "%s\n", strings.Join(info.fields(), sep))
 		if err != nil {
 			panic(fmt.Errorf("[WriteReposInfoFile] write file '%s' failed: %v", tmp, err))
 		}
@@ -66,16 +88,30 @@ func ReadReposInfoFile(
 	for scanner.Scan() {
 		line := strings.Trim(scanner.Text(), "\n\r")
 		fields := strings.Split(line, sep)
-		if len(fields) != 5 {
+		// Files from old versions have no 'Ref', 'Priority' or 'MountPoint' field
+		if len(fields) < 5 || len(fields) > 8 {
 			panic(fmt.Errorf("[ReadReposInfoFile] file '%s' line '%s' can't be parsed",
 				path, line))
 		}
 		info := RepoInfo{
-			fields[0],
-			fields[1],
-			fields[2],
-			fields[3],
-			fields[4],
+			Addr:      fields[0],
+			AddReason: fields[1],
+			Path:      fields[2],
+			HelpStr:   fields[3],
+			OnOff:     fields[4],
+		}
+		if len(fields) > 5 {
+			info.Ref = fields[5]
+		}
+		if len(fields) > 6 && len(fields[6]) != 0 {
+			info.Priority, err = strconv.Atoi(fields[6])
+			if err != nil {
+				panic(fmt.Errorf("[ReadReposInfoFile] file '%s' line '%s' has bad priority: %v",
+					path, line, err))
+			}
+		}
+		if len(fields) > 7 {
+			info.MountPoint = fields[7]
 		}
 		infos = append(infos, info)
 		list[info.Addr] = true
@@ -83,6 +119,52 @@ func ReadReposInfoFile(
 	return
 }
 
+// Lower priority ones are the first, so the higher ones could shadow them when loading
+func SortReposByPriority(infos []RepoInfo) []RepoInfo {
+	sorted := append([]RepoInfo{}, infos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	return sorted
+}
+
+func (self RepoInfo) MountPath(pathSep string) []string {
+	return SplitMountPoint(self.MountPoint, pathSep)
+}
+
+// Split a mount point to a cmd path, the redundant seps are ignored
+func SplitMountPoint(mountPoint string, pathSep string) (path []string) {
+	for _, it := range strings.Split(mountPoint, pathSep) {
+		it = strings.TrimSpace(it)
+		if len(it) != 0 {
+			path = append(path, it)
+		}
+	}
+	return
+}
+
+// Replace the infos by the updated ones in place, matched by addr, or by path for local dirs
+func UpdateReposInfo(infos []RepoInfo, updated []RepoInfo) []RepoInfo {
+	key := func(info RepoInfo) string {
+		if info.IsLocal() {
+			return info.Path
+		}
+		return info.Addr
+	}
+	updates := map[string]RepoInfo{}
+	for _, info := range updated {
+		updates[key(info)] = info
+	}
+	res := make([]RepoInfo, 0, len(infos))
+	for _, info := range infos {
+		if it, ok := updates[key(info)]; ok {
+			info = it
+		}
+		res = append(res, info)
+	}
+	return res
+}
+
 func ExtractAddrFromList(
 	infos []RepoInfo,
 	findStr string) (extracted []RepoInfo, rest []RepoInfo) {
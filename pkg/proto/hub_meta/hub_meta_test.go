@@ -0,0 +1,68 @@
+package hub_meta
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReposInfoFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "hub-meta-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "repos.hub")
+	infos := []RepoInfo{
+		{Addr: "a/x.ticat", AddReason: "a/x.ticat", Path: "/hub/x.ticat", HelpStr: "x", OnOff: "on"},
+		{Addr: "a/y.ticat", AddReason: "a/y.ticat", Path: "/hub/y.ticat", OnOff: "off", Ref: "v1"},
+		{AddReason: "<local>", Path: "/data/z", OnOff: "on", Priority: -1, MountPoint: "vendor.z"},
+	}
+	WriteReposInfoFile(path, infos, "\t")
+
+	// The optional fields with default values are omitted, so old versions could read the file
+	data, _ := ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := []int{5, 6, 8}
+	for i, line := range lines {
+		if n := len(strings.Split(line, "\t")); n != expected[i] {
+			t.Fatalf("line '%s' has %d fields, expected %d", line, n, expected[i])
+		}
+	}
+
+	loaded, list := ReadReposInfoFile(path, false, "\t")
+	if fmt.Sprintf("%v", loaded) != fmt.Sprintf("%v", infos) {
+		t.Fatalf("read repos info: %v != %v", loaded, infos)
+	}
+	if !list["a/x.ticat"] || !list["a/y.ticat"] || !list[""] {
+		t.Fatalf("wrong repo list: %v", list)
+	}
+}
+
+func TestUpdateReposInfo(t *testing.T) {
+	infos := []RepoInfo{
+		{Addr: "a/x.ticat", Path: "/hub/x.ticat", OnOff: "on"},
+		{AddReason: "<local>", Path: "/data/y", OnOff: "on"},
+		{Addr: "a/z.ticat", Path: "/hub/z.ticat", OnOff: "on"},
+	}
+	extracted, _ := ExtractAddrFromList(infos, "x")
+	extracted[0].OnOff = "disabled"
+	updated := UpdateReposInfo(infos, append(extracted, RepoInfo{AddReason: "<local>", Path: "/data/y",
+		OnOff: "on", Priority: 2}))
+
+	var strs []string
+	for _, it := range updated {
+		strs = append(strs, fmt.Sprintf("%s%s:%s:%d", it.Addr, it.Path, it.OnOff, it.Priority))
+	}
+	expected := "a/x.ticat/hub/x.ticat:disabled:0,/data/y:on:2,a/z.ticat/hub/z.ticat:on:0"
+	if strings.Join(strs, ",") != expected {
+		t.Fatalf("repos should be updated in place: %s", strings.Join(strs, ","))
+	}
+	if infos[0].OnOff != "on" {
+		t.Fatalf("the origin infos should not be modified")
+	}
+}
@@ -1,44 +1,36 @@
 package hub_meta
 
 import (
-	"fmt"
-	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
-
-	"github.com/pingcap/ticat/pkg/cli/core"
 )
 
-func UpdateRepoAndSubRepos(
-	screen core.Screen,
-	finisheds map[string]bool,
-	hubPath string,
-	gitAddr string,
-	repoExt string,
-	listFileName string,
-	selfName string,
-	cmd core.ParsedCmd) (topRepoHelpStr string, addrs []string, helpStrs []string) {
-
-	if finisheds[gitAddr] {
-		return
-	}
-	topRepoHelpStr, addrs, helpStrs = updateRepoAndReadSubList(
-		screen, hubPath, gitAddr, listFileName, selfName, cmd)
-	finisheds[gitAddr] = true
-
-	for i, addr := range addrs {
-		subTopHelpStr, subAddrs, subHelpStrs := UpdateRepoAndSubRepos(
-			screen, finisheds, hubPath, addr, repoExt, listFileName, selfName, cmd)
-		// If a repo has no help-str from hub-repo list, try to get the title from it's README
-		if len(helpStrs[i]) == 0 && len(subTopHelpStr) != 0 {
-			helpStrs[i] = subTopHelpStr
+// Split 'addr@ref', the '@' in 'git@github.com:...' or 'https://user@host/...' is not a ref separator
+func SplitAddrAndRef(addr string) (string, string) {
+	start := 0
+	if i := strings.Index(addr, "://"); i >= 0 {
+		start = i + 3
+		j := strings.Index(addr[start:], "/")
+		if j < 0 {
+			return addr, ""
 		}
-		addrs = append(addrs, subAddrs...)
-		helpStrs = append(helpStrs, subHelpStrs...)
+		start += j
+	} else if i := strings.IndexAny(addr, ":/"); i >= 0 {
+		start = i
+	}
+	i := strings.Index(addr[start:], "@")
+	if i < 0 {
+		return addr, ""
 	}
+	i += start
+	return addr[:i], addr[i+1:]
+}
 
-	return topRepoHelpStr, addrs, helpStrs
+func JoinAddrAndRef(addr string, ref string) string {
+	if len(ref) == 0 {
+		return addr
+	}
+	return addr + "@" + ref
 }
 
 func NormalizeGitAddr(addr string) string {
@@ -59,49 +51,11 @@ func AddrDisplayName(addr string) string {
 	return abbr
 }
 
-func GetRepoPath(hubPath string, gitAddr string) string {
-	return filepath.Join(hubPath, filepath.Base(gitAddr))
-}
-
-func updateRepoAndReadSubList(
-	screen core.Screen,
-	hubPath string,
-	gitAddr string,
-	listFileName string,
-	selfName string,
-	cmd core.ParsedCmd) (helpStr string, addrs []string, helpStrs []string) {
-
-	name := AddrDisplayName(gitAddr)
-	repoPath := GetRepoPath(hubPath, gitAddr)
-	var cmdStrs []string
-
-	stat, err := os.Stat(repoPath)
-	var pwd string
-	if !os.IsNotExist(err) {
-		if !stat.IsDir() {
-			panic(core.WrapCmdError(cmd, fmt.Errorf("repo path '%v' exists but is not dir",
-				repoPath)))
-		}
-		screen.Print(fmt.Sprintf("[%s] => git update\n", name))
-		cmdStrs = []string{"git", "pull", "--recurse-submodules"}
-		pwd = repoPath
-	} else {
-		screen.Print(fmt.Sprintf("[%s] => git clone\n", name))
-		cmdStrs = []string{"git", "clone", "--recursive", gitAddr, repoPath}
-	}
-
-	c := exec.Command(cmdStrs[0], cmdStrs[1:]...)
-	if len(pwd) != 0 {
-		c.Dir = pwd
-	}
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	err = c.Run()
-	if err != nil {
-		panic(core.WrapCmdError(cmd, fmt.Errorf("run '%v' failed: %v", cmdStrs, err)))
-	}
-	listFilePath := filepath.Join(repoPath, listFileName)
-	return ReadRepoListFromFile(selfName, listFilePath)
+func gitOutput(dir string, args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	out, err := c.Output()
+	return strings.TrimSpace(string(out)), err
 }
 
 func githubAddrAbbr(addr string) (abbr string) {
@@ -0,0 +1,22 @@
+package hub_meta
+
+import (
+	"testing"
+)
+
+func TestSplitAddrAndRef(t *testing.T) {
+	test := func(str string, addr string, ref string) {
+		a, r := SplitAddrAndRef(str)
+		if a != addr || r != ref {
+			t.Fatalf("split '%s': ('%s', '%s') != ('%s', '%s')", str, a, r, addr, ref)
+		}
+	}
+
+	test("innerr/tidb.ticat", "innerr/tidb.ticat", "")
+	test("innerr/tidb.ticat@v1.0", "innerr/tidb.ticat", "v1.0")
+	test("innerr/tidb.ticat@release/1.0", "innerr/tidb.ticat", "release/1.0")
+	test("git@github.com:innerr/tidb.ticat", "git@github.com:innerr/tidb.ticat", "")
+	test("git@github.com:innerr/tidb.ticat@main", "git@github.com:innerr/tidb.ticat", "main")
+	test("https://github.com/innerr/tidb.ticat", "https://github.com/innerr/tidb.ticat", "")
+	test("https://user@github.com/innerr/tidb.ticat@v2", "https://user@github.com/innerr/tidb.ticat", "v2")
+}
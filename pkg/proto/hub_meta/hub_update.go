@@ -0,0 +1,247 @@
+package hub_meta
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+const (
+	RepoUpdateCloned    = "cloned"
+	RepoUpdateUpdated   = "updated"
+	RepoUpdateUnchanged = "unchanged"
+	RepoUpdateFailed    = "failed"
+)
+
+// The result of updating one repo, sub-repos are the ones listed in its repo list file
+type RepoUpdateResult struct {
+	Addr string
+	Ref  string
+	// The top repo which brings this one in, equals to Addr if this is a top repo
+	Root string
+	// The repo which lists this one, empty if this is a top repo
+	Parent      string
+	ListHelpStr string
+
+	Status    string
+	OldCommit string
+	NewCommit string
+	Duration  time.Duration
+	Err       error
+	// The output of the backend, only be kept when failed
+	Output string
+
+	HelpStr     string
+	SubAddrs    []string
+	SubRefs     []string
+	SubHelpStrs []string
+	// The depended repos will also be updated, but they don't change the refs of the listed ones
+	Depends []RepoDepend
+}
+
+func (self RepoUpdateResult) Name() string {
+	return JoinAddrAndRef(AddrDisplayName(self.Addr), self.Ref)
+}
+
+func (self RepoUpdateResult) Failed() bool {
+	return self.Status == RepoUpdateFailed
+}
+
+// If a repo has no help-str from the upper repo's list, use the title from it's own list file
+func (self RepoUpdateResult) DisplayHelpStr() string {
+	if len(self.ListHelpStr) != 0 {
+		return self.ListHelpStr
+	}
+	return self.HelpStr
+}
+
+type repoUpdateTask struct {
+	idx    int
+	result RepoUpdateResult
+}
+
+type repoUpdateEvent struct {
+	idx     int
+	started bool
+	action  string
+	result  RepoUpdateResult
+}
+
+// Update (clone or pull) the repos and their sub-repos by a pool of workers.
+// A failed repo doesn't stop the others, the sub-repos of it are skipped.
+// A repo fails without running if its local path is used by another one, eg: 'a/x.ticat' and 'b/x.ticat'.
+// The results are in the order of discovery, the top repos are the first ones.
+func UpdateReposInParallel(
+	screen core.Screen,
+	finisheds map[string]bool,
+	hubPath string,
+	addrs []string,
+	refs []string,
+	listFileName string,
+	selfName string,
+	concurrency int) (results []RepoUpdateResult) {
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	for addr, _ := range finisheds {
+		finisheds[NormalizeRepoAddr(addr)] = true
+	}
+
+	// Only this goroutine touches the screen, the results and the pending list
+	var pending []repoUpdateTask
+	running := 0
+	finished := 0
+	printResult := func(result RepoUpdateResult) {
+		screen.Print(fmt.Sprintf("[%s] <= %s (%d/%d, %s)\n", result.Name(),
+			repoUpdateStatusStr(result), finished, len(results), formatDuration(result.Duration)))
+	}
+
+	// The same repo may be written in different forms in different lists
+	repoPaths := map[string]string{}
+	addTask := func(result RepoUpdateResult) {
+		normalized := NormalizeRepoAddr(result.Addr)
+		if finisheds[result.Addr] || finisheds[normalized] {
+			return
+		}
+		finisheds[result.Addr] = true
+		finisheds[normalized] = true
+		repoPath := GetRepoPath(hubPath, result.Addr)
+		if addr, ok := repoPaths[repoPath]; ok {
+			result.Status = RepoUpdateFailed
+			result.Err = fmt.Errorf("repo path '%s' is already used by '%s'", repoPath, addr)
+			results = append(results, result)
+			finished += 1
+			printResult(result)
+			return
+		}
+		repoPaths[repoPath] = result.Addr
+		pending = append(pending, repoUpdateTask{len(results), result})
+		results = append(results, result)
+	}
+	for i, addr := range addrs {
+		addTask(RepoUpdateResult{Addr: addr, Ref: refs[i], Root: addr})
+	}
+
+	tasks := make(chan repoUpdateTask)
+	events := make(chan repoUpdateEvent)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for task := range tasks {
+				action := repoUpdateAction(hubPath, task.result.Addr)
+				events <- repoUpdateEvent{task.idx, true, action, task.result}
+				result := updateRepo(task.result, hubPath, listFileName, selfName)
+				events <- repoUpdateEvent{task.idx, false, "", result}
+			}
+		}()
+	}
+
+	for len(pending) != 0 || running != 0 {
+		var sendTo chan repoUpdateTask
+		var next repoUpdateTask
+		if len(pending) != 0 {
+			sendTo = tasks
+			next = pending[0]
+		}
+		select {
+		case sendTo <- next:
+			pending = pending[1:]
+			running += 1
+		case event := <-events:
+			if event.started {
+				screen.Print(fmt.Sprintf("[%s] => %s\n", event.result.Name(), event.action))
+				continue
+			}
+			running -= 1
+			finished += 1
+			result := event.result
+			results[event.idx] = result
+			printResult(result)
+			for i, addr := range result.SubAddrs {
+				addTask(RepoUpdateResult{Addr: addr, Ref: result.SubRefs[i], Root: result.Root,
+					Parent: result.Addr, ListHelpStr: result.SubHelpStrs[i]})
+			}
+			for _, dep := range result.Depends {
+				addr, ref := SplitAddrAndRef(dep.Addr)
+				addTask(RepoUpdateResult{Addr: addr, Ref: ref, Root: result.Root, Parent: result.Addr})
+			}
+		}
+	}
+	close(tasks)
+	return
+}
+
+func updateRepo(
+	task RepoUpdateResult,
+	hubPath string,
+	listFileName string,
+	selfName string) (result RepoUpdateResult) {
+
+	result = task
+	start := time.Now()
+	backend := GetHubBackend(result.Addr)
+	var output bytes.Buffer
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("%v", r)
+		}
+		if result.Err != nil {
+			result.Status = RepoUpdateFailed
+			result.Output = output.String()
+		}
+		result.Duration = time.Since(start)
+	}()
+
+	repoPath := backend.RepoPath(hubPath, result.Addr)
+	if _, err := os.Stat(repoPath); err == nil {
+		result.OldCommit, _ = backend.Commit(repoPath)
+	}
+	err := backend.Update(repoPath, result.Addr, result.Ref, &output)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.NewCommit, _ = backend.Commit(repoPath)
+	if len(result.OldCommit) == 0 {
+		result.Status = RepoUpdateCloned
+	} else if result.OldCommit != result.NewCommit {
+		result.Status = RepoUpdateUpdated
+	} else {
+		result.Status = RepoUpdateUnchanged
+	}
+
+	meta := ReadRepoMetaFromFile(selfName, filepath.Join(repoPath, listFileName))
+	result.HelpStr = meta.HelpStr
+	result.SubHelpStrs = meta.HelpStrs
+	result.Depends = meta.Depends
+	for _, it := range meta.Addrs {
+		addr, ref := SplitAddrAndRef(it)
+		result.SubAddrs = append(result.SubAddrs, addr)
+		result.SubRefs = append(result.SubRefs, ref)
+	}
+	return result
+}
+
+func repoUpdateAction(hubPath string, addr string) string {
+	backend := GetHubBackend(addr)
+	if _, err := os.Stat(backend.RepoPath(hubPath, addr)); err == nil {
+		return backend.Name() + " update"
+	}
+	return backend.Name() + " clone"
+}
+
+func repoUpdateStatusStr(result RepoUpdateResult) string {
+	if result.Failed() {
+		return RepoUpdateFailed + ": " + result.Err.Error()
+	}
+	return result.Status
+}
+
+func formatDuration(dur time.Duration) string {
+	return dur.Round(time.Millisecond * 100).String()
+}
@@ -0,0 +1,62 @@
+package hub_meta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testScreen struct {
+	lines []string
+}
+
+func (self *testScreen) Print(text string) {
+	self.lines = append(self.lines, text)
+}
+
+func (self *testScreen) Error(text string) {
+	self.lines = append(self.lines, text)
+}
+
+func (self *testScreen) OutputNum() int {
+	return len(self.lines)
+}
+
+func TestUpdateReposWithSameRepoPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "hub-update-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	var addrs []string
+	for _, it := range []string{"a", "b", "c"} {
+		src := filepath.Join(root, it, "x.ticat")
+		if it == "c" {
+			src = filepath.Join(root, it, "y.ticat")
+		}
+		os.MkdirAll(src, os.ModePerm)
+		ioutil.WriteFile(filepath.Join(src, "hub.ticat"), []byte("help = "+it+"\n"), 0644)
+		addrs = append(addrs, "dir:"+src)
+	}
+
+	hubPath := filepath.Join(root, "hub")
+	os.MkdirAll(hubPath, os.ModePerm)
+	results := UpdateReposInParallel(&testScreen{}, map[string]bool{}, hubPath,
+		addrs, []string{"", "", ""}, "hub.ticat", "ticat", 3)
+	if len(results) != 3 {
+		t.Fatalf("wrong results count: %d", len(results))
+	}
+	if results[0].Failed() || results[2].Failed() {
+		t.Fatalf("repos with different paths should succeed: %v, %v", results[0].Err, results[2].Err)
+	}
+	if !results[1].Failed() || !strings.Contains(results[1].Err.Error(), "already used by") {
+		t.Fatalf("repo with a used path should fail: %#v", results[1])
+	}
+	content, _ := ioutil.ReadFile(filepath.Join(hubPath, "x.ticat", "hub.ticat"))
+	if string(content) != "help = a\n" {
+		t.Fatalf("repo path should not be overwritten: %s", content)
+	}
+}
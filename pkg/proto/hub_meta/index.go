@@ -0,0 +1,171 @@
+package hub_meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+// The searchable info of a cmd, for finding cmds in the repos not loaded
+type IndexedCmd struct {
+	Path string   `json:"path"`
+	Help string   `json:"help,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+	Args []string `json:"args,omitempty"`
+}
+
+// A repo listed by other repos but never added has no cmds in index
+type RepoIndex struct {
+	Addr string `json:"addr,omitempty"`
+	// Only for local dirs
+	Path     string       `json:"path,omitempty"`
+	HelpStr  string       `json:"help,omitempty"`
+	ListedBy []string     `json:"listed-by,omitempty"`
+	Cmds     []IndexedCmd `json:"cmds,omitempty"`
+}
+
+func (self RepoIndex) IsLocal() bool {
+	return len(self.Addr) == 0
+}
+
+func (self RepoIndex) Name() string {
+	if self.IsLocal() {
+		return filepath.Base(self.Path)
+	}
+	return AddrDisplayName(self.Addr)
+}
+
+func (self RepoIndex) key() string {
+	if self.IsLocal() {
+		return self.Path
+	}
+	return NormalizeRepoAddr(self.Addr)
+}
+
+func IndexCmdTree(tree *core.CmdTree) (cmds []IndexedCmd) {
+	cmd := tree.Cmd()
+	if cmd != nil && !tree.IsRoot() {
+		it := IndexedCmd{Path: tree.DisplayPath(), Help: cmd.Help(), Tags: ExtractTags(cmd.Help())}
+		args := cmd.Args()
+		it.Args = args.Names()
+		cmds = append(cmds, it)
+	}
+	for _, name := range tree.SubNames() {
+		cmds = append(cmds, IndexCmdTree(tree.GetSub(name))...)
+	}
+	return
+}
+
+// Tags are the words with prefix '@' in help strings, like '@ready'
+func ExtractTags(help string) (tags []string) {
+	for _, word := range strings.Fields(help) {
+		word = strings.Trim(word, ",.;:()[]'\"")
+		if len(word) > 1 && word[0] == '@' {
+			tags = append(tags, word)
+		}
+	}
+	return
+}
+
+// The new indexes come first, the old ones not in the new list are kept, since they are still known.
+// If a new one has no cmds (listed but not added), use the cmds from the old one
+func MergeRepoIndexes(olds []RepoIndex, news []RepoIndex) (merged []RepoIndex) {
+	oldIdx := map[string]int{}
+	for i, it := range olds {
+		oldIdx[it.key()] = i
+	}
+	newKeys := map[string]bool{}
+	for _, it := range news {
+		newKeys[it.key()] = true
+		i, ok := oldIdx[it.key()]
+		if ok && len(it.Cmds) == 0 {
+			it.Cmds = olds[i].Cmds
+		}
+		merged = append(merged, it)
+	}
+	for _, it := range olds {
+		if !newKeys[it.key()] {
+			merged = append(merged, it)
+		}
+	}
+	return
+}
+
+// Each find-str should match the repo addr, help string, or the path/help/tags/args of a cmd.
+// The result only keeps the matched cmds, a repo without cmds is matched by its addr and help string
+func SearchRepoIndexes(indexes []RepoIndex, findStrs []string) (res []RepoIndex) {
+	for _, repo := range indexes {
+		matched := repo
+		matched.Cmds = nil
+		if len(repo.Cmds) == 0 {
+			if matchRepoIndex(repo, nil, findStrs) {
+				res = append(res, matched)
+			}
+			continue
+		}
+		for _, cmd := range repo.Cmds {
+			if matchRepoIndex(repo, &cmd, findStrs) {
+				matched.Cmds = append(matched.Cmds, cmd)
+			}
+		}
+		if len(matched.Cmds) != 0 {
+			res = append(res, matched)
+		}
+	}
+	return
+}
+
+func matchRepoIndex(repo RepoIndex, cmd *IndexedCmd, findStrs []string) bool {
+	for _, findStr := range findStrs {
+		strs := []string{repo.Addr, repo.Path, repo.HelpStr}
+		if cmd != nil {
+			strs = append(strs, cmd.Path, cmd.Help)
+			strs = append(strs, cmd.Tags...)
+			strs = append(strs, cmd.Args...)
+		}
+		matched := false
+		for _, str := range strs {
+			if strings.Index(str, findStr) >= 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func WriteRepoIndexes(path string, indexes []RepoIndex) {
+	data, err := json.MarshalIndent(indexes, "", "    ")
+	if err != nil {
+		panic(fmt.Errorf("[WriteRepoIndexes] encode json failed: %v", err))
+	}
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[WriteRepoIndexes] write file '%s' failed: %v", path, err))
+	}
+}
+
+// Return false if there is no such file, means the index is not built yet
+func ReadRepoIndexes(path string) (indexes []RepoIndex, exists bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return
+		}
+		panic(fmt.Errorf("[ReadRepoIndexes] read file '%s' failed: %v", path, err))
+	}
+	err = json.Unmarshal(data, &indexes)
+	if err != nil {
+		panic(fmt.Errorf("[ReadRepoIndexes] decode file '%s' failed: %v", path, err))
+	}
+	return indexes, true
+}
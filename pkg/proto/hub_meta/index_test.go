@@ -0,0 +1,73 @@
+package hub_meta
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	test := func(help string, expected ...string) {
+		tags := ExtractTags(help)
+		if strings.Join(tags, " ") != strings.Join(expected, " ") {
+			t.Fatalf("tags of '%s': %v != %v", help, tags, expected)
+		}
+	}
+
+	test("")
+	test("run bench")
+	test("run bench @ready", "@ready")
+	test("(@ready, @provider) run bench", "@ready", "@provider")
+	test("mail to a@b.com")
+}
+
+func TestSearchRepoIndexes(t *testing.T) {
+	indexes := []RepoIndex{
+		{Addr: "a/x.ticat", Cmds: []IndexedCmd{
+			{Path: "bench.run", Help: "run bench @ready", Tags: []string{"@ready"}},
+			{Path: "bench.stop", Help: "stop bench", Args: []string{"force"}},
+		}},
+		{Addr: "a/y.ticat", HelpStr: "more bench tools"},
+	}
+
+	res := SearchRepoIndexes(indexes, []string{"@ready"})
+	if len(res) != 1 || len(res[0].Cmds) != 1 || res[0].Cmds[0].Path != "bench.run" {
+		t.Fatalf("wrong result: %v", res)
+	}
+	res = SearchRepoIndexes(indexes, []string{"bench", "force"})
+	if len(res) != 1 || len(res[0].Cmds) != 1 || res[0].Cmds[0].Path != "bench.stop" {
+		t.Fatalf("wrong result: %v", res)
+	}
+	res = SearchRepoIndexes(indexes, []string{"bench"})
+	if len(res) != 2 || len(res[0].Cmds) != 2 || len(res[1].Cmds) != 0 {
+		t.Fatalf("wrong result: %v", res)
+	}
+	res = SearchRepoIndexes(indexes, []string{"x.ticat", "stop"})
+	if len(res) != 1 || len(res[0].Cmds) != 1 {
+		t.Fatalf("wrong result: %v", res)
+	}
+}
+
+func TestMergeRepoIndexes(t *testing.T) {
+	olds := []RepoIndex{
+		{Addr: "a/x.ticat", Cmds: []IndexedCmd{{Path: "x"}}},
+		{Addr: "a/y.ticat", Cmds: []IndexedCmd{{Path: "y"}}},
+		{Path: "/tmp/z", Cmds: []IndexedCmd{{Path: "z"}}},
+	}
+	news := []RepoIndex{
+		{Addr: "git@github.com:a/x.ticat", ListedBy: []string{"w"}},
+		{Addr: "a/y.ticat", Cmds: []IndexedCmd{{Path: "y2"}}},
+	}
+	merged := MergeRepoIndexes(olds, news)
+	if len(merged) != 3 {
+		t.Fatalf("wrong merged: %v", merged)
+	}
+	if len(merged[0].Cmds) != 1 || merged[0].Cmds[0].Path != "x" {
+		t.Fatalf("cmds of a listed repo should be kept: %v", merged[0])
+	}
+	if merged[1].Cmds[0].Path != "y2" {
+		t.Fatalf("cmds should be updated: %v", merged[1])
+	}
+	if merged[2].Path != "/tmp/z" {
+		t.Fatalf("old repo should be kept: %v", merged[2])
+	}
+}
@@ -0,0 +1,123 @@
+package hub_meta
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+// The exact state of a repo in hub, for reproducing it on another machine
+type RepoLock struct {
+	Addr      string
+	AddReason string
+	Ref       string
+	Commit    string
+}
+
+// Local dirs are not locked, a repo failed to get commit will return error
+func GenRepoLocks(infos []RepoInfo) (locks []RepoLock, err error) {
+	for _, info := range infos {
+		if info.IsLocal() {
+			continue
+		}
+		commit, err := GetRepoCommit(info.Addr, info.Path)
+		if err != nil {
+			return nil, fmt.Errorf("get commit of repo '%s' in '%s' failed: %v",
+				info.Addr, info.Path, err)
+		}
+		locks = append(locks, RepoLock{info.Addr, info.AddReason, info.Ref, commit})
+	}
+	return
+}
+
+func WriteLockFile(path string, locks []RepoLock, sep string) {
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[WriteLockFile] open file '%s' failed: %v", tmp, err))
+	}
+	defer file.Close()
+
+	for _, lock := range locks {
+		_, err = fmt.Fprintf(file, "%s%s%s%s%s%s%s\n", lock.Addr, sep,
+			lock.AddReason, sep, lock.Ref, sep, lock.Commit)
+		if err != nil {
+			panic(fmt.Errorf("[WriteLockFile] write file '%s' failed: %v", tmp, err))
+		}
+	}
+	file.Close()
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		panic(fmt.Errorf("[WriteLockFile] rename file '%s' to '%s' failed: %v",
+			tmp, path, err))
+	}
+}
+
+func ReadLockFile(path string, sep string) (locks []RepoLock) {
+	file, err := os.Open(path)
+	if err != nil {
+		panic(fmt.Errorf("[ReadLockFile] open file '%s' failed: %v", path, err))
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := strings.Trim(scanner.Text(), "\n\r")
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(line, sep)
+		if len(fields) != 4 {
+			panic(fmt.Errorf("[ReadLockFile] file '%s' line '%s' can't be parsed",
+				path, line))
+		}
+		locks = append(locks, RepoLock{fields[0], fields[1], fields[2], fields[3]})
+	}
+	return
+}
+
+// Clone the repo if it's not existed, then checkout to the locked commit
+func SyncRepoToLock(
+	screen core.Screen,
+	hubPath string,
+	lock RepoLock,
+	listFileName string,
+	selfName string,
+	cmd core.ParsedCmd) (repoPath string, helpStr string) {
+
+	backend := GetHubBackend(lock.Addr)
+	repoPath = backend.RepoPath(hubPath, lock.Addr)
+	name := AddrDisplayName(lock.Addr)
+
+	if _, err := os.Stat(repoPath); err == nil {
+		commit, _ := backend.Commit(repoPath)
+		if commit == lock.Commit {
+			screen.Print(fmt.Sprintf("[%s] => %s (unchanged)\n", name, shortCommit(lock.Commit)))
+		} else {
+			screen.Print(fmt.Sprintf("[%s] => %s checkout %s\n", name, backend.Name(), shortCommit(lock.Commit)))
+		}
+	} else {
+		screen.Print(fmt.Sprintf("[%s] => %s clone, checkout %s\n", name, backend.Name(), shortCommit(lock.Commit)))
+	}
+	err := backend.Checkout(repoPath, lock.Addr, lock.Ref, lock.Commit, os.Stdout)
+	if err != nil {
+		panic(core.WrapCmdError(cmd, fmt.Errorf("sync repo '%s' failed: %v", name, err)))
+	}
+
+	helpStr, _, _ = ReadRepoListFromFile(selfName, filepath.Join(repoPath, listFileName))
+	return
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -7,25 +7,56 @@ import (
 	"github.com/pingcap/ticat/pkg/proto/meta_file"
 )
 
+// The repo list file of a repo, with its version and the dependencies on other repos
+type RepoMeta struct {
+	HelpStr  string
+	Version  string
+	Addrs    []string
+	HelpStrs []string
+	Depends  []RepoDepend
+}
+
+type RepoDepend struct {
+	// Could be 'addr@ref', the same as the sub-repos
+	Addr       string
+	Constraint string
+}
+
 func ReadRepoListFromFile(selfName string, path string) (helpStr string, addrs []string, helpStrs []string) {
+	meta := ReadRepoMetaFromFile(selfName, path)
+	return meta.HelpStr, meta.Addrs, meta.HelpStrs
+}
+
+func ReadRepoMetaFromFile(selfName string, path string) (repo RepoMeta) {
 	meta, err := meta_file.NewMetaFileEx(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return
 		}
-		panic(fmt.Errorf("[ReadRepoListFromFile] read mod meta file '%s' failed: %v", path, err))
+		panic(fmt.Errorf("[ReadRepoMetaFromFile] read mod meta file '%s' failed: %v", path, err))
 	}
-	helpStr = meta.Get("help")
+	repo.HelpStr = meta.Get("help")
+	repo.Version = meta.Get("version")
+
 	repos := meta.GetSection("repos")
 	if repos == nil {
 		repos = meta.GetSection("repo")
 	}
-	if repos == nil {
-		return
+	if repos != nil {
+		for _, addr := range repos.Keys() {
+			repo.Addrs = append(repo.Addrs, addr)
+			repo.HelpStrs = append(repo.HelpStrs, repos.Get(addr))
+		}
 	}
-	for _, addr := range repos.Keys() {
-		addrs = append(addrs, addr)
-		helpStrs = append(helpStrs, repos.Get(addr))
+
+	deps := meta.GetSection("depends")
+	if deps == nil {
+		deps = meta.GetSection("deps")
+	}
+	if deps != nil {
+		for _, addr := range deps.Keys() {
+			repo.Depends = append(repo.Depends, RepoDepend{addr, deps.Get(addr)})
+		}
 	}
 	return
 }
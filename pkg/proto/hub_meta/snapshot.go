@@ -0,0 +1,217 @@
+package hub_meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+)
+
+// The registered info of a command, for finding out what changed after updating
+type CmdSnapshot struct {
+	Type   string   `json:"type"`
+	Help   string   `json:"help"`
+	Cmd    string   `json:"cmd,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	EnvOps []string `json:"env-ops,omitempty"`
+	Flow   []string `json:"flow,omitempty"`
+}
+
+// Commands of a repo, the keys are cmd paths
+type RepoSnapshot map[string]CmdSnapshot
+
+type CmdChange struct {
+	Path     string   `json:"path"`
+	Fields   []string `json:"fields"`
+	FlowDiff []string `json:"flow-diff,omitempty"`
+}
+
+type RepoChanges struct {
+	Addr     string      `json:"addr"`
+	Ref      string      `json:"ref,omitempty"`
+	Added    []string    `json:"added,omitempty"`
+	Removed  []string    `json:"removed,omitempty"`
+	Modified []CmdChange `json:"modified,omitempty"`
+}
+
+func (self RepoChanges) IsEmpty() bool {
+	return len(self.Added) == 0 && len(self.Removed) == 0 && len(self.Modified) == 0
+}
+
+func (self RepoChanges) Name() string {
+	return JoinAddrAndRef(AddrDisplayName(self.Addr), self.Ref)
+}
+
+// The executable paths are recorded as relative paths to the repo root
+func SnapshotCmdTree(tree *core.CmdTree, root string) RepoSnapshot {
+	snapshot := RepoSnapshot{}
+	snapshotCmdTree(tree, root, snapshot)
+	return snapshot
+}
+
+func snapshotCmdTree(tree *core.CmdTree, root string, snapshot RepoSnapshot) {
+	cmd := tree.Cmd()
+	if cmd != nil && !tree.IsRoot() {
+		it := CmdSnapshot{Type: string(cmd.Type()), Help: cmd.Help(), Cmd: cmd.CmdLine()}
+		if rel, err := filepath.Rel(root, it.Cmd); len(it.Cmd) != 0 && err == nil {
+			it.Cmd = rel
+		}
+		args := cmd.Args()
+		for _, name := range args.Names() {
+			arg := name + " = " + args.DefVal(name)
+			abbrs := args.Abbrs(name)
+			if len(abbrs) > 1 {
+				arg += " (" + strings.Join(abbrs[1:], tree.Strs.AbbrsSep) + ")"
+			}
+			it.Args = append(it.Args, arg)
+		}
+		envOps := cmd.EnvOps()
+		for _, key := range envOps.EnvKeys() {
+			var ops []string
+			for _, op := range envOps.Ops(key) {
+				ops = append(ops, core.EnvOpStr(op))
+			}
+			it.EnvOps = append(it.EnvOps, key+" = "+strings.Join(ops, tree.Strs.EnvOpSep))
+		}
+		it.Flow = cmd.FlowStrs()
+		snapshot[tree.DisplayPath()] = it
+	}
+	for _, name := range tree.SubNames() {
+		snapshotCmdTree(tree.GetSub(name), root, snapshot)
+	}
+}
+
+func DiffRepoSnapshot(addr string, ref string, old RepoSnapshot, new RepoSnapshot) RepoChanges {
+	changes := RepoChanges{Addr: addr, Ref: ref}
+	for path, newCmd := range new {
+		oldCmd, ok := old[path]
+		if !ok {
+			changes.Added = append(changes.Added, path)
+			continue
+		}
+		change := diffCmdSnapshot(path, oldCmd, newCmd)
+		if len(change.Fields) != 0 {
+			changes.Modified = append(changes.Modified, change)
+		}
+	}
+	for path, _ := range old {
+		if _, ok := new[path]; !ok {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Slice(changes.Modified, func(i, j int) bool {
+		return changes.Modified[i].Path < changes.Modified[j].Path
+	})
+	return changes
+}
+
+func diffCmdSnapshot(path string, old CmdSnapshot, new CmdSnapshot) (change CmdChange) {
+	change.Path = path
+	if old.Type != new.Type {
+		change.Fields = append(change.Fields, "type")
+	}
+	if old.Help != new.Help {
+		change.Fields = append(change.Fields, "help")
+	}
+	if old.Cmd != new.Cmd {
+		change.Fields = append(change.Fields, "cmd")
+	}
+	if !equalStrs(old.Args, new.Args) {
+		change.Fields = append(change.Fields, "args")
+	}
+	if !equalStrs(old.EnvOps, new.EnvOps) {
+		change.Fields = append(change.Fields, "env-ops")
+	}
+	if !equalStrs(old.Flow, new.Flow) {
+		change.Fields = append(change.Fields, "flow")
+		change.FlowDiff = DiffLines(old.Flow, new.Flow)
+	}
+	return
+}
+
+// A simple LCS based line diff, the results are prefixed by '  ', '- ' or '+ '
+func DiffLines(old []string, new []string) (res []string) {
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		if old[i] == new[j] {
+			res = append(res, "  "+old[i])
+			i += 1
+			j += 1
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			res = append(res, "- "+old[i])
+			i += 1
+		} else {
+			res = append(res, "+ "+new[j])
+			j += 1
+		}
+	}
+	for ; i < len(old); i++ {
+		res = append(res, "- "+old[i])
+	}
+	for ; j < len(new); j++ {
+		res = append(res, "+ "+new[j])
+	}
+	return
+}
+
+func WriteRepoChanges(path string, changes []RepoChanges) {
+	data, err := json.MarshalIndent(changes, "", "    ")
+	if err != nil {
+		panic(fmt.Errorf("[WriteRepoChanges] encode json failed: %v", err))
+	}
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[WriteRepoChanges] write file '%s' failed: %v", path, err))
+	}
+}
+
+// Return false if there is no such file, means no update happened
+func ReadRepoChanges(path string) (changes []RepoChanges, exists bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return
+		}
+		panic(fmt.Errorf("[ReadRepoChanges] read file '%s' failed: %v", path, err))
+	}
+	err = json.Unmarshal(data, &changes)
+	if err != nil {
+		panic(fmt.Errorf("[ReadRepoChanges] decode file '%s' failed: %v", path, err))
+	}
+	return changes, true
+}
+
+func equalStrs(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, it := range a {
+		if it != b[i] {
+			return false
+		}
+	}
+	return true
+}
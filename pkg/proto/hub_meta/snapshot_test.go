@@ -0,0 +1,55 @@
+package hub_meta
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	test := func(old []string, new []string, expected ...string) {
+		res := DiffLines(old, new)
+		if strings.Join(res, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("diff %v %v: %v != %v", old, new, res, expected)
+		}
+	}
+
+	test(nil, nil)
+	test([]string{"a"}, []string{"a"}, "  a")
+	test([]string{"a", "b", "c"}, []string{"a", "c"}, "  a", "- b", "  c")
+	test([]string{"a", "c"}, []string{"a", "b", "c"}, "  a", "+ b", "  c")
+	test([]string{"a", "b"}, []string{"a", "x"}, "  a", "- b", "+ x")
+	test(nil, []string{"a"}, "+ a")
+}
+
+func TestDiffRepoSnapshot(t *testing.T) {
+	old := RepoSnapshot{
+		"a":   CmdSnapshot{Type: "flow", Help: "a", Flow: []string{"x : y"}},
+		"b":   CmdSnapshot{Type: "executable-file", Help: "b"},
+		"b.c": CmdSnapshot{Type: "executable-file", Help: "c", Args: []string{"n = 1"}},
+	}
+	new := RepoSnapshot{
+		"a":   CmdSnapshot{Type: "flow", Help: "a", Flow: []string{"x : z"}},
+		"b.c": CmdSnapshot{Type: "executable-file", Help: "c", Args: []string{"n = 2"}},
+		"d":   CmdSnapshot{Type: "no-executable", Help: "d"},
+	}
+	changes := DiffRepoSnapshot("addr", "", old, new)
+	if len(changes.Added) != 1 || changes.Added[0] != "d" {
+		t.Fatalf("wrong added: %v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "b" {
+		t.Fatalf("wrong removed: %v", changes.Removed)
+	}
+	if len(changes.Modified) != 2 {
+		t.Fatalf("wrong modified: %v", changes.Modified)
+	}
+	if changes.Modified[0].Path != "a" || strings.Join(changes.Modified[0].Fields, ",") != "flow" ||
+		len(changes.Modified[0].FlowDiff) != 2 {
+		t.Fatalf("wrong modified: %v", changes.Modified[0])
+	}
+	if changes.Modified[1].Path != "b.c" || strings.Join(changes.Modified[1].Fields, ",") != "args" {
+		t.Fatalf("wrong modified: %v", changes.Modified[1])
+	}
+	if DiffRepoSnapshot("addr", "", new, new).IsEmpty() != true {
+		t.Fatalf("same snapshots should have no changes")
+	}
+}
@@ -0,0 +1,216 @@
+package hub_meta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The allowlist of repos, and the commits of untrusted repos which are reviewed (confirmed) by users
+type TrustList struct {
+	// Addrs or owners like 'innerr/*'
+	Allows []string
+	// Addr => commit
+	Revieweds map[string]string
+}
+
+func NewTrustList() *TrustList {
+	return &TrustList{nil, map[string]string{}}
+}
+
+func (self *TrustList) IsAllowed(addr string) bool {
+	return len(self.MatchedAllow(addr)) != 0
+}
+
+// Return the allowlist entry matched the addr, empty if not matched
+func (self *TrustList) MatchedAllow(addr string) string {
+	normalized := NormalizeRepoAddr(addr)
+	for _, allow := range self.Allows {
+		if strings.HasSuffix(allow, "*") {
+			prefix := strings.TrimSuffix(NormalizeRepoAddr(allow), "*")
+			if strings.HasPrefix(normalized, prefix) {
+				return allow
+			}
+		} else if NormalizeRepoAddr(allow) == normalized {
+			return allow
+		}
+	}
+	return ""
+}
+
+// Return false if it's already in the list
+func (self *TrustList) Allow(addrOrOwner string) bool {
+	for _, allow := range self.Allows {
+		if allow == addrOrOwner {
+			return false
+		}
+	}
+	self.Allows = append(self.Allows, addrOrOwner)
+	return true
+}
+
+// Remove the allowlist entries and the review records matched the find-str
+func (self *TrustList) Remove(findStr string) (removeds []string) {
+	var allows []string
+	for _, allow := range self.Allows {
+		if strings.Index(allow, findStr) >= 0 {
+			removeds = append(removeds, allow)
+		} else {
+			allows = append(allows, allow)
+		}
+	}
+	self.Allows = allows
+	for addr, _ := range self.Revieweds {
+		if strings.Index(addr, findStr) >= 0 {
+			delete(self.Revieweds, addr)
+			removeds = append(removeds, addr)
+		}
+	}
+	return
+}
+
+func (self *TrustList) IsReviewed(addr string, commit string) bool {
+	reviewed, ok := self.Revieweds[addr]
+	return ok && len(commit) != 0 && reviewed == commit
+}
+
+func (self *TrustList) SetReviewed(addr string, commit string) {
+	self.Revieweds[addr] = commit
+}
+
+// Record the current commits of the repos as reviewed, local dirs are skipped
+func (self *TrustList) ReviewRepos(infos []RepoInfo) (count int) {
+	for _, info := range infos {
+		if info.IsLocal() {
+			continue
+		}
+		commit, err := GetRepoCommit(info.Addr, info.Path)
+		if err != nil || len(commit) == 0 {
+			continue
+		}
+		self.SetReviewed(info.Addr, commit)
+		count += 1
+	}
+	return
+}
+
+// Each line is 'allow <addr-or-owner>' or 'reviewed <addr> <commit>', fields are seperated by 'sep'
+func WriteTrustFile(path string, list *TrustList, sep string) {
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		panic(fmt.Errorf("[WriteTrustFile] open file '%s' failed: %v", tmp, err))
+	}
+	defer file.Close()
+
+	for _, allow := range list.Allows {
+		_, err = fmt.Fprintf(file, "allow%s%s\n", sep, allow)
+		if err != nil {
+			panic(fmt.Errorf("[WriteTrustFile] write file '%s' failed: %v", tmp, err))
+		}
+	}
+	var addrs []string
+	for addr, _ := range list.Revieweds {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		_, err = fmt.Fprintf(file, "reviewed%s%s%s%s\n", sep, addr, sep, list.Revieweds[addr])
+		if err != nil {
+			panic(fmt.Errorf("[WriteTrustFile] write file '%s' failed: %v", tmp, err))
+		}
+	}
+	file.Close()
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		panic(fmt.Errorf("[WriteTrustFile] rename file '%s' to '%s' failed: %v",
+			tmp, path, err))
+	}
+}
+
+func ReadTrustFile(path string, sep string) *TrustList {
+	list := NewTrustList()
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return list
+		}
+		panic(fmt.Errorf("[ReadTrustFile] open file '%s' failed: %v", path, err))
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := strings.Trim(scanner.Text(), "\n\r")
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(line, sep)
+		if fields[0] == "allow" && len(fields) == 2 {
+			list.Allows = append(list.Allows, fields[1])
+		} else if fields[0] == "reviewed" && len(fields) == 3 {
+			list.Revieweds[fields[1]] = fields[2]
+		} else {
+			panic(fmt.Errorf("[ReadTrustFile] file '%s' line '%s' can't be parsed", path, line))
+		}
+	}
+	return list
+}
+
+// Check the signature of the current commit, or the tag if the ref is a tag,
+// return the key which signed it if the key is in the configured list
+func VerifyRepoSignature(repoPath string, ref string, keys []string) (signer string, ok bool) {
+	if len(keys) == 0 {
+		return
+	}
+	var outputs []string
+	out, err := gitOutput(repoPath, "log", "-1", "--format=%G?%n%GF%n%GP", "HEAD")
+	if err == nil {
+		lines := strings.Split(out, "\n")
+		// 'G' means a good signature, 'U' means good but the trust level of the key is unknown
+		if len(lines) > 1 && (lines[0] == "G" || lines[0] == "U") {
+			outputs = append(outputs, lines[1:]...)
+		}
+	}
+	if len(ref) != 0 {
+		out, err = gitStderr(repoPath, "verify-tag", "--raw", ref)
+		if err == nil {
+			for _, line := range strings.Split(out, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) > 2 && fields[1] == "VALIDSIG" {
+					outputs = append(outputs, fields[2])
+				}
+			}
+		}
+	}
+	for _, fingerprint := range outputs {
+		if len(fingerprint) == 0 {
+			continue
+		}
+		for _, key := range keys {
+			key = strings.ToUpper(strings.TrimSpace(key))
+			if len(key) != 0 && strings.HasSuffix(strings.ToUpper(fingerprint), key) {
+				return key, true
+			}
+		}
+	}
+	return
+}
+
+// 'git verify-tag --raw' writes the status to stderr
+func gitStderr(dir string, args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	err := c.Run()
+	return strings.TrimSpace(stderr.String()), err
+}
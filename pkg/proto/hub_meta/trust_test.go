@@ -0,0 +1,81 @@
+package hub_meta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrustListMatchedAllow(t *testing.T) {
+	list := NewTrustList()
+	list.Allow("innerr/tidb.ticat")
+	list.Allow("pingcap/*")
+
+	test := func(addr string, expected string) {
+		if allow := list.MatchedAllow(addr); allow != expected {
+			t.Fatalf("allow of '%s': '%s' != '%s'", addr, allow, expected)
+		}
+	}
+
+	test("innerr/tidb.ticat", "innerr/tidb.ticat")
+	test("git@github.com:innerr/tidb.ticat", "innerr/tidb.ticat")
+	test("innerr/tidb.ticat.x", "")
+	test("innerr/marsh.ticat", "")
+	test("pingcap/ticat", "pingcap/*")
+	test("git@github.com:pingcap/tiup.ticat", "pingcap/*")
+	test("pingcapx/ticat", "")
+}
+
+func TestTrustFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trust-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "repos.trust")
+
+	list := ReadTrustFile(path, "\t")
+	if len(list.Allows) != 0 || len(list.Revieweds) != 0 {
+		t.Fatalf("should be empty if file not exists")
+	}
+	list.Allow("innerr/*")
+	list.SetReviewed("a/x.ticat", "c1")
+	list.SetReviewed("a/y.ticat", "c2")
+	WriteTrustFile(path, list, "\t")
+
+	list = ReadTrustFile(path, "\t")
+	if len(list.Allows) != 1 || !list.IsReviewed("a/x.ticat", "c1") || list.IsReviewed("a/y.ticat", "c1") {
+		t.Fatalf("wrong trust list: %v", list)
+	}
+	removeds := list.Remove("x.ticat")
+	if len(removeds) != 1 || len(list.Revieweds) != 1 {
+		t.Fatalf("wrong removed: %v", removeds)
+	}
+}
+
+func TestTrustListReviewRepos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trust-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repoPath := filepath.Join(dir, "x.ticat")
+	os.MkdirAll(repoPath, os.ModePerm)
+	ioutil.WriteFile(filepath.Join(repoPath, "hub.ticat"), []byte("help = x\n"), 0644)
+
+	addr := "dir:" + repoPath
+	infos := []RepoInfo{
+		{Addr: addr, Path: repoPath, OnOff: "on"},
+		{Addr: "", Path: dir, OnOff: "on"},
+		{Addr: "dir:/not/existed", Path: "/not/existed", OnOff: "on"},
+	}
+	list := NewTrustList()
+	if count := list.ReviewRepos(infos); count != 1 || len(list.Revieweds) != 1 {
+		t.Fatalf("only the repos with commits should be reviewed: %v", list.Revieweds)
+	}
+	commit, _ := GetRepoCommit(addr, repoPath)
+	if !list.IsReviewed(addr, commit) {
+		t.Fatalf("the current commit should be reviewed: %v", list.Revieweds)
+	}
+}
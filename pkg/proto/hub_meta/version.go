@@ -0,0 +1,172 @@
+package hub_meta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A semver-like version: 'major.minor.patch[-pre]', the missing parts are zeros
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string
+}
+
+func ParseVersion(str string) (ver Version, err error) {
+	str = strings.TrimSpace(str)
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "v"), "V")
+	if i := strings.Index(str, "-"); i >= 0 {
+		ver.Pre = str[i+1:]
+		str = str[:i]
+	}
+	fields := strings.Split(str, ".")
+	if len(str) == 0 || len(fields) > 3 {
+		return ver, fmt.Errorf("bad version format '%s'", str)
+	}
+	nums := []*int{&ver.Major, &ver.Minor, &ver.Patch}
+	for i, field := range fields {
+		*nums[i], err = strconv.Atoi(field)
+		if err != nil || *nums[i] < 0 {
+			return ver, fmt.Errorf("bad version format '%s'", str)
+		}
+	}
+	return ver, nil
+}
+
+// A version with pre-release tag is lower than the one without
+func (self Version) Compare(other Version) int {
+	diffs := []int{self.Major - other.Major, self.Minor - other.Minor, self.Patch - other.Patch}
+	for _, diff := range diffs {
+		if diff != 0 {
+			return diff
+		}
+	}
+	if self.Pre == other.Pre {
+		return 0
+	}
+	if len(self.Pre) == 0 {
+		return 1
+	}
+	if len(other.Pre) == 0 {
+		return -1
+	}
+	return strings.Compare(self.Pre, other.Pre)
+}
+
+func (self Version) String() string {
+	str := fmt.Sprintf("%d.%d.%d", self.Major, self.Minor, self.Patch)
+	if len(self.Pre) != 0 {
+		str += "-" + self.Pre
+	}
+	return str
+}
+
+type versionCond struct {
+	op  string
+	ver Version
+}
+
+func (self versionCond) match(ver Version) bool {
+	cmp := ver.Compare(self.ver)
+	switch self.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// Conditions seperated by ',' are all required, empty or '*' means any version.
+// Supported: '=1.2.3' '1.2.3' '!=1.2.3' '>1.2' '>=1.2' '<2' '<=2.1' '^1.2' '~1.2',
+// '^1.2' means '>=1.2.0, <2.0.0', '~1.2' means '>=1.2.0, <1.3.0'
+type VersionConstraint struct {
+	raw   string
+	conds []versionCond
+}
+
+func ParseVersionConstraint(str string) (VersionConstraint, error) {
+	constraint := VersionConstraint{raw: strings.TrimSpace(str)}
+	if len(constraint.raw) == 0 || constraint.raw == "*" {
+		return constraint, nil
+	}
+	for _, it := range strings.Split(constraint.raw, ",") {
+		it = strings.TrimSpace(it)
+		op := ""
+		for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(it, prefix) {
+				op = prefix
+				break
+			}
+		}
+		ver, err := ParseVersion(it[len(op):])
+		if err != nil {
+			return constraint, fmt.Errorf("bad version constraint '%s': %v", constraint.raw, err)
+		}
+		switch op {
+		case "":
+			constraint.conds = append(constraint.conds, versionCond{"=", ver})
+		case "^":
+			constraint.conds = append(constraint.conds, versionCond{">=", ver},
+				versionCond{"<", Version{ver.Major + 1, 0, 0, ""}})
+		case "~":
+			constraint.conds = append(constraint.conds, versionCond{">=", ver},
+				versionCond{"<", Version{ver.Major, ver.Minor + 1, 0, ""}})
+		default:
+			constraint.conds = append(constraint.conds, versionCond{op, ver})
+		}
+	}
+	return constraint, nil
+}
+
+func (self VersionConstraint) Match(ver Version) bool {
+	for _, cond := range self.conds {
+		if !cond.match(ver) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self VersionConstraint) String() string {
+	if len(self.raw) == 0 {
+		return "*"
+	}
+	return self.raw
+}
+
+// Check if there is a version could match all constraints,
+// only the versions on the boundaries need to be tried
+func VersionConstraintsCompatible(constraints []VersionConstraint) bool {
+	candidates := []Version{{}}
+	for _, constraint := range constraints {
+		for _, cond := range constraint.conds {
+			ver := cond.ver
+			candidates = append(candidates, ver,
+				Version{ver.Major, ver.Minor, ver.Patch + 1, ""})
+		}
+	}
+	for _, candidate := range candidates {
+		matched := true
+		for _, constraint := range constraints {
+			if !constraint.Match(candidate) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package hub_meta
+
+import (
+	"testing"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	test := func(constraint string, version string, expected bool) {
+		c, err := ParseVersionConstraint(constraint)
+		if err != nil {
+			t.Fatalf("parse constraint '%s' failed: %v", constraint, err)
+		}
+		v, err := ParseVersion(version)
+		if err != nil {
+			t.Fatalf("parse version '%s' failed: %v", version, err)
+		}
+		if c.Match(v) != expected {
+			t.Fatalf("'%s' match '%s': %v != %v", constraint, version, !expected, expected)
+		}
+	}
+
+	test("", "1.0", true)
+	test("*", "0.0.1", true)
+	test("1.2", "1.2.0", true)
+	test("=1.2.1", "v1.2.1", true)
+	test("1.2", "1.2.1", false)
+	test(">=1.2", "1.10", true)
+	test(">1.2", "1.2", false)
+	test("<2", "1.99.99", true)
+	test(">=1.0, <2.0", "2.0.0", false)
+	test("^1.2", "1.9.0", true)
+	test("^1.2", "2.0.0", false)
+	test("^1.2", "1.1.9", false)
+	test("~1.2", "1.2.9", true)
+	test("~1.2", "1.3.0", false)
+	test("!=1.0", "1.0.1", true)
+	test(">=1.0", "1.0.0-rc1", false)
+}
+
+func TestVersionConstraintsCompatible(t *testing.T) {
+	test := func(expected bool, strs ...string) {
+		var constraints []VersionConstraint
+		for _, str := range strs {
+			c, err := ParseVersionConstraint(str)
+			if err != nil {
+				t.Fatalf("parse constraint '%s' failed: %v", str, err)
+			}
+			constraints = append(constraints, c)
+		}
+		if VersionConstraintsCompatible(constraints) != expected {
+			t.Fatalf("compatible %v: %v != %v", strs, !expected, expected)
+		}
+	}
+
+	test(true, "^1.0", ">=1.2")
+	test(true, "*", "1.3")
+	test(true, ">1.2", "<1.3")
+	test(false, "^1.0", ">=2.0")
+	test(false, "1.2", "1.3")
+	test(false, "~1.2", "^1.3")
+}
+
+func TestParseBadVersion(t *testing.T) {
+	for _, str := range []string{"", "a.b", "1.2.3.4", "-1"} {
+		if _, err := ParseVersion(str); err == nil {
+			t.Fatalf("version '%s' should be invalid", str)
+		}
+	}
+	if _, err := ParseVersionConstraint(">=x"); err == nil {
+		t.Fatalf("constraint '>=x' should be invalid")
+	}
+}
@@ -1,7 +1,6 @@
 package meta_file
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -86,34 +85,59 @@ func (self *MetaFile) GetAll() SectionMap {
 	return self.sections
 }
 
+// The comments and blank lines are kept with the key (or the section end) after them,
+// the original lines of a key are saved back if its value is not changed
 func (self *MetaFile) parse(data []byte) {
 	var sectionName string
-	section := NewSection()
-	self.sections[sectionName] = section
+	section := self.NewOrGetSection(sectionName)
 
+	var pending []string
+	var multiLine *rawKeyVal
 	var multiLineKey string
 	var multiLineValue []string
+	var innerComments []string
+
+	finishMultiLine := func() {
+		multiLine.val = strings.Join(multiLineValue, self.lineSep)
+		section.Set(multiLineKey, multiLine.val)
+		section.raws[multiLineKey] = multiLine
+		multiLine = nil
+		multiLineKey = ""
+		multiLineValue = nil
+		innerComments = nil
+	}
 
-	tryAppendMultiLine := func(line string) bool {
-		if len(multiLineKey) == 0 {
+	tryAppendMultiLine := func(raw string, line string) bool {
+		if multiLine == nil {
 			return false
 		}
+		multiLine.lines = append(multiLine.lines, raw)
+		// Comments and blank lines could be in a multi-line value, they are not part of the value
+		if len(line) == 0 || line[0] == CommentPrefix {
+			if len(line) != 0 {
+				innerComments = append(innerComments, line)
+			}
+			return true
+		}
 		multiLineFinish := false
 		if line[len(line)-1] == MultiLineBreaker {
 			line = line[:len(line)-1]
 		} else {
 			multiLineFinish = true
 		}
-		multiLineValue = append(multiLineValue, strings.TrimSpace(line))
+		line = strings.TrimSpace(line)
+		if len(innerComments) != 0 {
+			multiLine.inner[line] = append(multiLine.inner[line], innerComments...)
+			innerComments = nil
+		}
+		multiLineValue = append(multiLineValue, line)
 		if multiLineFinish {
-			section.Set(multiLineKey, strings.Join(multiLineValue, "\n"))
-			multiLineKey = ""
-			multiLineValue = nil
+			finishMultiLine()
 		}
 		return true
 	}
 
-	checkMultiLineStart := func(k string, v string) bool {
+	checkMultiLineStart := func(k string, v string, entry *rawKeyVal) bool {
 		if len(v) == 0 {
 			return false
 		}
@@ -121,6 +145,7 @@ func (self *MetaFile) parse(data []byte) {
 			return false
 		}
 		v = strings.TrimSpace(v[:len(v)-1])
+		multiLine = entry
 		multiLineKey = k
 		if len(v) != 0 {
 			multiLineValue = append(multiLineValue, v)
@@ -129,38 +154,52 @@ func (self *MetaFile) parse(data []byte) {
 	}
 
 	// TODO: convert to string too many times
-	lines := bytes.Split(data, []byte(self.lineSep))
-	for _, line := range lines {
-		line = bytes.TrimSpace(line)
+	lines := strings.Split(string(data), self.lineSep)
+	if len(lines) != 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for _, raw := range lines {
+		raw = strings.TrimRight(raw, "\r")
+		line := strings.TrimSpace(raw)
 		size := len(line)
-		if size == 0 {
+		if tryAppendMultiLine(raw, line) {
 			continue
 		}
-		if tryAppendMultiLine(string(line)) {
-			continue
-		}
-		if line[0] == CommentPrefix {
+		if size == 0 || line[0] == CommentPrefix {
+			pending = append(pending, raw)
 			continue
 		}
 		if line[0] == SectionBracketLeft && line[size-1] == SectionBracketRight {
-			sectionName = string(line[1 : size-1])
+			section.tail = pending
+			pending = nil
+			sectionName = line[1 : size-1]
 			section = NewSection()
+			if _, ok := self.sections[sectionName]; !ok {
+				self.orderedKeys = append(self.orderedKeys, sectionName)
+			}
 			self.sections[sectionName] = section
 			continue
 		}
 
-		pos := bytes.Index(line, []byte(self.kvSep))
+		pos := strings.Index(line, self.kvSep)
 		if pos < 0 {
 			panic(fmt.Errorf("[MetaFile.parse] bad kv format: %s", line))
 		}
 
-		k := bytes.TrimSpace(line[0:pos])
-		v := bytes.TrimSpace(line[pos+len(self.kvSep):])
-		if checkMultiLineStart(string(k), string(v)) {
+		k := strings.TrimSpace(line[0:pos])
+		v := strings.TrimSpace(line[pos+len(self.kvSep):])
+		entry := &rawKeyVal{pending, []string{raw}, v, map[string][]string{}}
+		pending = nil
+		if checkMultiLineStart(k, v, entry) {
 			continue
 		}
-		section.Set(string(k), string(v))
+		section.Set(k, v)
+		section.raws[k] = entry
 	}
+	if multiLine != nil {
+		finishMultiLine()
+	}
+	section.tail = pending
 }
 
 func (self *MetaFile) Save() {
@@ -174,39 +213,91 @@ func (self *MetaFile) Save() {
 }
 
 func (self *MetaFile) save(w io.Writer) {
-	saveKey := func(key string, val string) (multiLine bool) {
+	lastBlank := true
+	write := func(line string) {
+		fmt.Fprintf(w, "%s\n", line)
+		lastBlank = len(strings.TrimSpace(line)) == 0
+	}
+
+	// A multi-line value starts from the next line, so the lines are aligned
+	saveKey := func(key string, val string, raw *rawKeyVal) (multiLine bool) {
 		lines := strings.Split(val, self.lineSep)
 		if len(lines) == 1 {
-			fmt.Fprintf(w, "%s %s %s\n", key, self.kvSep, val)
+			write(fmt.Sprintf("%s %s %s", key, self.kvSep, val))
 			return false
-		} else {
-			fmt.Fprintf(w, "%s %s %s %c\n", key, self.kvSep, lines[0], MultiLineBreaker)
-			lines = lines[1:]
-			for i, line := range lines {
-				if i != len(lines)-1 {
-					fmt.Fprintf(w, "    %s %c\n", line, MultiLineBreaker)
-				} else {
-					fmt.Fprintf(w, "    %s\n", line)
+		}
+		write(fmt.Sprintf("%s %s %c", key, self.kvSep, MultiLineBreaker))
+		for i, line := range lines {
+			if raw != nil {
+				for _, comment := range raw.inner[line] {
+					write("    " + comment)
 				}
 			}
-			return true
+			if i != len(lines)-1 {
+				write(fmt.Sprintf("    %s %c", line, MultiLineBreaker))
+			} else {
+				write("    " + line)
+			}
 		}
+		return true
 	}
 
 	for i, name := range self.orderedKeys {
 		section := self.sections[name]
-		keys := section.Keys()
+		if section == nil {
+			continue
+		}
 		if len(name) != 0 {
-			fmt.Fprintf(w, "%c%s%c\n", SectionBracketLeft, name, SectionBracketRight)
+			if i != 0 && !lastBlank && !section.parsed() {
+				write("")
+			}
+			write(fmt.Sprintf("%c%s%c", SectionBracketLeft, name, SectionBracketRight))
 		}
-		for j, key := range keys {
-			multiLine := saveKey(key, section.Get(key))
-			if multiLine && j != len(keys)-1 {
-				fmt.Fprintf(w, "\n")
+		lastMultiLine := false
+		for _, key := range section.Keys() {
+			val := section.pairs[key]
+			raw := section.raws[key]
+			if raw != nil {
+				for _, line := range raw.leading {
+					write(line)
+				}
+				// Only the parsed keys have original lines, others may just carry comments of a deleted key
+				if raw.lines != nil && (val == raw.val || val == strings.Trim(raw.val, ValTrimChars)) {
+					for _, line := range raw.lines {
+						write(line)
+					}
+					lastMultiLine = false
+					continue
+				}
+			} else if lastMultiLine && !lastBlank {
+				write("")
 			}
+			lastMultiLine = saveKey(key, val, raw)
 		}
-		if i != len(self.orderedKeys)-1 {
-			fmt.Fprintf(w, "\n")
+		for _, line := range section.tail {
+			write(line)
+		}
+		if i != len(self.orderedKeys)-1 && !lastBlank && !self.nextParsed(i) {
+			write("")
+		}
+	}
+}
+
+func (self *MetaFile) nextParsed(i int) bool {
+	section := self.sections[self.orderedKeys[i+1]]
+	return section != nil && section.parsed()
+}
+
+// Remove a section, the comments before it (at the end of the previous section) are kept
+func (self *MetaFile) DeleteSection(name string) {
+	if _, ok := self.sections[name]; !ok {
+		return
+	}
+	delete(self.sections, name)
+	for i, it := range self.orderedKeys {
+		if it == name {
+			self.orderedKeys = append(self.orderedKeys[:i], self.orderedKeys[i+1:]...)
+			break
 		}
 	}
 }
@@ -216,15 +307,34 @@ type SectionMap map[string]*Section
 type Section struct {
 	pairs       map[string]string
 	orderedKeys []string
+	// The parsed content, for saving back with comments and the original format
+	raws map[string]*rawKeyVal
+	tail []string
+}
+
+// The original lines of a key-value in a file
+type rawKeyVal struct {
+	// The comments and blank lines before the key
+	leading []string
+	lines   []string
+	val     string
+	// The comments in a multi-line value, by the value line after them
+	inner map[string][]string
 }
 
 func NewSection() *Section {
 	return &Section{
 		map[string]string{},
 		[]string{},
+		map[string]*rawKeyVal{},
+		nil,
 	}
 }
 
+func (self *Section) parsed() bool {
+	return len(self.raws) != 0 || len(self.tail) != 0
+}
+
 func (self *Section) Get(key string) string {
 	val, _ := self.pairs[key]
 	return strings.Trim(val, ValTrimChars)
@@ -259,6 +369,44 @@ func (self *Section) Set(key string, val string) {
 	return
 }
 
+// The comments before the removed key are kept, they are moved to the next key
+func (self *Section) Delete(key string) {
+	if _, ok := self.pairs[key]; !ok {
+		return
+	}
+	delete(self.pairs, key)
+	raw := self.raws[key]
+	delete(self.raws, key)
+	for i, it := range self.orderedKeys {
+		if it != key {
+			continue
+		}
+		self.orderedKeys = append(self.orderedKeys[:i], self.orderedKeys[i+1:]...)
+		if raw == nil || !hasComment(raw.leading) {
+			break
+		}
+		if i < len(self.orderedKeys) {
+			next := self.orderedKeys[i]
+			if self.raws[next] == nil {
+				self.raws[next] = &rawKeyVal{}
+			}
+			self.raws[next].leading = append(raw.leading, self.raws[next].leading...)
+		} else {
+			self.tail = append(raw.leading, self.tail...)
+		}
+		break
+	}
+}
+
+func hasComment(lines []string) bool {
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (self *Section) SetMultiLineVal(key string, val []string) {
 	self.Set(key, strings.Join(val, LineSep))
 }
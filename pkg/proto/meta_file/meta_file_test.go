@@ -0,0 +1,97 @@
+package meta_file
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func parseMeta(text string) *MetaFile {
+	meta := CreateMetaFile("")
+	meta.parse([]byte(text))
+	return meta
+}
+
+func saveMeta(meta *MetaFile) string {
+	w := bytes.NewBuffer(nil)
+	meta.save(w)
+	return w.String()
+}
+
+const testFlowFile = `# hand-edited flow
+help = run w and r
+
+flow = \
+    {x=1}w \
+    # read back
+    : r \
+
+    : dummy
+
+# params
+[args]
+n|N = 3
+`
+
+func TestRoundTrip(t *testing.T) {
+	meta := parseMeta(testFlowFile)
+	if out := saveMeta(meta); out != testFlowFile {
+		t.Fatalf("unchanged file not kept:\n%s", out)
+	}
+
+	flow := meta.GetGlobalSection().GetMultiLineVal("flow", false)
+	if strings.Join(flow, "|") != "{x=1}w|: r|: dummy" {
+		t.Fatalf("wrong multi-line val: %v", flow)
+	}
+	if meta.SectionGet("args", "n|N") != "3" {
+		t.Fatalf("wrong section val")
+	}
+
+	meta.GetGlobalSection().Set("help", "new help")
+	meta.GetSection("args").Set("n|N", "5")
+	out := saveMeta(meta)
+	expected := strings.Replace(testFlowFile, "run w and r", "new help", 1)
+	expected = strings.Replace(expected, "n|N = 3", "n|N = 5", 1)
+	if out != expected {
+		t.Fatalf("comments or format not kept after changes:\n%s", out)
+	}
+}
+
+func TestSetAndDelete(t *testing.T) {
+	meta := parseMeta(testFlowFile)
+	meta.GetGlobalSection().Delete("help")
+	meta.GetGlobalSection().SetMultiLineVal("flow", []string{"w", ": r"})
+	meta.DeleteSection("args")
+	meta.NewOrGetSection("args.help").Set("n", "threads")
+
+	out := saveMeta(meta)
+	meta = parseMeta(out)
+	if len(meta.GetGlobalSection().Get("help")) != 0 || meta.GetSection("args") != nil {
+		t.Fatalf("deleted key or section still exists:\n%s", out)
+	}
+	if !strings.HasPrefix(out, "# hand-edited flow\n") {
+		t.Fatalf("leading comment not kept:\n%s", out)
+	}
+	flow := meta.GetGlobalSection().GetMultiLineVal("flow", false)
+	if strings.Join(flow, "|") != "w|: r" {
+		t.Fatalf("wrong multi-line val: %v\n%s", flow, out)
+	}
+	if meta.SectionGet("args.help", "n") != "threads" {
+		t.Fatalf("new section not saved:\n%s", out)
+	}
+}
+
+func TestDeleteMovesCommentToNewKey(t *testing.T) {
+	meta := parseMeta("# comment of a\na = 1\n")
+	meta.GetGlobalSection().Set("b", "")
+	meta.GetGlobalSection().Delete("a")
+
+	out := saveMeta(meta)
+	if out != "# comment of a\nb = \n" {
+		t.Fatalf("wrong saved content:\n%s", out)
+	}
+	meta = parseMeta(out)
+	if strings.Join(meta.GetGlobalSection().Keys(), ",") != "b" {
+		t.Fatalf("new key with empty value is dropped:\n%s", out)
+	}
+}
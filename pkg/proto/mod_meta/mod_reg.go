@@ -16,35 +16,66 @@ func RegMod(
 	executablePath string,
 	isDir bool,
 	isFlow bool,
+	mountPath []string,
 	cmdPath []string,
 	abbrsSep string,
 	envPathSep string,
-	source string) {
+	source string,
+	priority int) {
 
+	var mod *core.CmdTree
+	var shadowed *core.Cmd
 	defer func() {
 		if err := recover(); err != nil {
+			if shadowed != nil {
+				mod.RestoreCmd(shadowed)
+			}
 			cc.TolerableErrs.OnErr(err, source, metaPath, "module loading failed")
 		}
 	}()
 
-	mod := cc.Cmds.GetOrAddSub(cmdPath...)
+	mount := cc.Cmds.GetOrAddSub(mountPath...)
+	mod = mount.GetOrAddSub(cmdPath...)
 	meta := meta_file.NewMetaFile(metaPath)
 
+	shadowed = mod.ShadowCmd(source, priority)
 	cmd := regMod(meta, mod, executablePath, isDir)
-	cmd.SetSource(source).SetMetaFile(metaPath)
+	cmd.SetSource(source).SetSourcePriority(priority).SetMountPath(mountPath).SetMetaFile(metaPath)
+	if shadowed != nil {
+		cmd.SetShadowed(shadowed)
+		cc.TolerableErrs.OnShadowed(core.ShadowedCmd{mod.Path(), metaPath, source, priority,
+			shadowed.Source(), shadowed.SourcePriority()})
+	}
 
 	// Reg by isFlow, not 'cmd.Type()'
 	if isFlow {
-		regFlowAbbrs(meta, cc.Cmds, cmdPath)
+		regFlowAbbrs(meta, mount, cmdPath)
 	} else {
 		regModAbbrs(meta, mod)
 	}
 
+	regProtocol(meta, cmd)
 	regArgs(meta, cmd, abbrsSep)
 	regDeps(meta, cmd)
 	regEnvOps(cc.EnvAbbrs, meta, cmd, abbrsSep, envPathSep)
 	regVal2Env(cc.EnvAbbrs, meta, cmd, abbrsSep, envPathSep)
 	regArg2Env(cc.EnvAbbrs, meta, cmd, abbrsSep, envPathSep)
+	regStdout2Env(cc.EnvAbbrs, meta, cmd, abbrsSep, envPathSep)
+}
+
+// Called once after all mods are loaded. The flow closing a cycle is unregistered,
+// the one it shadowed is put back, then check again because that one may be in a cycle too
+func UnregFlowCycles(cc *core.Cli) {
+	for {
+		closers, cycles := core.FindFlowCycles(cc, cc.GlobalEnv)
+		if len(closers) == 0 {
+			return
+		}
+		for i, cmd := range closers {
+			cmd.Owner().RestoreCmd(cmd.Shadowed())
+			cc.TolerableErrs.OnErr(cycles[i], cmd.Source(), cmd.MetaFile(), "module loading failed")
+		}
+	}
 }
 
 func regMod(
@@ -144,6 +175,16 @@ func regFlowAbbrs(meta *meta_file.MetaFile, cmds *core.CmdTree, cmdPath []string
 	}
 }
 
+func regProtocol(meta *meta_file.MetaFile, cmd *core.Cmd) {
+	str := strings.ToLower(meta.Get("protocol"))
+	protocol, ok := core.ParseModProtocol(str)
+	if !ok {
+		panic(fmt.Errorf("[regProtocol] cmd '%s' has unknown protocol '%s' in '%s'",
+			cmd.Owner().DisplayPath(), str, meta.Path()))
+	}
+	cmd.SetProtocol(protocol)
+}
+
 func regArgs(meta *meta_file.MetaFile, cmd *core.Cmd, abbrsSep string) {
 	args := meta.GetSection("args")
 	if args == nil {
@@ -162,6 +203,17 @@ func regArgs(meta *meta_file.MetaFile, cmd *core.Cmd, abbrsSep string) {
 		}
 		cmd.AddArg(name, defVal, argAbbrs...)
 	}
+
+	helps := meta.GetSection("args.help")
+	if helps == nil {
+		helps = meta.GetSection("arg.help")
+	}
+	if helps == nil {
+		return
+	}
+	for _, name := range helps.Keys() {
+		cmd.SetArgHelp(strings.TrimSpace(name), helps.Get(name))
+	}
 }
 
 func regDeps(meta *meta_file.MetaFile, cmd *core.Cmd) {
@@ -249,6 +301,32 @@ func regArg2Env(
 	}
 }
 
+func regStdout2Env(
+	envAbbrs *core.EnvAbbrs,
+	meta *meta_file.MetaFile,
+	cmd *core.Cmd,
+	abbrsSep string,
+	envPathSep string) {
+
+	writes := meta.GetSection("env.from-stdout")
+	if writes == nil {
+		writes = meta.GetSection("stdout2env")
+	}
+	if writes == nil {
+		return
+	}
+
+	for _, envKey := range writes.Keys() {
+		pattern := writes.Get(envKey)
+		key := regEnvKeyAbbrs(envAbbrs, envKey, abbrsSep, envPathSep)
+		cmd.AddStdout2Env(key, pattern)
+		// The captured keys are written by this cmd, no need to declare them again in '[env]'
+		if len(cmd.EnvOps().Ops(key)) == 0 {
+			cmd.AddEnvOp(key, core.EnvOpTypeWrite)
+		}
+	}
+}
+
 func regEnvKeyAbbrs(
 	envAbbrs *core.EnvAbbrs,
 	envKeyWithAbbrs string,
@@ -0,0 +1,65 @@
+package mod_meta
+
+import (
+	"testing"
+
+	"github.com/pingcap/ticat/pkg/cli/core"
+	"github.com/pingcap/ticat/pkg/cli/parser"
+)
+
+func TestUnregFlowCycles(t *testing.T) {
+	tree := core.NewCmdTree(&core.CmdTreeStrs{"<root>", "<builtin>", ".", ".", "|", ":", "--", "=", ".", "\t",
+		"[[", "]]", "|"})
+	seqParser := parser.NewSequenceParser(":", []string{"http", "HTTP"}, []string{"/"})
+	envParser := parser.NewEnvParser(parser.Brackets{"{", "}"}, "\t\n\r ", "=", ".")
+	cmdParser := parser.NewCmdParser(envParser, ".", "./", "\t\n\r ", "<root>")
+	cc := &core.Cli{
+		GlobalEnv:     core.NewEnv(),
+		Cmds:          tree,
+		Parser:        parser.NewParser(seqParser, cmdParser),
+		EnvAbbrs:      core.NewEnvAbbrs("<root>"),
+		TolerableErrs: core.NewTolerableErrs(),
+	}
+
+	reg := func(name string, flow string, source string, priority int) *core.Cmd {
+		mod := tree.GetOrAddSub(name)
+		shadowed := mod.ShadowCmd(source, priority)
+		cmd := mod.RegFlowCmd([]string{flow}, "").SetSource(source).SetSourcePriority(priority)
+		if shadowed != nil {
+			cmd.SetShadowed(shadowed)
+		}
+		return cmd
+	}
+
+	// a -> b -> a, then the shadowed b is put back: a -> b -> c -> a
+	a := reg("a", "b", "x", 0)
+	b := reg("b", "c", "y", 0)
+	reg("b", "a", "z", 1)
+	reg("c", "a : d", "y", 0)
+	d := reg("d", "dummy", "y", 0)
+	tree.GetOrAddSub("dummy").RegEmptyCmd("")
+
+	UnregFlowCycles(cc)
+
+	errs := cc.TolerableErrs.Uncatalogeds
+	if len(errs) != 2 {
+		t.Fatalf("wrong cycles: %v", errs)
+	}
+	for i, source := range []string{"z", "y"} {
+		cycle, ok := errs[i].Err.(core.CmdFlowCycleDetected)
+		if !ok || errs[i].Source != source || len(cycle.Cycle) < 3 || cycle.Cycle[0].CmdPath != "a" {
+			t.Fatalf("wrong cycle: %v", errs[i])
+		}
+	}
+	if tree.GetSub("c").Cmd() != nil {
+		t.Fatalf("the flows closing cycles should be unregistered")
+	}
+	if tree.GetSub("a").Cmd() != a || tree.GetSub("b").Cmd() != b || tree.GetSub("d").Cmd() != d {
+		t.Fatalf("the flows not closing cycles should be kept")
+	}
+
+	UnregFlowCycles(cc)
+	if len(cc.TolerableErrs.Uncatalogeds) != 2 {
+		t.Fatalf("no more cycles should be found")
+	}
+}
@@ -0,0 +1,222 @@
+package session_meta
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pingcap/ticat/pkg/proto/meta_file"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusAborted   = "aborted"
+	StatusUnknown   = "unknown"
+)
+
+const timeFormat = time.RFC3339
+
+type SessionStatus struct {
+	Id       string
+	Dir      string
+	Pid      int
+	StartTs  time.Time
+	FinishTs time.Time
+	Status   string
+	Flow     string
+}
+
+// The id is used as the dir name, pid is not enough because it could be reused by the OS
+func NewSessionId(pid int, now time.Time) string {
+	return fmt.Sprintf("%s.%d", now.Format("20060102-150405"), pid)
+}
+
+func NewSessionStatus(root string, pid int, now time.Time, flow string) SessionStatus {
+	id := NewSessionId(pid, now)
+	return SessionStatus{id, filepath.Join(root, id), pid, now, time.Time{}, StatusRunning, flow}
+}
+
+func (self SessionStatus) IsAlive() bool {
+	if self.Pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(self.Pid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// A running session with a dead pid is aborted by signals or panics
+func (self SessionStatus) DisplayStatus() string {
+	if self.Status == StatusRunning && !self.IsAlive() {
+		return StatusAborted
+	}
+	return self.Status
+}
+
+func (self SessionStatus) LastActiveTs() time.Time {
+	if self.FinishTs.After(self.StartTs) {
+		return self.FinishTs
+	}
+	return self.StartTs
+}
+
+func SaveSessionStatus(status SessionStatus, statusFileName string) {
+	meta := meta_file.CreateMetaFile(filepath.Join(status.Dir, statusFileName))
+	section := meta.GetGlobalSection()
+	section.Set("pid", fmt.Sprintf("%d", status.Pid))
+	section.Set("status", status.Status)
+	section.Set("start", status.StartTs.Format(timeFormat))
+	if !status.FinishTs.IsZero() {
+		section.Set("finish", status.FinishTs.Format(timeFormat))
+	}
+	if len(status.Flow) != 0 {
+		section.Set("flow", status.Flow)
+	}
+	meta.Save()
+}
+
+// Dirs without status file are from old versions, the dir names are pids
+func LoadSessionStatus(dir string, statusFileName string) (status SessionStatus) {
+	status.Id = filepath.Base(dir)
+	status.Dir = dir
+	status.Status = StatusUnknown
+
+	meta, err := meta_file.NewMetaFileEx(filepath.Join(dir, statusFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			panic(fmt.Errorf("[LoadSessionStatus] read session status file in '%s' failed: %v",
+				dir, err))
+		}
+		status.Pid, _ = strconv.Atoi(status.Id)
+		info, err := os.Stat(dir)
+		if err == nil {
+			status.StartTs = info.ModTime()
+		}
+		return
+	}
+
+	section := meta.GetGlobalSection()
+	status.Pid, _ = strconv.Atoi(section.Get("pid"))
+	if val := section.Get("status"); len(val) != 0 {
+		status.Status = val
+	}
+	status.StartTs, _ = time.Parse(timeFormat, section.Get("start"))
+	status.FinishTs, _ = time.Parse(timeFormat, section.Get("finish"))
+	status.Flow = section.GetUnTrim("flow")
+	return
+}
+
+// Sorted by start time, the newest is the last one
+func ListSessions(root string, statusFileName string) (sessions []SessionStatus) {
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return
+		}
+		panic(fmt.Errorf("[ListSessions] read sessions' root path '%s' failed: %v", root, err))
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		sessions = append(sessions, LoadSessionStatus(filepath.Join(root, dir.Name()), statusFileName))
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].StartTs.Equal(sessions[j].StartTs) {
+			return sessions[i].Id < sessions[j].Id
+		}
+		return sessions[i].StartTs.Before(sessions[j].StartTs)
+	})
+	return
+}
+
+func FindSessions(sessions []SessionStatus, findStr string) (res []SessionStatus) {
+	for _, it := range sessions {
+		if it.Id == findStr {
+			return []SessionStatus{it}
+		}
+	}
+	for _, it := range sessions {
+		if strings.Index(it.Id, findStr) >= 0 {
+			res = append(res, it)
+		}
+	}
+	return
+}
+
+// Sessions with alive pids are always kept.
+// A dead session is removed when it's inactive longer than keepDur,
+// or when there are more than keepCount newer dead sessions. Zero means no limit.
+func SelectSessionsToGc(
+	sessions []SessionStatus,
+	keepDur time.Duration,
+	keepCount int,
+	now time.Time) (gcs []SessionStatus) {
+
+	kept := 0
+	for i := len(sessions) - 1; i >= 0; i-- {
+		it := sessions[i]
+		if it.IsAlive() {
+			continue
+		}
+		expired := keepDur > 0 && now.Sub(it.LastActiveTs()) > keepDur
+		overflow := keepCount > 0 && kept >= keepCount
+		if expired || overflow {
+			gcs = append(gcs, it)
+		} else {
+			kept += 1
+		}
+	}
+	return
+}
+
+func RemoveSession(session SessionStatus) error {
+	return os.RemoveAll(session.Dir)
+}
+
+// The retention policy of sessions, zero means no limit
+type GcPolicy struct {
+	KeepDur   time.Duration
+	KeepCount int
+}
+
+// The policy is defined by env 'sys.session.keep-duration' and 'sys.session.keep-count'
+func ParseGcPolicy(keepDur string, keepCount string) (policy GcPolicy, err error) {
+	if len(keepDur) != 0 {
+		policy.KeepDur, err = time.ParseDuration(keepDur)
+		if err != nil {
+			return policy, fmt.Errorf("bad format of env 'sys.session.keep-duration': %v", err)
+		}
+	}
+	if len(keepCount) != 0 {
+		policy.KeepCount, err = strconv.Atoi(keepCount)
+		if err != nil {
+			return policy, fmt.Errorf("bad format of env 'sys.session.keep-count': %v", err)
+		}
+	}
+	return
+}
+
+// Remove the sessions selected by the policy, stop at the first failure
+func GcSessions(
+	root string,
+	statusFileName string,
+	policy GcPolicy,
+	now time.Time) (removed []SessionStatus, err error) {
+
+	sessions := ListSessions(root, statusFileName)
+	for _, session := range SelectSessionsToGc(sessions, policy.KeepDur, policy.KeepCount, now) {
+		err = RemoveSession(session)
+		if err != nil {
+			return removed, fmt.Errorf("remove session '%s' failed: %v", session.Dir, err)
+		}
+		removed = append(removed, session)
+	}
+	return
+}
@@ -0,0 +1,112 @@
+package session_meta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSession(id string, pid int, start time.Time, finish time.Time) SessionStatus {
+	return SessionStatus{id, filepath.Join("/sessions", id), pid, start, finish, StatusSucceeded, ""}
+}
+
+func sessionIds(sessions []SessionStatus) string {
+	var ids []string
+	for _, it := range sessions {
+		ids = append(ids, it.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSelectSessionsToGc(t *testing.T) {
+	now := time.Now()
+	hour := time.Hour
+	alive := os.Getpid()
+	sessions := []SessionStatus{
+		newTestSession("a", 0, now.Add(-10*hour), now.Add(-9*hour)),
+		newTestSession("b", alive, now.Add(-8*hour), time.Time{}),
+		newTestSession("c", 0, now.Add(-5*hour), time.Time{}),
+		newTestSession("d", 0, now.Add(-2*hour), now.Add(-hour)),
+		newTestSession("e", 0, now.Add(-hour), now),
+	}
+
+	test := func(keepDur time.Duration, keepCount int, expected string) {
+		gcs := SelectSessionsToGc(sessions, keepDur, keepCount, now)
+		if ids := sessionIds(gcs); ids != expected {
+			t.Fatalf("gc(%v, %d): '%s' != '%s'", keepDur, keepCount, ids, expected)
+		}
+	}
+
+	// The alive session is always kept, the newest ones are checked first
+	test(0, 0, "")
+	test(4*hour, 0, "c,a")
+	test(0, 2, "c,a")
+	test(0, 1, "d,c,a")
+	test(90*time.Minute, 10, "c,a")
+	test(6*hour, 1, "d,c,a")
+}
+
+func TestFindSessions(t *testing.T) {
+	now := time.Now()
+	sessions := []SessionStatus{
+		newTestSession("20210101-100000.12", 0, now, now),
+		newTestSession("20210101-100000.123", 0, now, now),
+		newTestSession("20210102-100000.45", 0, now, now),
+	}
+
+	test := func(findStr string, expected string) {
+		if ids := sessionIds(FindSessions(sessions, findStr)); ids != expected {
+			t.Fatalf("find '%s': '%s' != '%s'", findStr, ids, expected)
+		}
+	}
+
+	// The exactly matched one wins
+	test("20210101-100000.12", "20210101-100000.12")
+	test("100000.12", "20210101-100000.12,20210101-100000.123")
+	test("0102", "20210102-100000.45")
+	test("not-exists", "")
+}
+
+func TestParseGcPolicy(t *testing.T) {
+	policy, err := ParseGcPolicy("72h", "32")
+	if err != nil || policy.KeepDur != 72*time.Hour || policy.KeepCount != 32 {
+		t.Fatalf("parse policy: %v, %v", policy, err)
+	}
+	policy, err = ParseGcPolicy("", "")
+	if err != nil || policy.KeepDur != 0 || policy.KeepCount != 0 {
+		t.Fatalf("parse empty policy: %v, %v", policy, err)
+	}
+	if _, err = ParseGcPolicy("3days", "1"); err == nil {
+		t.Fatalf("bad duration should fail")
+	}
+	if _, err = ParseGcPolicy("1h", "x"); err == nil {
+		t.Fatalf("bad count should fail")
+	}
+}
+
+func TestGcSessions(t *testing.T) {
+	root, err := ioutil.TempDir("", "session-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	now := time.Now()
+	for i, id := range []string{"old", "mid", "new"} {
+		start := now.Add(time.Duration(i-3) * time.Hour)
+		status := SessionStatus{id, filepath.Join(root, id), 0, start, start, StatusSucceeded, ""}
+		os.MkdirAll(status.Dir, os.ModePerm)
+		SaveSessionStatus(status, "status")
+	}
+
+	removed, err := GcSessions(root, "status", GcPolicy{0, 1}, now)
+	if err != nil || sessionIds(removed) != "mid,old" {
+		t.Fatalf("gc sessions: '%s', %v", sessionIds(removed), err)
+	}
+	if ids := sessionIds(ListSessions(root, "status")); ids != "new" {
+		t.Fatalf("sessions left after gc: '%s'", ids)
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"syscall"
 	"unsafe"
@@ -22,6 +23,29 @@ func UserConfirm() (yes bool) {
 	return
 }
 
+// Return true if user typed 'y', false if 'n' or no more input (eg: stdin is not a terminal)
+func UserConfirmOrDecline() bool {
+	buf := bufio.NewReader(os.Stdin)
+	for {
+		line, err := buf.ReadBytes('\n')
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			panic(fmt.Errorf("[UserConfirmOrDecline] read from stdin failed: %v", err))
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == 'y' || line[0] == 'Y' {
+			return true
+		}
+		if line[0] == 'n' || line[0] == 'N' {
+			return false
+		}
+	}
+}
+
 type TerminalSize struct {
 	Row    uint16
 	Col    uint16